@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/szcvak/sps/pkg/config"
+)
+
+const keyAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// runClientKey prints the key material clients of this server need, generates new key files and
+// patches it into client binaries. Clients keep the key as a plain string, so a client is
+// patched by overwriting the key it was built with, which must be as long as the new one.
+func runClientKey(args []string) error {
+	if len(args) == 0 {
+		return writeKey(os.Stdout, currentKey())
+	}
+
+	switch args[0] {
+	case "generate":
+		key := config.Rc4KeyMaterial{
+			Key:   randomKey(len(currentKey().Key)),
+			Nonce: currentKey().Nonce,
+		}
+
+		if len(args) < 2 {
+			return writeKey(os.Stdout, key)
+		}
+
+		file, err := os.OpenFile(args[1], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		return writeKey(file, key)
+	case "patch":
+		return patchClient(args[1:])
+	}
+
+	return errUsage
+}
+
+// --- Helper functions --- //
+
+func currentKey() config.Rc4KeyMaterial {
+	return config.Get().Crypto.Rc4Keys()[0]
+}
+
+func writeKey(out io.Writer, key config.Rc4KeyMaterial) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(key)
+}
+
+func randomKey(length int) string {
+	key := make([]byte, length)
+
+	for i := range key {
+		key[i] = keyAlphabet[randomIndex(len(keyAlphabet))]
+	}
+
+	return string(key)
+}
+
+func randomIndex(n int) int {
+	// rejection sampling keeps every character equally likely
+	limit := 256 - 256%n
+	b := make([]byte, 1)
+
+	for {
+		_, _ = rand.Read(b)
+
+		if int(b[0]) < limit {
+			return int(b[0]) % n
+		}
+	}
+}
+
+func patchClient(args []string) error {
+	flags := flag.NewFlagSet("client-key patch", flag.ContinueOnError)
+	from := flags.String("from", config.Defaults().Crypto.Rc4Key, "key the client was built with")
+
+	if err := flags.Parse(args); err != nil || flags.NArg() < 2 {
+		return errUsage
+	}
+
+	key := currentKey().Key
+
+	if len(*from) != len(key) {
+		return fmt.Errorf("cannot patch a %d byte key over a %d byte key, generate a key of the same length", len(key), len(*from))
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	count := bytes.Count(data, []byte(*from))
+
+	if count == 0 {
+		return fmt.Errorf("key %q not found in %s", *from, flags.Arg(0))
+	}
+
+	patched := bytes.ReplaceAll(data, []byte(*from), []byte(key))
+
+	if err = os.WriteFile(flags.Arg(1), patched, 0o644); err != nil {
+		return err
+	}
+
+	slog.Info("client patched", "file", flags.Arg(1), "occurrences", count)
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"account": {
+		usage: "account export <high id> <low id> [file] | account import <file> | account delete <high id> <low id> | account rotate-token <high id> <low id>",
+		run:   runAccount,
+	},
+	"client-key": {
+		usage: "client-key | client-key generate [file] | client-key patch [-from key] <client binary> <output>",
+		run:   runClientKey,
+	},
+	"backup": {
+		usage: "backup <file>",
+		run:   runBackup,
+	},
+	"restore": {
+		usage: "restore <file>",
+		run:   runRestore,
+	},
+	"sanction": {
+		usage: "sanction issue <ban|suspend|mute|exclude> [-for duration] [-by issuer] <high id> <low id> [reason] | sanction lift <kind> <high id> <low id> | sanction list <high id> <low id>",
+		run:   runSanction,
+	},
+	"simulate-boxes": {
+		usage: "simulate-boxes [-box type] [-players n] [-boxes n]",
+		run:   runSimulateBoxes,
+	},
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func runCommand(name string, args []string) error {
+	cmd, exists := commands[name]
+
+	if !exists {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+
+	err := cmd.run(args)
+
+	if errors.Is(err, errUsage) {
+		return fmt.Errorf("%w, usage: sps %s", err, cmd.usage)
+	}
+
+	return err
+}
+
+func openDatabase() (*database.Manager, error) {
+	dbm, err := database.NewManager()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to psql: %w", err)
+	}
+
+	if err = dbm.CreateDefault(); err != nil {
+		dbm.Close()
+		return nil, fmt.Errorf("failed to create default db tables: %w", err)
+	}
+
+	return dbm, nil
+}
+
+// --- Account --- //
+
+func runAccount(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	dbm, err := openDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	defer dbm.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	switch args[0] {
+	case "export":
+		if len(args) < 3 {
+			return errUsage
+		}
+
+		highId, lowId, err := parseIds(args[1], args[2])
+
+		if err != nil {
+			return err
+		}
+
+		export, err := dbm.ExportAccount(ctx, highId, lowId)
+
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = os.Stdout
+
+		if len(args) > 3 {
+			file, err := os.Create(args[3])
+
+			if err != nil {
+				return err
+			}
+
+			defer file.Close()
+			out = file
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(export)
+	case "import":
+		if len(args) < 2 {
+			return errUsage
+		}
+
+		data, err := os.ReadFile(args[1])
+
+		if err != nil {
+			return err
+		}
+
+		var export database.AccountExport
+
+		if err = json.Unmarshal(data, &export); err != nil {
+			return fmt.Errorf("failed to parse account export: %w", err)
+		}
+
+		_, err = dbm.ImportAccount(ctx, &export)
+
+		return err
+	case "delete":
+		if len(args) < 3 {
+			return errUsage
+		}
+
+		highId, lowId, err := parseIds(args[1], args[2])
+
+		if err != nil {
+			return err
+		}
+
+		return dbm.DeleteAccount(ctx, highId, lowId)
+	case "rotate-token":
+		if len(args) < 3 {
+			return errUsage
+		}
+
+		highId, lowId, err := parseIds(args[1], args[2])
+
+		if err != nil {
+			return err
+		}
+
+		playerId, err := dbm.PlayerId(ctx, highId, lowId)
+
+		if err != nil {
+			return err
+		}
+
+		// the old token stops working, the player has to transfer the account with a password
+		// or recovery code or be given the new token
+		token, err := messaging.NewToken()
+
+		if err != nil {
+			return err
+		}
+
+		if err = dbm.RotateToken(ctx, playerId, token, false); err != nil {
+			return err
+		}
+
+		fmt.Println(token)
+
+		return nil
+	}
+
+	return errUsage
+}
+
+// --- Backup --- //
+
+func runBackup(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	dbm, err := openDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	defer dbm.Close()
+
+	file, err := os.Create(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	manifest, err := dbm.Backup(context.Background(), file)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("backup written", "file", args[0], "schemaVersion", manifest.SchemaVersion, "tables", len(manifest.Tables))
+
+	return nil
+}
+
+func runRestore(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	dbm, err := openDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	defer dbm.Close()
+
+	file, err := os.Open(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	manifest, err := dbm.Restore(context.Background(), file)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("backup restored", "file", args[0], "createdAt", manifest.CreatedAt, "tables", len(manifest.Tables))
+
+	return nil
+}
+
+// --- Helper functions --- //
+
+func parseIds(high string, low string) (int32, int32, error) {
+	highId, err := strconv.ParseInt(high, 10, 32)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid high id %q: %w", high, err)
+	}
+
+	lowId, err := strconv.ParseInt(low, 10, 32)
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid low id %q: %w", low, err)
+	}
+
+	return int32(highId), int32(lowId), nil
+}
@@ -1,25 +1,78 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
 	"github.com/szcvak/sps/pkg/hub"
+	"github.com/szcvak/sps/pkg/messages"
+	"github.com/szcvak/sps/pkg/messaging"
 	"github.com/szcvak/sps/pkg/network"
 )
 
 func main() {
+	if err := config.Load(); err != nil {
+		slog.Error("failed to load configuration!", "err", err)
+		os.Exit(1)
+	}
+
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			slog.Error("command failed!", "command", os.Args[1], "err", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	if err := csv.LoadAll(); err != nil {
 		slog.Error("failed to load cards!", "err", err)
 		return
 	}
 
-	core.InitEventManager(core.DefaultSchedules())
+	if err := messaging.LoadBoxes(config.Get().Economy.BoxesPath); err != nil {
+		slog.Error("failed to load boxes!", "err", err)
+		return
+	}
+
+	if err := messaging.LoadShop(config.Get().Economy.ShopPath); err != nil {
+		slog.Error("failed to load shop!", "err", err)
+		return
+	}
+
+	if err := messaging.LoadAccessories(config.Get().Economy.AccessoriesPath); err != nil {
+		slog.Error("failed to load accessories!", "err", err)
+		return
+	}
+
+	if err := messaging.LoadMilestones(config.Get().Economy.MilestonesPath); err != nil {
+		slog.Error("failed to load milestones!", "err", err)
+		return
+	}
+
+	if err := messaging.LoadLevelRewards(config.Get().Economy.LevelRewardsPath); err != nil {
+		slog.Error("failed to load level rewards!", "err", err)
+		return
+	}
+
+	if err := messaging.LoadQuests(config.Get().Economy.QuestsPath); err != nil {
+		slog.Error("failed to load quests!", "err", err)
+		return
+	}
+
+	if err := setReceiptVerifier(); err != nil {
+		slog.Error("failed to set receipt verifier!", "err", err)
+		return
+	}
+
 	core.InitTeamManager()
 	
 	hub.InitHub()
@@ -40,24 +93,167 @@ func main() {
 		return
 	}
 
-	server := network.NewServer("0.0.0.0:9339", dbm)
+	if err = dbm.MigrateLegacyPowerLevels(context.Background(), csv.Data().UnlockCardIds()); err != nil {
+		slog.Error("failed to migrate power levels!", "err", err)
+		return
+	}
+
+	core.InitEventManager(core.SchedulesFromConfig(config.Get()), messaging.NewDatabaseTicketStore(dbm))
+
+	seasons := database.NewSeasonScheduler(dbm)
+	seasons.OnReset = applySeasonReset
+
+	if err = seasons.Start(); err != nil {
+		slog.Error("failed to start season scheduler!", "err", err)
+		return
+	}
+
+	defer seasons.Close()
+
+	sanctions := database.NewSanctionListener(dbm)
+	sanctions.OnChange = func(playerId int64) {
+		messages.ApplySanctions(dbm, playerId)
+	}
+
+	if err = sanctions.Start(); err != nil {
+		slog.Error("failed to start sanction listener!", "err", err)
+		return
+	}
+
+	defer sanctions.Close()
+
+	server := network.NewServer(config.Get().Server.Address, dbm)
 	errChan := make(chan error, 1)
 
 	go func() {
 		errChan <- server.Serve()
 	}()
 
+	if address := config.Get().Server.PatchAddress; address != "" {
+		patchServer := network.NewPatchServer(address)
+		defer patchServer.Close()
+
+		go func() {
+			if err := patchServer.Serve(); err != nil {
+				slog.Error("failed to serve assets!", "err", err)
+			}
+		}()
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-	select {
-	case _ = <-stop:
-		server.Close()
-	case err := <-errChan:
-		slog.Error("faled to serve!", "err", err)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+
+	if interval := config.Get().Server.AssetsWatchInterval; interval > 0 {
+		go csv.Watch(time.Duration(interval)*time.Second, stopWatch)
+	}
+
+loop:
+	for {
+		select {
+		case _ = <-reload:
+			if err := csv.Reload(); err != nil {
+				slog.Error("failed to reload game data, keeping the old one!", "err", err)
+			}
+
+			if err := config.Reload(); err != nil {
+				slog.Error("failed to reload configuration, keeping the old one!", "err", err)
+				continue
+			}
+
+			core.GetEventManager().Reload(core.SchedulesFromConfig(config.Get()))
+			messages.DisconnectForMaintenance()
+
+			if err := messaging.LoadBoxes(config.Get().Economy.BoxesPath); err != nil {
+				slog.Error("failed to reload boxes, keeping the old ones!", "err", err)
+			}
+
+			if err := messaging.LoadShop(config.Get().Economy.ShopPath); err != nil {
+				slog.Error("failed to reload shop, keeping the old one!", "err", err)
+			}
+
+			if err := messaging.LoadAccessories(config.Get().Economy.AccessoriesPath); err != nil {
+				slog.Error("failed to reload accessories, keeping the old ones!", "err", err)
+			}
+
+			if err := messaging.LoadMilestones(config.Get().Economy.MilestonesPath); err != nil {
+				slog.Error("failed to reload milestones, keeping the old ones!", "err", err)
+			}
+
+			if err := messaging.LoadLevelRewards(config.Get().Economy.LevelRewardsPath); err != nil {
+				slog.Error("failed to reload level rewards, keeping the old ones!", "err", err)
+			}
+
+			if err := messaging.LoadQuests(config.Get().Economy.QuestsPath); err != nil {
+				slog.Error("failed to reload quests, keeping the old ones!", "err", err)
+			}
+
+			if err := setReceiptVerifier(); err != nil {
+				slog.Error("failed to reload receipt verifier, keeping the old one!", "err", err)
+			}
+		case _ = <-stop:
+			server.Close()
+			break loop
+		case err := <-errChan:
+			slog.Error("faled to serve!", "err", err)
+			break loop
+		}
 	}
 
 	if em := core.GetEventManager(); em != nil {
 		em.Close()
 	}
 }
+
+// setReceiptVerifier switches gem pack purchases to the configured receipt verifier.
+func setReceiptVerifier() error {
+	verifier, err := messaging.NewReceiptVerifier(config.Get().Economy.BillingVerifier)
+
+	if err != nil {
+		return err
+	}
+
+	messaging.SetReceiptVerifier(verifier)
+
+	if _, fake := verifier.(*messaging.FakeReceiptVerifier); fake {
+		slog.Warn("the fake receipt verifier grants every purchase, do not use it with real players")
+	}
+
+	return nil
+}
+
+// applySeasonReset mirrors a committed season reset onto the player, if they are online. It
+// runs on the season scheduler, so the change is queued for the connection of the player.
+func applySeasonReset(result database.SeasonResetResult) {
+	for _, user := range messages.LoggedInUsers() {
+		if user.Player.DbId != result.PlayerId {
+			continue
+		}
+
+		user.Player.QueueUpdate(func(player *core.Player) {
+			for id, trophies := range result.Trophies {
+				if brawler, exists := player.Brawlers[id]; exists {
+					brawler.Trophies = trophies
+				}
+			}
+
+			player.Trophies = max(player.Trophies-result.TrophiesLost, 0)
+
+			currency, exists := player.Wallet[result.CurrencyId]
+
+			if !exists {
+				currency = &core.PlayerCurrency{CurrencyId: result.CurrencyId}
+				player.Wallet[result.CurrencyId] = currency
+			}
+
+			currency.Balance += int64(result.Payout)
+		})
+
+		return
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/database"
+)
+
+var sanctionKinds = map[string]core.SanctionKind{
+	"ban":     core.SanctionBan,
+	"suspend": core.SanctionSuspension,
+	"mute":    core.SanctionMute,
+	"exclude": core.SanctionLeaderboardExclusion,
+}
+
+// runSanction issues, lifts and lists sanctions. A running server is told about every change
+// and applies it to the player right away if they are online.
+func runSanction(args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "issue":
+		return issueSanction(args[1:])
+	case "lift":
+		if len(args) < 4 {
+			return errUsage
+		}
+
+		kind, exists := sanctionKinds[args[1]]
+
+		if !exists {
+			return errUsage
+		}
+
+		return withPlayer(args[2], args[3], func(ctx context.Context, dbm *database.Manager, playerId int64) error {
+			lifted, err := dbm.LiftSanctions(ctx, playerId, kind)
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("lifted %d sanction(s)\n", lifted)
+
+			return nil
+		})
+	case "list":
+		if len(args) < 3 {
+			return errUsage
+		}
+
+		return withPlayer(args[1], args[2], listSanctions)
+	}
+
+	return errUsage
+}
+
+func issueSanction(args []string) error {
+	flags := flag.NewFlagSet("sanction issue", flag.ContinueOnError)
+
+	duration := flags.Duration("for", 0, "how long the sanction lasts, 0 for good")
+	issuer := flags.String("by", currentUser(), "who issued the sanction")
+
+	if len(args) < 1 {
+		return errUsage
+	}
+
+	kind, exists := sanctionKinds[args[0]]
+
+	if !exists {
+		return errUsage
+	}
+
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() < 2 {
+		return errUsage
+	}
+
+	reason := strings.Join(flags.Args()[2:], " ")
+
+	return withPlayer(flags.Arg(0), flags.Arg(1), func(ctx context.Context, dbm *database.Manager, playerId int64) error {
+		sanction, err := dbm.IssueSanction(ctx, playerId, kind, reason, *issuer, *duration)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("issued sanction %d\n", sanction.Id)
+
+		return nil
+	})
+}
+
+func listSanctions(ctx context.Context, dbm *database.Manager, playerId int64) error {
+	sanctions, err := dbm.SanctionHistory(ctx, playerId)
+
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "ID\tKIND\tISSUED\tEXPIRES\tSTATUS\tISSUER\tREASON")
+
+	now := time.Now()
+
+	for _, sanction := range sanctions {
+		expires := "never"
+
+		if sanction.ExpiresAt != nil {
+			expires = sanction.ExpiresAt.Format(time.DateTime)
+		}
+
+		status := "active"
+
+		if sanction.LiftedAt != nil {
+			status = "lifted"
+		} else if !sanction.Active(now) {
+			status = "expired"
+		}
+
+		fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			sanction.Id, sanctionKindName(sanction.Kind), sanction.IssuedAt.Format(time.DateTime), expires, status, sanction.Issuer, sanction.Reason)
+	}
+
+	return out.Flush()
+}
+
+// --- Helper functions --- //
+
+// withPlayer opens the database and runs fn with the id of the player with the given ids.
+func withPlayer(high string, low string, fn func(ctx context.Context, dbm *database.Manager, playerId int64) error) error {
+	highId, lowId, err := parseIds(high, low)
+
+	if err != nil {
+		return err
+	}
+
+	dbm, err := openDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	defer dbm.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	playerId, err := dbm.PlayerId(ctx, highId, lowId)
+
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, dbm, playerId)
+}
+
+func sanctionKindName(kind core.SanctionKind) string {
+	for name, k := range sanctionKinds {
+		if k == kind {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("unknown (%d)", kind)
+}
+
+func currentUser() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+
+	return "console"
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+// simulationProfile describes the inventory a synthetic player starts the simulation with.
+type simulationProfile struct {
+	name  string
+	owned float64
+}
+
+var simulationProfiles = []simulationProfile{
+	{name: "new", owned: 0},
+	{name: "half", owned: 0.5},
+	{name: "complete", owned: 1},
+}
+
+var unlockMilestones = []float64{0.25, 0.5, 0.75, 1}
+
+type simulationResult struct {
+	boxes   int
+	rewards map[int32]int64
+
+	// unlocks holds, per milestone, the number of boxes each player needed to reach it.
+	unlocks [][]int
+}
+
+// nopRewardStore lets DeliveryLogic run against in-memory players.
+type nopRewardStore struct{}
+
+func (nopRewardStore) UpdateBalance(*core.Player, int32, int64) error        { return nil }
+func (nopRewardStore) InsertBrawler(*core.Player, *core.PlayerBrawler) error { return nil }
+func (nopRewardStore) UpdateBrawler(*core.Player, *core.PlayerBrawler) error { return nil }
+func (nopRewardStore) UpdatePity(*core.Player) error                         { return nil }
+func (nopRewardStore) RecordOfferPurchase(*core.Player, string, int32) (bool, error) {
+	return true, nil
+}
+func (nopRewardStore) RevertOfferPurchase(*core.Player, string) error           { return nil }
+func (nopRewardStore) ClaimMilestone(*core.Player, int32) (bool, error)         { return true, nil }
+func (nopRewardStore) UpdateQuest(*core.Player, *core.PlayerQuest) error        { return nil }
+func (nopRewardStore) ClaimQuest(*core.Player, *core.PlayerQuest) (bool, error) { return true, nil }
+func (nopRewardStore) UnclaimQuest(*core.Player, *core.PlayerQuest) error       { return nil }
+func (nopRewardStore) GrantPurchase(*core.Player, string, string, int32, int32) (bool, error) {
+	return true, nil
+}
+func (nopRewardStore) ReplaceQuests(*core.Player, core.QuestPeriod, []*core.PlayerQuest) error {
+	return nil
+}
+func (nopRewardStore) ExtendBoost(player *core.Player, kind core.BoostKind, duration time.Duration) (*core.PlayerBoost, error) {
+	start := time.Now()
+
+	if boost := player.Boost(kind); boost.ExpiresAt != nil && boost.ExpiresAt.After(start) {
+		start = *boost.ExpiresAt
+	}
+
+	expiresAt := start.Add(duration)
+
+	return &core.PlayerBoost{Kind: kind, ExpiresAt: &expiresAt}, nil
+}
+func (nopRewardStore) AddBoost(player *core.Player, kind core.BoostKind, amount int32) (*core.PlayerBoost, error) {
+	return &core.PlayerBoost{Kind: kind, Remaining: player.Boost(kind).Remaining + amount}, nil
+}
+func (nopRewardStore) ConsumeBoost(player *core.Player, kind core.BoostKind, amount int32) (int32, int32, error) {
+	remaining := player.Boost(kind).Remaining
+	used := min(remaining, amount)
+
+	return used, remaining - used, nil
+}
+func (nopRewardStore) UnlockAccessory(*core.Player, *core.PlayerBrawler, core.AccessoryKind, int32) error {
+	return nil
+}
+
+func runSimulateBoxes(args []string) error {
+	flags := flag.NewFlagSet("simulate-boxes", flag.ContinueOnError)
+
+	boxType := flags.Int("box", 0, "box type to simulate, 0 for every box")
+	players := flags.Int("players", 200, "synthetic players per profile")
+	opened := flags.Int("boxes", 500, "boxes opened by every player")
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if *players <= 0 || *opened <= 0 {
+		return errUsage
+	}
+
+	if err := csv.LoadAll(); err != nil {
+		return fmt.Errorf("failed to load cards: %w", err)
+	}
+
+	if err := messaging.LoadBoxes(config.Get().Economy.BoxesPath); err != nil {
+		return err
+	}
+
+	if err := messaging.LoadAccessories(config.Get().Economy.AccessoriesPath); err != nil {
+		return err
+	}
+
+	// DeliveryLogic logs every box, which would drown the report.
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+
+	table := messaging.Boxes()
+	cards := unlockableCards(table)
+
+	if len(cards) == 0 {
+		return fmt.Errorf("no unlockable brawlers found")
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	for _, box := range table.Boxes {
+		if *boxType != 0 && box.Type != int32(*boxType) {
+			continue
+		}
+
+		fmt.Fprintf(out, "\n%s (type %d): %d of currency %d, %d rewards\n", box.Name, box.Type, box.Price, box.CurrencyId, box.RewardsCount)
+		fmt.Fprintln(out, "profile\tbrawlers/box\tchips/box\telixir/box\tbooster s/box\tdoubler/box\tpower points/box\t25%\t50%\t75%\t100%")
+
+		for _, profile := range simulationProfiles {
+			result := simulate(box, profile, cards, *players, *opened)
+			perBox := func(rewardId int32) string {
+				return fmt.Sprintf("%.3f", float64(result.rewards[rewardId])/float64(result.boxes))
+			}
+
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s",
+				profile.name,
+				perBox(messaging.RewardIdBrawler),
+				perBox(messaging.RewardIdChips),
+				perBox(messaging.RewardIdElixir),
+				perBox(messaging.RewardIdCoinBooster),
+				perBox(messaging.RewardIdCoinDoubler),
+				perBox(messaging.RewardIdPowerPoints),
+			)
+
+			for _, boxes := range result.unlocks {
+				fmt.Fprintf(out, "\t%s", formatUnlock(boxes, *players))
+			}
+
+			fmt.Fprintln(out)
+		}
+	}
+
+	fmt.Fprintln(out, "\nunlock columns: median boxes until that share of all brawlers is owned, with the share of players that got there")
+
+	return out.Flush()
+}
+
+// --- Helper functions --- //
+
+func simulate(box messaging.BoxDefinition, profile simulationProfile, cards []int32, players int, opened int) simulationResult {
+	result := simulationResult{
+		rewards: make(map[int32]int64),
+		unlocks: make([][]int, len(unlockMilestones)),
+	}
+
+	for i := 0; i < players; i++ {
+		player := syntheticPlayer(profile, cards)
+		reached := 0
+
+		for n := 1; n <= opened; n++ {
+			player.Wallet[box.CurrencyId].Balance = int64(box.Price)
+
+			logic := messaging.NewDeliveryLogicWithStore(player, nopRewardStore{})
+
+			if err := logic.GenerateRewards(box.Type); err != nil {
+				continue
+			}
+
+			result.boxes++
+
+			for _, reward := range logic.Rewards() {
+				result.rewards[reward.RewardId] += int64(reward.Amount)
+			}
+
+			for reached < len(unlockMilestones) && float64(len(player.Brawlers)) >= unlockMilestones[reached]*float64(len(cards)) {
+				result.unlocks[reached] = append(result.unlocks[reached], n)
+				reached++
+			}
+		}
+	}
+
+	return result
+}
+
+func syntheticPlayer(profile simulationProfile, cards []int32) *core.Player {
+	player := core.NewPlayer()
+
+	for _, currencyId := range config.DefaultCurrencies {
+		player.Wallet[currencyId] = &core.PlayerCurrency{CurrencyId: currencyId}
+	}
+
+	owned := int(math.Round(profile.owned * float64(len(cards))))
+
+	for _, card := range cards[:owned] {
+		brawlerId := csv.GetBrawlerId(card)
+		player.Brawlers[brawlerId] = &core.PlayerBrawler{BrawlerId: brawlerId, PowerLevel: 1}
+	}
+
+	return player
+}
+
+func unlockableCards(table *messaging.BoxTable) []int32 {
+	cards := make([]int32, 0)
+
+	for _, rarity := range table.Rarities {
+		cards = append(cards, csv.GetBrawlersWithRarity(rarity.Name)...)
+	}
+
+	slices.Sort(cards)
+
+	return cards
+}
+
+func formatUnlock(boxes []int, players int) string {
+	if len(boxes) == 0 {
+		return "-"
+	}
+
+	slices.Sort(boxes)
+
+	return fmt.Sprintf("%d (%.0f%%)", boxes[len(boxes)/2], 100*float64(len(boxes))/float64(players))
+}
@@ -1,68 +1,421 @@
 package config
 
 import (
-	"github.com/mroth/weightedrand/v2"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	// --- Crypto configuration --- //
+	CurrencyCoins  int32 = 1
+	CurrencyGems         = 2
+	CurrencyBling        = 3
+	CurrencyChips        = 5
+	CurrencyElixir       = 6
+)
 
-	Rc4Key      = "fhsd6f86f67rt8fw78fw789we78r9789wer6re"
-	Rc4KeyNonce = "nonce"
+var (
+	DefaultCurrencies = []int32{
+		CurrencyCoins, CurrencyGems, CurrencyBling, CurrencyChips, CurrencyElixir,
+	}
 
-	// --- General configuration --- //
+	currencyNames = map[int32]string{
+		CurrencyCoins:  "coins",
+		CurrencyGems:   "gems",
+		CurrencyBling:  "star points",
+		CurrencyChips:  "chips",
+		CurrencyElixir: "elixir",
+	}
+)
 
-	MaximumRank         = 20
-	MaximumUpgradeLevel = 6
-	SeasonEndTime       = 30 * 24 * 60 * 60
+// CurrencyName is how a currency is called in texts shown to players.
+func CurrencyName(id int32) string {
+	if name, exists := currencyNames[id]; exists {
+		return name
+	}
 
-	// --- New brawler configuration --- //
+	return fmt.Sprintf("currency %d", id)
+}
 
-	NewBrawlerPowerLevel       int32 = 1
-	NewBrawlerTrophies         int32 = 0
-	NewBrawlerPowerPoints      int32 = 0
-	NewPlayerStartingBrawlerId int32 = 0
+type Config struct {
+	Server   ServerConfig   `json:"server"`
+	Crypto   CryptoConfig   `json:"crypto"`
+	Access   AccessConfig   `json:"access"`
+	Gameplay GameplayConfig `json:"gameplay"`
+	Economy  EconomyConfig  `json:"economy"`
+	Events   []EventSlot    `json:"events"`
+}
 
-	// --- New player configuration --- //
+// --- Server configuration --- //
 
-	NewPlayerTrophies int32 = 0
+type ServerConfig struct {
+	Address     string `json:"address"`
+	DatabaseUrl string `json:"database_url"`
 
-	// --- Offer configuration --- //
+	// HighId is given to every account this server creates, telling its accounts apart from the
+	// ones of other servers. Low ids are allocated in order.
+	HighId int32 `json:"high_id"`
 
-	CoinBoosterPrice  int64 = 20
-	CoinBoosterReward int32 = 7 * 24 * 60 * 60
+	// AssetsWatchInterval is how often, in seconds, the csv files are checked for changes and
+	// reloaded. Zero only reloads them on SIGHUP.
+	AssetsWatchInterval int `json:"assets_watch_interval"`
 
-	CoinDoublerPrice  int64 = 50
-	CoinDoublerReward int32 = 1000
-)
+	// PatchAddress is where the built-in asset server listens. Empty leaves it off, for when the
+	// files are hosted elsewhere.
+	PatchAddress string `json:"patch_address"`
 
-const (
-	CurrencyCoins  int32 = 1
-	CurrencyGems         = 2
-	CurrencyBling        = 3
-	CurrencyChips        = 5
-	CurrencyElixir       = 6
-)
+	// PatchUrl is the public address of the asset server that clients download updates from.
+	PatchUrl string `json:"patch_url"`
 
-var (
-	DefaultCurrencies = []int32{
-		CurrencyCoins, CurrencyGems, CurrencyBling, CurrencyChips, CurrencyElixir,
+	// CheckFingerprint sends clients whose content fingerprint differs from the server's to
+	// PatchUrl to update instead of letting them log in.
+	CheckFingerprint bool `json:"check_fingerprint"`
+}
+
+// --- Crypto configuration --- //
+
+type CryptoConfig struct {
+	Rc4Key      string `json:"rc4_key"`
+	Rc4KeyNonce string `json:"rc4_key_nonce"`
+
+	// Rc4KeyFile names a json file with the key and nonce, which replace rc4_key and
+	// rc4_key_nonce so the key material can be kept out of the configuration.
+	Rc4KeyFile string `json:"rc4_key_file"`
+
+	// Rc4AcceptedKeys are older keys still accepted from clients that have not been patched with
+	// the current one yet. New connections pick whichever key their first packet was sent with.
+	Rc4AcceptedKeys []Rc4KeyMaterial `json:"rc4_accepted_keys"`
+
+	// RequireHandshake refuses clients that use RC4 instead of the session handshake.
+	RequireHandshake bool `json:"require_handshake"`
+}
+
+type Rc4KeyMaterial struct {
+	Key   string `json:"key"`
+	Nonce string `json:"nonce"`
+}
+
+// Rc4Keys returns every key clients may use, the current one first.
+func (c *CryptoConfig) Rc4Keys() []Rc4KeyMaterial {
+	return append([]Rc4KeyMaterial{{Key: c.Rc4Key, Nonce: c.Rc4KeyNonce}}, c.Rc4AcceptedKeys...)
+}
+
+// --- Access configuration --- //
+
+type AccessConfig struct {
+	// MinimumVersion and MaximumVersion bound the client versions that may log in, written as
+	// major.minor.build. An empty bound is open.
+	MinimumVersion string `json:"minimum_version"`
+	MaximumVersion string `json:"maximum_version"`
+
+	// UpdateUrl is where clients older than MinimumVersion are sent to update.
+	UpdateUrl string `json:"update_url"`
+
+	// Maintenance turns away everyone but Testers. Turning it on with a reload also disconnects
+	// the players that are online. MaintenanceEndTime, a unix timestamp, drives the countdown
+	// clients show and is left out when zero.
+	Maintenance        bool      `json:"maintenance"`
+	MaintenanceEndTime int64     `json:"maintenance_end_time"`
+	Testers            []Account `json:"testers"`
+
+	// CredentialAttempts is how many wrong passwords or recovery codes an address or an account
+	// may send, and how many binds a player may make, within CredentialAttemptWindow seconds
+	// before it has to wait.
+	CredentialAttempts      int `json:"credential_attempts"`
+	CredentialAttemptWindow int `json:"credential_attempt_window"`
+}
+
+type Account struct {
+	HighId int32 `json:"high_id"`
+	LowId  int32 `json:"low_id"`
+}
+
+// SupportsVersion tells whether a client version is within the configured bounds, returning
+// -1 when it is too old and 1 when it is too new.
+func (a *AccessConfig) SupportsVersion(major, minor, build int32) int {
+	version := [3]int32{major, minor, build}
+
+	if minimum, err := parseVersion(a.MinimumVersion); err == nil && minimum != nil && compareVersions(version, *minimum) < 0 {
+		return -1
 	}
 
-	DefaultCurrencyBalance = map[int32]int32{
-		CurrencyCoins:  1000,
-		CurrencyGems:   1000,
-		CurrencyBling:  5000,
-		CurrencyChips:  0,
-		CurrencyElixir: 0,
+	if maximum, err := parseVersion(a.MaximumVersion); err == nil && maximum != nil && compareVersions(version, *maximum) > 0 {
+		return 1
 	}
 
-	BoxRewardRarityChooser, _ = weightedrand.NewChooser(
-		weightedrand.NewChoice(0, 25),
-		weightedrand.NewChoice(1, 20),
-		weightedrand.NewChoice(2, 15),
-		weightedrand.NewChoice(3, 12),
-		weightedrand.NewChoice(4, 8),
-		weightedrand.NewChoice(5, 5),
-	)
-)
+	return 0
+}
+
+// IsTester tells whether an account may log in during maintenance.
+func (a *AccessConfig) IsTester(highId, lowId int32) bool {
+	for _, tester := range a.Testers {
+		if tester.HighId == highId && tester.LowId == lowId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MaintenanceSecondsLeft returns how long maintenance is expected to last from now, zero when
+// no end time is set or it has passed.
+func (a *AccessConfig) MaintenanceSecondsLeft(now time.Time) int32 {
+	if a.MaintenanceEndTime == 0 {
+		return 0
+	}
+
+	return int32(max(a.MaintenanceEndTime-now.Unix(), 0))
+}
+
+// --- Gameplay configuration --- //
+
+type GameplayConfig struct {
+	MaximumRank         int `json:"maximum_rank"`
+	MaximumUpgradeLevel int `json:"maximum_upgrade_level"`
+
+	// SeasonEndTime is the length of a season in seconds.
+	SeasonEndTime int32 `json:"season_end_time"`
+
+	// SeasonResets lists, by ascending MinTrophies, what brawler trophies are reset to at the
+	// end of a season. The trophies above ResetTo are paid out in SeasonCurrencyId.
+	SeasonResets     []SeasonReset `json:"season_resets"`
+	SeasonCurrencyId int32         `json:"season_currency_id"`
+
+	NewBrawlerPowerLevel       int32 `json:"new_brawler_power_level"`
+	NewBrawlerTrophies         int32 `json:"new_brawler_trophies"`
+	NewBrawlerPowerPoints      int32 `json:"new_brawler_power_points"`
+	NewPlayerStartingBrawlerId int32 `json:"new_player_starting_brawler_id"`
+
+	NewPlayerTrophies int32 `json:"new_player_trophies"`
+}
+
+type SeasonReset struct {
+	MinTrophies int32 `json:"min_trophies"`
+	ResetTo     int32 `json:"reset_to"`
+}
+
+// --- Economy configuration --- //
+
+type EconomyConfig struct {
+	StartingCurrencies map[int32]int32 `json:"starting_currencies"`
+
+	CoinBoosterPrice  int64 `json:"coin_booster_price"`
+	CoinBoosterReward int32 `json:"coin_booster_reward"`
+
+	CoinDoublerPrice  int64 `json:"coin_doubler_price"`
+	CoinDoublerReward int32 `json:"coin_doubler_reward"`
+
+	// UpgradeCosts holds the price of every power level, entry i takes a brawler from level
+	// i+1 to i+2. It needs an entry for every level below maximum_upgrade_level.
+	UpgradeCosts []UpgradeCost `json:"upgrade_costs"`
+
+	// BoxesPath points to the box and drop rate definitions, reloaded together with this file.
+	BoxesPath        string `json:"boxes_path"`
+	ShopPath         string `json:"shop_path"`
+	AccessoriesPath  string `json:"accessories_path"`
+	MilestonesPath   string `json:"milestones_path"`
+	LevelRewardsPath string `json:"level_rewards_path"`
+	QuestsPath       string `json:"quests_path"`
+
+	// BillingVerifier checks the receipts of gem pack purchases. "none" turns purchases off and
+	// "fake" accepts any receipt, which is only meant for development.
+	BillingVerifier string `json:"billing_verifier"`
+}
+
+type UpgradeCost struct {
+	PowerPoints int32 `json:"power_points"`
+	Coins       int64 `json:"coins"`
+}
+
+// --- Event configuration --- //
+
+type EventSlot struct {
+	DurationMinutes int           `json:"duration_minutes"`
+	Events          []EventConfig `json:"events"`
+
+	// Ticketed makes every event of the slot cost an entry fee, nil slots are free to play.
+	Ticketed *TicketedEvent `json:"ticketed,omitempty"`
+}
+
+// TicketedEvent is the entry fee and the rewards of a ticketed slot. EntryCost of CurrencyId is
+// paid when a player readies up, at most Attempts times per event.
+type TicketedEvent struct {
+	CurrencyId int32 `json:"currency_id"`
+	EntryCost  int32 `json:"entry_cost"`
+	Attempts   int32 `json:"attempts"`
+
+	// Rewards lists, by ascending MaxRank, what a battle pays out. The first tier whose MaxRank
+	// is at least the player's rank is granted, ranks below every tier get nothing.
+	Rewards []TicketedReward `json:"rewards"`
+}
+
+type TicketedReward struct {
+	MaxRank    int32 `json:"max_rank"`
+	CurrencyId int32 `json:"currency_id"`
+	Amount     int32 `json:"amount"`
+}
+
+type EventConfig struct {
+	Gamemode         string `json:"gamemode"`
+	RequiredBrawlers int32  `json:"required_brawlers"`
+	CoinsToClaim     int32  `json:"coins_to_claim"`
+	BonusCoins       int32  `json:"bonus_coins"`
+	CoinsToWin       int32  `json:"coins_to_win"`
+	EventText        string `json:"event_text"`
+	DoubleCoins      bool   `json:"double_coins"`
+	DoubleExp        bool   `json:"double_exp"`
+	MaxPlayers       int32  `json:"max_players"`
+}
+
+func Defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:             "0.0.0.0:9339",
+			AssetsWatchInterval: 10,
+		},
+		Access: AccessConfig{
+			CredentialAttempts:      5,
+			CredentialAttemptWindow: 15 * 60,
+		},
+		Crypto: CryptoConfig{
+			Rc4Key:      "fhsd6f86f67rt8fw78fw789we78r9789wer6re",
+			Rc4KeyNonce: "nonce",
+		},
+		Gameplay: GameplayConfig{
+			MaximumRank:         20,
+			MaximumUpgradeLevel: 6,
+			SeasonEndTime:       30 * 24 * 60 * 60,
+
+			SeasonResets: []SeasonReset{
+				{MinTrophies: 501, ResetTo: 500},
+				{MinTrophies: 525, ResetTo: 524},
+				{MinTrophies: 550, ResetTo: 549},
+				{MinTrophies: 575, ResetTo: 574},
+				{MinTrophies: 600, ResetTo: 599},
+				{MinTrophies: 650, ResetTo: 624},
+				{MinTrophies: 700, ResetTo: 649},
+				{MinTrophies: 750, ResetTo: 674},
+				{MinTrophies: 800, ResetTo: 699},
+				{MinTrophies: 900, ResetTo: 749},
+				{MinTrophies: 1000, ResetTo: 799},
+			},
+			SeasonCurrencyId: CurrencyBling,
+
+			NewBrawlerPowerLevel:       1,
+			NewBrawlerTrophies:         0,
+			NewBrawlerPowerPoints:      0,
+			NewPlayerStartingBrawlerId: 0,
+
+			NewPlayerTrophies: 0,
+		},
+		Economy: EconomyConfig{
+			StartingCurrencies: map[int32]int32{
+				CurrencyCoins:  1000,
+				CurrencyGems:   1000,
+				CurrencyBling:  5000,
+				CurrencyChips:  0,
+				CurrencyElixir: 0,
+			},
+
+			CoinBoosterPrice:  20,
+			CoinBoosterReward: 7 * 24 * 60 * 60,
+
+			CoinDoublerPrice:  50,
+			CoinDoublerReward: 1000,
+
+			UpgradeCosts: []UpgradeCost{
+				{PowerPoints: 20, Coins: 20},
+				{PowerPoints: 30, Coins: 35},
+				{PowerPoints: 50, Coins: 75},
+				{PowerPoints: 80, Coins: 140},
+				{PowerPoints: 130, Coins: 290},
+				{PowerPoints: 210, Coins: 480},
+				{PowerPoints: 340, Coins: 800},
+				{PowerPoints: 550, Coins: 1250},
+				{PowerPoints: 890, Coins: 1875},
+				{PowerPoints: 1440, Coins: 2800},
+			},
+
+			BoxesPath:        "assets/boxes.json",
+			ShopPath:         "assets/shop.json",
+			AccessoriesPath:  "assets/accessories.json",
+			MilestonesPath:   "assets/milestones.json",
+			LevelRewardsPath: "assets/level_rewards.json",
+			QuestsPath:       "assets/quests.json",
+
+			BillingVerifier: "none",
+		},
+		Events: []EventSlot{
+			{
+				DurationMinutes: 2 * 60,
+				Events: []EventConfig{
+					{Gamemode: "BattleRoyale", CoinsToWin: 100, EventText: "Solo Showdown", MaxPlayers: 1},
+					{Gamemode: "CoinRush", CoinsToWin: 100, EventText: "Gem Grab", MaxPlayers: 3},
+				},
+			},
+			{
+				DurationMinutes: 60,
+				Events: []EventConfig{
+					{Gamemode: "LaserBall", CoinsToWin: 100, EventText: "Brawl Ball", DoubleExp: true, MaxPlayers: 3},
+				},
+			},
+			{
+				DurationMinutes: 3 * 60,
+				Events: []EventConfig{
+					{Gamemode: "AttackDefend", CoinsToClaim: 100, CoinsToWin: 100, EventText: "Heist", DoubleCoins: true, MaxPlayers: 3},
+					{Gamemode: "BountyHunter", CoinsToWin: 100, EventText: "Bounty", MaxPlayers: 3},
+				},
+			},
+			{
+				DurationMinutes: 4 * 60,
+				Events: []EventConfig{
+					{Gamemode: "BattleRoyale", CoinsToWin: 100, EventText: "Solo Showdown", MaxPlayers: 3},
+				},
+			},
+		},
+	}
+}
+
+// --- Helper functions --- //
+
+// parseVersion parses a major.minor.build version, nil for an empty one.
+func parseVersion(version string) (*[3]int32, error) {
+	if version == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(version, ".")
+
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%q is not a major.minor.build version", version)
+	}
+
+	var parsed [3]int32
+
+	for i, part := range parts {
+		v, err := strconv.ParseInt(part, 10, 32)
+
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%q is not a major.minor.build version", version)
+		}
+
+		parsed[i] = int32(v)
+	}
+
+	return &parsed, nil
+}
+
+func compareVersions(a, b [3]int32) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return 0
+}
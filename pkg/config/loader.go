@@ -0,0 +1,374 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultPath = "config.json"
+	envPrefix   = "SPS"
+	eventSlots  = 4
+)
+
+var (
+	current atomic.Pointer[Config]
+
+	loadMu     sync.Mutex
+	loadedPath string
+)
+
+func init() {
+	c := Defaults()
+
+	if err := c.validate(); err != nil {
+		panic(fmt.Sprintf("default configuration is invalid: %v", err))
+	}
+
+	current.Store(c)
+}
+
+// Get returns the live configuration. The returned value must be treated as read-only,
+// callers that need several values consistently should keep the pointer for the duration.
+func Get() *Config {
+	return current.Load()
+}
+
+// Load builds the configuration from defaults, the file named by SPS_CONFIG (config.json when
+// unset) and environment overrides, in that order. Nothing is replaced if validation fails.
+func Load() error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	path, explicit := os.LookupEnv(envPrefix + "_CONFIG")
+
+	if !explicit {
+		path = defaultPath
+	}
+
+	c, err := build(path, explicit)
+
+	if err != nil {
+		return err
+	}
+
+	loadedPath = path
+	current.Store(c)
+
+	slog.Info("loaded configuration", "path", path)
+
+	return nil
+}
+
+// Reload re-reads the configuration file and swaps in the new access, gameplay, economy, event
+// and crypto values. Server settings only take effect on restart, so they are carried over. The
+// crypto settings apply to new connections, which lets keys be rotated without a restart.
+func Reload() error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	path := loadedPath
+
+	if path == "" {
+		path = defaultPath
+	}
+
+	c, err := build(path, loadedPath != "")
+
+	if err != nil {
+		return err
+	}
+
+	old := current.Load()
+
+	if c.Server != old.Server {
+		slog.Warn("server configuration changes require a restart, keeping old values")
+	}
+
+	c.Server = old.Server
+
+	current.Store(c)
+
+	slog.Info("reloaded configuration", "path", path)
+
+	return nil
+}
+
+// --- Private methods --- //
+
+func build(path string, required bool) (*Config, error) {
+	c := Defaults()
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read configuration file %s: %w", path, err)
+		}
+
+		slog.Info("no configuration file found, using defaults", "path", path)
+	} else {
+		// json merges array elements into the existing ones, so the default events, upgrade
+		// costs and season resets are only restored when the file has none instead of leaking
+		// fields into configured entries.
+		c.Events = nil
+		c.Economy.UpgradeCosts = nil
+		c.Gameplay.SeasonResets = nil
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		if err = decoder.Decode(c); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+		}
+
+		if c.Events == nil {
+			c.Events = Defaults().Events
+		}
+
+		if c.Economy.UpgradeCosts == nil {
+			c.Economy.UpgradeCosts = Defaults().Economy.UpgradeCosts
+		}
+
+		if c.Gameplay.SeasonResets == nil {
+			c.Gameplay.SeasonResets = Defaults().Gameplay.SeasonResets
+		}
+	}
+
+	if err = applyEnv(c); err != nil {
+		return nil, err
+	}
+
+	if err = loadKeyFile(&c.Crypto); err != nil {
+		return nil, err
+	}
+
+	if err = c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return c, nil
+}
+
+// loadKeyFile replaces the configured key and nonce with the ones in crypto.rc4_key_file, if
+// it is set. The file is read again on every reload.
+func loadKeyFile(crypto *CryptoConfig) error {
+	if crypto.Rc4KeyFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(crypto.Rc4KeyFile)
+
+	if err != nil {
+		return fmt.Errorf("failed to read key file %s: %w", crypto.Rc4KeyFile, err)
+	}
+
+	var key Rc4KeyMaterial
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(&key); err != nil {
+		return fmt.Errorf("failed to parse key file %s: %w", crypto.Rc4KeyFile, err)
+	}
+
+	crypto.Rc4Key = key.Key
+	crypto.Rc4KeyNonce = key.Nonce
+
+	return nil
+}
+
+// applyEnv overrides scalar fields from variables named SPS_<SECTION>_<FIELD>, using the upper
+// cased json names, e.g. SPS_GAMEPLAY_MAXIMUM_RANK. DATABASE_URL is honoured for compatibility.
+func applyEnv(c *Config) error {
+	if url, ok := os.LookupEnv("DATABASE_URL"); ok {
+		c.Server.DatabaseUrl = url
+	}
+
+	errs := make([]error, 0)
+	root := reflect.ValueOf(c).Elem()
+
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Field(i)
+
+		if section.Kind() != reflect.Struct || !root.Type().Field(i).IsExported() {
+			continue
+		}
+
+		sectionName := jsonName(root.Type().Field(i))
+
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Field(j)
+			name := strings.ToUpper(envPrefix + "_" + sectionName + "_" + jsonName(section.Type().Field(j)))
+
+			value, ok := os.LookupEnv(name)
+
+			if !ok {
+				continue
+			}
+
+			if err := setScalar(field, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Address != "", "server.address must not be empty")
+	check(c.Server.HighId >= 0, "server.high_id must not be negative, got %d", c.Server.HighId)
+	check(c.Server.AssetsWatchInterval >= 0, "server.assets_watch_interval must not be negative, got %d", c.Server.AssetsWatchInterval)
+	check(!c.Server.CheckFingerprint || c.Server.PatchUrl != "", "server.patch_url must be set when server.check_fingerprint is on")
+	minimum, err := parseVersion(c.Access.MinimumVersion)
+	check(err == nil, "access.minimum_version: %v", err)
+
+	maximum, err := parseVersion(c.Access.MaximumVersion)
+	check(err == nil, "access.maximum_version: %v", err)
+
+	if minimum != nil && maximum != nil {
+		check(compareVersions(*minimum, *maximum) <= 0, "access.minimum_version must not be above access.maximum_version")
+	}
+
+	check(c.Access.MaintenanceEndTime >= 0, "access.maintenance_end_time must not be negative, got %d", c.Access.MaintenanceEndTime)
+	check(c.Access.CredentialAttempts >= 1, "access.credential_attempts must be at least 1, got %d", c.Access.CredentialAttempts)
+	check(c.Access.CredentialAttemptWindow >= 1, "access.credential_attempt_window must be at least 1, got %d", c.Access.CredentialAttemptWindow)
+	check(c.Crypto.Rc4Key != "", "crypto.rc4_key must not be empty")
+
+	for i, key := range c.Crypto.Rc4AcceptedKeys {
+		check(key.Key != "", "crypto.rc4_accepted_keys[%d].key must not be empty", i)
+	}
+
+	check(c.Gameplay.MaximumRank >= 1, "gameplay.maximum_rank must be at least 1, got %d", c.Gameplay.MaximumRank)
+	check(c.Gameplay.MaximumUpgradeLevel >= 1 && c.Gameplay.MaximumUpgradeLevel <= 11, "gameplay.maximum_upgrade_level must be between 1 and 11, got %d", c.Gameplay.MaximumUpgradeLevel)
+	check(c.Gameplay.SeasonEndTime > 0, "gameplay.season_end_time must be positive, got %d", c.Gameplay.SeasonEndTime)
+	check(len(c.Gameplay.SeasonResets) > 0, "gameplay.season_resets must not be empty")
+
+	for i, reset := range c.Gameplay.SeasonResets {
+		check(reset.ResetTo >= 0 && reset.ResetTo < reset.MinTrophies, "gameplay.season_resets[%d].reset_to must be between 0 and min_trophies", i)
+
+		if i > 0 {
+			previous := c.Gameplay.SeasonResets[i-1]
+			check(reset.MinTrophies > previous.MinTrophies, "gameplay.season_resets[%d].min_trophies must be above the previous entry", i)
+			check(reset.ResetTo >= previous.ResetTo, "gameplay.season_resets[%d].reset_to must not be below the previous entry", i)
+		}
+	}
+
+	check(slices.Contains(DefaultCurrencies, c.Gameplay.SeasonCurrencyId), "gameplay.season_currency_id %d is not a currency", c.Gameplay.SeasonCurrencyId)
+	check(c.Gameplay.NewBrawlerPowerLevel >= 1 && c.Gameplay.NewBrawlerPowerLevel <= 11, "gameplay.new_brawler_power_level must be between 1 and 11, got %d", c.Gameplay.NewBrawlerPowerLevel)
+	check(c.Gameplay.NewBrawlerTrophies >= 0, "gameplay.new_brawler_trophies must not be negative")
+	check(c.Gameplay.NewBrawlerPowerPoints >= 0, "gameplay.new_brawler_power_points must not be negative")
+	check(c.Gameplay.NewPlayerTrophies >= 0, "gameplay.new_player_trophies must not be negative")
+
+	for _, currency := range DefaultCurrencies {
+		balance, ok := c.Economy.StartingCurrencies[currency]
+		check(ok && balance >= 0, "economy.starting_currencies must contain a non-negative balance for currency %d", currency)
+	}
+
+	check(c.Economy.CoinBoosterPrice >= 0, "economy.coin_booster_price must not be negative")
+	check(c.Economy.CoinBoosterReward > 0, "economy.coin_booster_reward must be positive")
+	check(c.Economy.CoinDoublerPrice >= 0, "economy.coin_doubler_price must not be negative")
+	check(c.Economy.CoinDoublerReward > 0, "economy.coin_doubler_reward must be positive")
+	check(len(c.Economy.UpgradeCosts) >= c.Gameplay.MaximumUpgradeLevel-1, "economy.upgrade_costs must have at least %d entries, got %d", c.Gameplay.MaximumUpgradeLevel-1, len(c.Economy.UpgradeCosts))
+
+	for i, cost := range c.Economy.UpgradeCosts {
+		check(cost.PowerPoints >= 0 && cost.Coins >= 0, "economy.upgrade_costs[%d] must not be negative", i)
+	}
+
+	check(c.Economy.BoxesPath != "", "economy.boxes_path must not be empty")
+	check(c.Economy.ShopPath != "", "economy.shop_path must not be empty")
+	check(c.Economy.AccessoriesPath != "", "economy.accessories_path must not be empty")
+	check(c.Economy.MilestonesPath != "", "economy.milestones_path must not be empty")
+	check(c.Economy.LevelRewardsPath != "", "economy.level_rewards_path must not be empty")
+	check(c.Economy.QuestsPath != "", "economy.quests_path must not be empty")
+	check(c.Economy.BillingVerifier == "none" || c.Economy.BillingVerifier == "fake", "economy.billing_verifier must be none or fake, got %q", c.Economy.BillingVerifier)
+
+	check(len(c.Events) == eventSlots, "events must have %d slots, got %d", eventSlots, len(c.Events))
+
+	for i, slot := range c.Events {
+		check(slot.DurationMinutes > 0, "events[%d].duration_minutes must be positive", i)
+		check(len(slot.Events) > 0, "events[%d].events must not be empty", i)
+
+		for j, event := range slot.Events {
+			check(event.Gamemode != "", "events[%d].events[%d].gamemode must not be empty", i, j)
+			check(event.MaxPlayers > 0, "events[%d].events[%d].max_players must be positive", i, j)
+		}
+
+		if ticketed := slot.Ticketed; ticketed != nil {
+			check(slices.Contains(DefaultCurrencies, ticketed.CurrencyId), "events[%d].ticketed.currency_id %d is not a currency", i, ticketed.CurrencyId)
+			check(ticketed.EntryCost >= 0, "events[%d].ticketed.entry_cost must not be negative", i)
+			check(ticketed.Attempts > 0, "events[%d].ticketed.attempts must be positive", i)
+			check(len(ticketed.Rewards) > 0, "events[%d].ticketed.rewards must not be empty", i)
+
+			for j, reward := range ticketed.Rewards {
+				check(reward.MaxRank >= 1, "events[%d].ticketed.rewards[%d].max_rank must be at least 1", i, j)
+				check(slices.Contains(DefaultCurrencies, reward.CurrencyId), "events[%d].ticketed.rewards[%d].currency_id %d is not a currency", i, j, reward.CurrencyId)
+				check(reward.Amount > 0, "events[%d].ticketed.rewards[%d].amount must be positive", i, j)
+
+				if j > 0 {
+					check(reward.MaxRank > ticketed.Rewards[j-1].MaxRank, "events[%d].ticketed.rewards[%d].max_rank must be above the previous entry", i, j)
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// --- Helper functions --- //
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+func setScalar(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(v)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(v)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+
+	return nil
+}
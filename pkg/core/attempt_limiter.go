@@ -0,0 +1,71 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter counts failed attempts per key over a sliding window, to slow down guessing
+// of passwords and recovery codes. It only lives in memory, so a restart clears it.
+type AttemptLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+}
+
+func NewAttemptLimiter() *AttemptLimiter {
+	return &AttemptLimiter{
+		failures: make(map[string][]time.Time),
+	}
+}
+
+// RetryAfter returns how long key has to wait before its next attempt, zero when it had fewer
+// than limit failures within window.
+func (a *AttemptLimiter) RetryAfter(key string, limit int, window time.Duration, now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	failures := a.prune(key, window, now)
+
+	if limit <= 0 || len(failures) < limit {
+		return 0
+	}
+
+	return failures[len(failures)-limit].Add(window).Sub(now)
+}
+
+// Fail records a failed attempt by key.
+func (a *AttemptLimiter) Fail(key string, window time.Duration, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures[key] = append(a.prune(key, window, now), now)
+}
+
+// Reset forgets the failures of key, after it got an attempt right.
+func (a *AttemptLimiter) Reset(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.failures, key)
+}
+
+// --- Private methods --- //
+
+func (a *AttemptLimiter) prune(key string, window time.Duration, now time.Time) []time.Time {
+	failures := a.failures[key]
+	start := 0
+
+	for start < len(failures) && !failures[start].Add(window).After(now) {
+		start++
+	}
+
+	if start == len(failures) {
+		delete(a.failures, key)
+		return nil
+	}
+
+	failures = failures[start:]
+	a.failures[key] = failures
+
+	return failures
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiterLimits(t *testing.T) {
+	limiter := NewAttemptLimiter()
+	window := time.Minute
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 3; i++ {
+		if wait := limiter.RetryAfter("key", 3, window, now); wait != 0 {
+			t.Fatalf("expected no wait after %d failures, got %v", i, wait)
+		}
+
+		limiter.Fail("key", window, now.Add(time.Duration(i)*time.Second))
+	}
+
+	if wait := limiter.RetryAfter("key", 3, window, now.Add(3*time.Second)); wait != 57*time.Second {
+		t.Fatalf("expected to wait 57s, got %v", wait)
+	}
+
+	if wait := limiter.RetryAfter("other", 3, window, now); wait != 0 {
+		t.Fatalf("expected other keys not to wait, got %v", wait)
+	}
+}
+
+func TestAttemptLimiterWindow(t *testing.T) {
+	limiter := NewAttemptLimiter()
+	window := time.Minute
+	now := time.Unix(1_700_000_000, 0)
+
+	limiter.Fail("key", window, now)
+	limiter.Fail("key", window, now.Add(30*time.Second))
+
+	if wait := limiter.RetryAfter("key", 2, window, now.Add(time.Minute)); wait != 0 {
+		t.Fatalf("expected the first failure to have expired, got %v", wait)
+	}
+
+	if wait := limiter.RetryAfter("key", 1, window, now.Add(time.Minute)); wait != 30*time.Second {
+		t.Fatalf("expected to wait 30s, got %v", wait)
+	}
+}
+
+func TestAttemptLimiterReset(t *testing.T) {
+	limiter := NewAttemptLimiter()
+	window := time.Minute
+	now := time.Unix(1_700_000_000, 0)
+
+	limiter.Fail("key", window, now)
+	limiter.Reset("key")
+
+	if wait := limiter.RetryAfter("key", 1, window, now); wait != 0 {
+		t.Fatalf("expected no wait after a reset, got %v", wait)
+	}
+}
@@ -2,57 +2,122 @@ package core
 
 import (
 	"encoding/binary"
+	"errors"
 	"log/slog"
 	"net"
+	"sync"
 
 	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/crypt"
 )
 
+var errNoCodec = errors.New("connection has no codec yet")
+
 type ClientWrapper struct {
 	conn   net.Conn
 	Player *Player
 
-	encryptor *crypt.Rc4
-	decryptor *crypt.Rc4
+	// codec is picked from the first packet of the connection, nothing is sent before that.
+	codec  crypt.Codec
+	sendMu sync.Mutex
 }
 
 func NewClientWrapper(conn net.Conn) *ClientWrapper {
-	fullKey := append([]byte(config.Rc4Key), []byte(config.Rc4KeyNonce)...)
-
-	encryptor := crypt.NewRc4(fullKey)
-	decryptor := crypt.NewRc4(fullKey)
-
-	encryptor.Process(fullKey)
-	decryptor.Process(fullKey)
-
 	return &ClientWrapper{
 		conn:   conn,
 		Player: NewPlayer(),
+	}
+}
+
+// NewRc4Codec returns the codec of legacy clients for the key their first payload was sent
+// with. While older keys are accepted, each key decrypts a copy of the payload until valid
+// accepts the result. The current key is used when valid is nil or no key fits.
+func NewRc4Codec(first []byte, valid func([]byte) bool) crypt.Codec {
+	keys := config.Get().Crypto.Rc4Keys()
 
-		encryptor: encryptor,
-		decryptor: decryptor,
+	if valid == nil || len(keys) == 1 {
+		return newRc4Codec(keys[0])
 	}
+
+	for i, key := range keys {
+		probe := make([]byte, len(first))
+		copy(probe, first)
+
+		decrypted, err := newRc4Codec(key).Decrypt(probe)
+
+		if err != nil || !valid(decrypted) {
+			continue
+		}
+
+		if i > 0 {
+			slog.Info("client uses an older rc4 key", "index", i)
+		}
+
+		return newRc4Codec(key)
+	}
+
+	slog.Warn("no accepted rc4 key fits the first packet, using the current one")
+
+	return newRc4Codec(keys[0])
 }
 
 func (w *ClientWrapper) Close() {
 	_ = w.conn.Close()
 }
 
-func (w *ClientWrapper) Decrypt(payload []byte) {
-	w.decryptor.Process(payload)
+// SetCodec sets the encryption of the connection, once its first packet has shown which one
+// the client uses.
+func (w *ClientWrapper) SetCodec(codec crypt.Codec) {
+	w.sendMu.Lock()
+	defer w.sendMu.Unlock()
+
+	w.codec = codec
 }
 
-func (w *ClientWrapper) Encrypt(payload []byte) {
-	w.encryptor.Process(payload)
+// Decrypt is only called by the goroutine reading the connection.
+func (w *ClientWrapper) Decrypt(payload []byte) ([]byte, error) {
+	if w.codec == nil {
+		return nil, errNoCodec
+	}
+
+	return w.codec.Decrypt(payload)
 }
 
 func (w *ClientWrapper) Send(id uint16, version uint16, payload []byte) {
-	encrypted := make([]byte, len(payload))
-	copy(encrypted, payload)
+	w.sendMu.Lock()
+	defer w.sendMu.Unlock()
 
-	w.Encrypt(encrypted)
-	l := len(encrypted)
+	if w.codec == nil {
+		slog.Error("failed to send packet!", "id", id, "err", errNoCodec)
+		return
+	}
+
+	encrypted, err := w.codec.Encrypt(payload)
+
+	if err != nil {
+		slog.Error("failed to encrypt payload!", "id", id, "err", err)
+		return
+	}
+
+	w.write(id, version, encrypted)
+}
+
+// SendRaw sends payload without encryption, which is only done for the handshake.
+func (w *ClientWrapper) SendRaw(id uint16, version uint16, payload []byte) {
+	w.sendMu.Lock()
+	defer w.sendMu.Unlock()
+
+	w.write(id, version, payload)
+}
+
+func (w *ClientWrapper) Conn() net.Conn {
+	return w.conn
+}
+
+// --- Private methods --- //
+
+func (w *ClientWrapper) write(id uint16, version uint16, payload []byte) {
+	l := len(payload)
 
 	packetSize := 2 + 3 + 2 + l
 	packet := make([]byte, packetSize)
@@ -65,7 +130,7 @@ func (w *ClientWrapper) Send(id uint16, version uint16, payload []byte) {
 
 	binary.BigEndian.PutUint16(packet[5:7], version)
 
-	copy(packet[7:], encrypted)
+	copy(packet[7:], payload)
 
 	_, err := w.conn.Write(packet)
 
@@ -76,6 +141,8 @@ func (w *ClientWrapper) Send(id uint16, version uint16, payload []byte) {
 	slog.Info("sent packet", "id", id, "size", len(packet), "ver", version)
 }
 
-func (w *ClientWrapper) Conn() net.Conn {
-	return w.conn
+// --- Helper functions --- //
+
+func newRc4Codec(key config.Rc4KeyMaterial) crypt.Codec {
+	return crypt.NewRc4Codec([]byte(key.Key), []byte(key.Nonce))
 }
@@ -8,6 +8,41 @@ const (
 	StateLoggedIn
 )
 
+type AccessoryKind int32
+
+const (
+	AccessoryStarPower AccessoryKind = iota
+	AccessoryGadget
+	AccessoryGear
+)
+
+type QuestPeriod int32
+
+const (
+	QuestDaily QuestPeriod = iota
+	QuestWeekly
+)
+
+// BoostKind identifies a boost. Time based boosts last until their expiry, quantity based ones
+// until their remaining amount is used up.
+type BoostKind int32
+
+const (
+	BoostCoinBooster BoostKind = 1
+	BoostCoinDoubler BoostKind = 2
+)
+
+// SanctionKind identifies a moderation sanction. Bans and suspensions keep a player from logging
+// in, mutes keep them out of chat and leaderboard exclusions off the leaderboards.
+type SanctionKind int32
+
+const (
+	SanctionBan                  SanctionKind = 1
+	SanctionSuspension           SanctionKind = 2
+	SanctionMute                 SanctionKind = 3
+	SanctionLeaderboardExclusion SanctionKind = 4
+)
+
 const (
 	TeamLeftReasonLeft int32 = 0
 	TeamLeftReasonKicked int32 = 1
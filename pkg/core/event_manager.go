@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/csv"
 )
 
@@ -36,6 +38,9 @@ type EventConfig struct {
 type EventSlotSchedule struct {
 	Configs  []EventConfig
 	Duration time.Duration
+
+	// Ticketed is set for slots whose events cost an entry fee.
+	Ticketed *config.TicketedEvent
 }
 
 type ActiveEvent struct {
@@ -45,6 +50,23 @@ type ActiveEvent struct {
 	EndTime    time.Time
 	Config     EventConfig
 	SeenBy     []int64
+
+	Ticketed *config.TicketedEvent
+	Entries  map[int64]*TicketEntry
+}
+
+// TicketEntry tracks a player in a ticketed event. Pending is set from paying the entry fee
+// until the result of the battle arrives, readying up again in between is free.
+type TicketEntry struct {
+	Attempts int32
+	Pending  bool
+}
+
+// TicketStore keeps the entries of ticketed events by slot and start time, so a restart during
+// an event neither gives attempts back nor drops entries that were paid for.
+type TicketStore interface {
+	LoadTicketEntries(slot int, startTime time.Time) (map[int64]*TicketEntry, error)
+	SaveTicketEntry(slot int, startTime time.Time, playerId int64, entry TicketEntry) error
 }
 
 type EventManager struct {
@@ -52,6 +74,7 @@ type EventManager struct {
 	slotData       [NumEventSlots]slotInternalData
 	locationGetter func(gameMode string) []int32
 	tickers        [NumEventSlots]*time.Ticker
+	tickets        TicketStore
 
 	stopChan chan struct{}
 }
@@ -67,9 +90,13 @@ var (
 	eventManagerOnce     sync.Once
 
 	errInvalidSlot = errors.New("invalid event slot index")
+
+	ErrNoAttemptsLeft = errors.New("no attempts left for this event")
 )
 
-func InitEventManager(schedules [NumEventSlots]EventSlotSchedule) {
+// InitEventManager starts the event rotation. Events start on multiples of their slot duration,
+// so the event running before a restart is the one picked up again along with its entries.
+func InitEventManager(schedules [NumEventSlots]EventSlotSchedule, tickets TicketStore) {
 	eventManagerOnce.Do(func() {
 		for i, s := range schedules {
 			if len(s.Configs) == 0 {
@@ -102,6 +129,7 @@ func InitEventManager(schedules [NumEventSlots]EventSlotSchedule) {
 
 		em := &EventManager{
 			locationGetter: getter,
+			tickets:        tickets,
 			stopChan:       make(chan struct{}),
 		}
 
@@ -133,40 +161,66 @@ func GetEventManager() *EventManager {
 	return eventManagerInstance
 }
 
-func DefaultSchedules() [NumEventSlots]EventSlotSchedule {
-	return [NumEventSlots]EventSlotSchedule{
-		{
-			Configs: []EventConfig{
-				{Gamemode: GameModeShowdown, RequiredBrawlers: 0, CoinsToClaim: 0, BonusCoins: 0, CoinsToWin: 100, EventText: "Solo Showdown", MaxPlayers: 1},
-				{Gamemode: GameModeGemGrab, RequiredBrawlers: 0, CoinsToClaim: 0, BonusCoins: 0, CoinsToWin: 100, EventText: "Gem Grab", MaxPlayers: 3},
-			},
-			Duration: 2 * time.Hour,
-		},
-		{
-			Configs: []EventConfig{
-				{Gamemode: GameModeBrawlBall, RequiredBrawlers: 0, CoinsToClaim: 0, BonusCoins: 0, CoinsToWin: 100, EventText: "Brawl Ball", DoubleExp: true, MaxPlayers: 3},
-			},
-			Duration: 1 * time.Hour,
-		},
-		{
-			Configs: []EventConfig{
-				{Gamemode: GameModeHeist, RequiredBrawlers: 0, CoinsToClaim: 100, BonusCoins: 0, CoinsToWin: 100, EventText: "Heist", DoubleCoins: true, MaxPlayers: 3},
-				{Gamemode: GameModeBounty, RequiredBrawlers: 0, CoinsToClaim: 0, BonusCoins: 0, CoinsToWin: 100, EventText: "Bounty", MaxPlayers: 3},
-			},
-			Duration: 3 * time.Hour,
-		},
-		{
-			Configs: []EventConfig{
-				{Gamemode: GameModeShowdown, RequiredBrawlers: 0, CoinsToClaim: 0, BonusCoins: 0, CoinsToWin: 100, EventText: "Solo Showdown", MaxPlayers: 3},
-			},
-			Duration: 4 * time.Hour,
-		},
+// SchedulesFromConfig builds the slot schedules from the event section of the configuration.
+func SchedulesFromConfig(cfg *config.Config) [NumEventSlots]EventSlotSchedule {
+	var schedules [NumEventSlots]EventSlotSchedule
+
+	for i := 0; i < NumEventSlots && i < len(cfg.Events); i++ {
+		slot := cfg.Events[i]
+		configs := make([]EventConfig, 0, len(slot.Events))
+
+		for _, event := range slot.Events {
+			configs = append(configs, EventConfig(event))
+		}
+
+		schedules[i] = EventSlotSchedule{
+			Configs:  configs,
+			Duration: time.Duration(slot.DurationMinutes) * time.Minute,
+			Ticketed: slot.Ticketed,
+		}
+	}
+
+	return schedules
+}
+
+// Reload replaces the slot schedules without interrupting the running events. A slot whose
+// duration changed has its current event end on the next multiple of the new duration. Ticket
+// changes apply from the next event, so entries paid for the current one keep their ticket.
+func (em *EventManager) Reload(schedules [NumEventSlots]EventSlotSchedule) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	now := time.Now()
+
+	for i, s := range schedules {
+		if len(s.Configs) == 0 || s.Duration <= 0 {
+			slog.Warn("ignoring invalid schedule on reload", "slot", i)
+			continue
+		}
+
+		slot := &em.slotData[i]
+
+		if slot.rotationIndex >= len(s.Configs) {
+			slot.rotationIndex = len(s.Configs) - 1
+		}
+
+		if s.Duration != slot.schedule.Duration {
+			slot.currentEvent.EndTime = now.Truncate(s.Duration).Add(s.Duration)
+
+			if em.tickers[i] != nil {
+				em.tickers[i].Reset(untilEnd(&slot.currentEvent, now))
+			}
+		}
+
+		slot.schedule = s
 	}
+
+	slog.Info("event schedules reloaded")
 }
 
 func (em *EventManager) startRotationLoops() {
 	for i := 0; i < NumEventSlots; i++ {
-		ticker := time.NewTicker(em.slotData[i].schedule.Duration)
+		ticker := time.NewTicker(untilEnd(&em.slotData[i].currentEvent, time.Now()))
 
 		em.tickers[i] = ticker
 
@@ -209,7 +263,9 @@ func (em *EventManager) slotRotationLoop(slotIndex int, tick <-chan time.Time) {
 	}
 }
 
-func (em *EventManager) rotateEventForSlot(slotIndex int, startTime time.Time) error {
+// rotateEventForSlot starts the next event of slot where the current one ends, or on the last
+// multiple of the slot duration before now when there is none.
+func (em *EventManager) rotateEventForSlot(slotIndex int, now time.Time) error {
 	if slotIndex < 0 || slotIndex >= NumEventSlots {
 		return errInvalidSlot
 	}
@@ -224,6 +280,12 @@ func (em *EventManager) rotateEventForSlot(slotIndex int, startTime time.Time) e
 		return fmt.Errorf("schedule for slot %d is empty", slotIndex)
 	}
 
+	startTime := now.Truncate(schedule.Duration)
+
+	if end := slot.currentEvent.EndTime; !end.IsZero() {
+		startTime = end
+	}
+
 	slot.rotationIndex = (slot.rotationIndex + 1) % len(schedule.Configs)
 	config := schedule.Configs[slot.rotationIndex]
 
@@ -245,6 +307,22 @@ func (em *EventManager) rotateEventForSlot(slotIndex int, startTime time.Time) e
 		EndTime:    startTime.Add(schedule.Duration),
 		Config:     config,
 		SeenBy:     []int64{},
+		Ticketed:   schedule.Ticketed,
+		Entries:    make(map[int64]*TicketEntry),
+	}
+
+	if schedule.Ticketed != nil && em.tickets != nil {
+		entries, err := em.tickets.LoadTicketEntries(slotIndex, startTime)
+
+		if err != nil {
+			slog.Error("failed to load ticket entries!", "slot", slotIndex, "err", err)
+		} else {
+			slot.currentEvent.Entries = entries
+		}
+	}
+
+	if em.tickers[slotIndex] != nil {
+		em.tickers[slotIndex].Reset(untilEnd(&slot.currentEvent, now))
 	}
 
 	slog.Info("rotated event", "slot", slotIndex, "gamemode", config.Gamemode, "location", locationID, "endTime", slot.currentEvent.EndTime)
@@ -260,6 +338,107 @@ func (em *EventManager) GetCurrentEvent(slot int32) ActiveEvent {
 	return em.slotData[slot].currentEvent
 }
 
+// EnterTicketed registers the player for the current event of slot. It returns the ticket and
+// whether its entry fee is due, which is not the case for free events or for players who
+// already paid and have not played yet. A due entry counts as an attempt until CancelTicketed.
+func (em *EventManager) EnterTicketed(slot int32, playerId int64) (*config.TicketedEvent, bool, error) {
+	if slot < 0 || slot >= NumEventSlots {
+		return nil, false, errInvalidSlot
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	event := &em.slotData[slot].currentEvent
+
+	if event.Ticketed == nil {
+		return nil, false, nil
+	}
+
+	entry, exists := event.Entries[playerId]
+
+	if !exists {
+		entry = &TicketEntry{}
+		event.Entries[playerId] = entry
+	}
+
+	if entry.Pending {
+		return event.Ticketed, false, nil
+	}
+
+	if entry.Attempts >= event.Ticketed.Attempts {
+		return nil, false, ErrNoAttemptsLeft
+	}
+
+	entry.Attempts++
+	entry.Pending = true
+
+	if err := em.saveTicketEntry(event, playerId, entry); err != nil {
+		entry.Attempts--
+		entry.Pending = false
+
+		return nil, false, err
+	}
+
+	return event.Ticketed, true, nil
+}
+
+// CancelTicketed gives back the attempt taken by EnterTicketed when the entry fee could not be
+// paid.
+func (em *EventManager) CancelTicketed(slot int32, playerId int64) {
+	if slot < 0 || slot >= NumEventSlots {
+		return
+	}
+
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	event := &em.slotData[slot].currentEvent
+
+	if entry, exists := event.Entries[playerId]; exists && entry.Pending {
+		entry.Attempts--
+		entry.Pending = false
+
+		if err := em.saveTicketEntry(event, playerId, entry); err != nil {
+			slog.Error("failed to give back ticket attempt!", "slot", slot, "playerId", playerId, "err", err)
+		}
+	}
+}
+
+// FinishTicketed closes the pending entry of the player in the ticketed event played at
+// location. It returns the ticket of the event, nil if location is not a ticketed event, and
+// whether the player had paid for the battle.
+func (em *EventManager) FinishTicketed(location int32, playerId int64) (*config.TicketedEvent, bool) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	var ticketed *config.TicketedEvent
+
+	for i := 0; i < NumEventSlots; i++ {
+		event := &em.slotData[i].currentEvent
+
+		if event.Ticketed == nil || event.LocationId != location {
+			continue
+		}
+
+		ticketed = event.Ticketed
+
+		if entry, exists := event.Entries[playerId]; exists && entry.Pending {
+			entry.Pending = false
+
+			if err := em.saveTicketEntry(event, playerId, entry); err != nil {
+				slog.Error("failed to close ticket entry!", "slot", i, "playerId", playerId, "err", err)
+			}
+
+			return event.Ticketed, true
+		}
+	}
+
+	return ticketed, false
+}
+
+// Embed writes the event slots. The client knows no ticketed events, so their entry fee, the
+// attempts player has left and the rewards are told in the event text.
 func (em *EventManager) Embed(stream *ByteStream, player *Player) {
 	em.mu.RLock()
 	defer em.mu.RUnlock()
@@ -312,7 +491,18 @@ func (em *EventManager) Embed(stream *ByteStream, player *Player) {
 		stream.Write(VInt(0))
 		stream.Write(VInt(visionState)) // 1=new event, 2=seen
 
-		stream.Write(event.Config.EventText)
+		if event.Ticketed != nil {
+			entry, exists := event.Entries[player.DbId]
+
+			if !exists {
+				entry = &TicketEntry{}
+			}
+
+			stream.Write(ticketedText(event.Config.EventText, event.Ticketed, entry))
+		} else {
+			stream.Write(event.Config.EventText)
+		}
+
 		stream.Write(false)
 	}
 
@@ -365,7 +555,59 @@ func (em *EventManager) Embed(stream *ByteStream, player *Player) {
 		stream.Write(VInt(0))
 		stream.Write(VInt(1))
 
-		stream.Write(nextConfig.EventText)
+		if schedule.Ticketed != nil {
+			stream.Write(ticketedText(nextConfig.EventText, schedule.Ticketed, nil))
+		} else {
+			stream.Write(nextConfig.EventText)
+		}
+
 		stream.Write(false)
 	}
 }
+
+// --- Private methods --- //
+
+func (em *EventManager) saveTicketEntry(event *ActiveEvent, playerId int64, entry *TicketEntry) error {
+	if em.tickets == nil {
+		return nil
+	}
+
+	return em.tickets.SaveTicketEntry(event.SlotIndex, event.StartTime, playerId, *entry)
+}
+
+// --- Helper functions --- //
+
+// untilEnd returns how long event has left at now, at least a millisecond for tickers.
+func untilEnd(event *ActiveEvent, now time.Time) time.Duration {
+	return max(event.EndTime.Sub(now), time.Millisecond)
+}
+
+// ticketedText adds the entry fee and rewards of a ticketed event to its text, and the attempts
+// left when entry is given, e.g. "Entry: 10 gems, 2 of 3 attempts left. Rank 1: 100 coins.".
+func ticketedText(text string, ticketed *config.TicketedEvent, entry *TicketEntry) string {
+	var builder strings.Builder
+
+	if text != "" {
+		builder.WriteString(text)
+		builder.WriteString(" - ")
+	}
+
+	fmt.Fprintf(&builder, "Entry: %d %s", ticketed.EntryCost, config.CurrencyName(ticketed.CurrencyId))
+
+	switch {
+	case entry == nil:
+		fmt.Fprintf(&builder, ", %d attempts", ticketed.Attempts)
+	case entry.Pending:
+		builder.WriteString(", paid")
+	default:
+		fmt.Fprintf(&builder, ", %d of %d attempts left", max(ticketed.Attempts-entry.Attempts, 0), ticketed.Attempts)
+	}
+
+	builder.WriteString(".")
+
+	for _, reward := range ticketed.Rewards {
+		fmt.Fprintf(&builder, " Rank %d: %d %s.", reward.MaxRank, reward.Amount, config.CurrencyName(reward.CurrencyId))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+)
+
+type memoryTicketStore map[time.Time]map[int64]TicketEntry
+
+func (s memoryTicketStore) LoadTicketEntries(_ int, startTime time.Time) (map[int64]*TicketEntry, error) {
+	entries := make(map[int64]*TicketEntry)
+
+	for playerId, entry := range s[startTime] {
+		entries[playerId] = &entry
+	}
+
+	return entries, nil
+}
+
+func (s memoryTicketStore) SaveTicketEntry(_ int, startTime time.Time, playerId int64, entry TicketEntry) error {
+	if s[startTime] == nil {
+		s[startTime] = make(map[int64]TicketEntry)
+	}
+
+	s[startTime][playerId] = entry
+
+	return nil
+}
+
+func newTestEventManager(ticketed *config.TicketedEvent, store TicketStore, now time.Time) *EventManager {
+	em := &EventManager{
+		locationGetter: func(string) []int32 { return []int32{7} },
+		tickets:        store,
+	}
+
+	for i := 0; i < NumEventSlots; i++ {
+		em.slotData[i] = slotInternalData{
+			schedule:      EventSlotSchedule{Configs: []EventConfig{{Gamemode: GameModeShowdown}}, Duration: time.Hour, Ticketed: ticketed},
+			rotationIndex: -1,
+		}
+
+		_ = em.rotateEventForSlot(i, now)
+	}
+
+	return em
+}
+
+func TestTicketEntriesSurviveRestart(t *testing.T) {
+	ticketed := &config.TicketedEvent{Attempts: 1}
+	store := memoryTicketStore{}
+	now := time.Unix(1_700_000_000, 0)
+
+	em := newTestEventManager(ticketed, store, now)
+
+	if _, due, err := em.EnterTicketed(0, 1); err != nil || !due {
+		t.Fatalf("expected the entry fee to be due, got %v, %v", due, err)
+	}
+
+	restarted := newTestEventManager(ticketed, store, now.Add(10*time.Minute))
+
+	if _, due, err := restarted.EnterTicketed(0, 1); err != nil || due {
+		t.Fatalf("expected the paid entry to be kept, got %v, %v", due, err)
+	}
+
+	if _, paid := restarted.FinishTicketed(7, 1); !paid {
+		t.Fatalf("expected the battle to be paid for")
+	}
+
+	restarted = newTestEventManager(ticketed, store, now.Add(20*time.Minute))
+
+	if _, _, err := restarted.EnterTicketed(0, 1); err != ErrNoAttemptsLeft {
+		t.Fatalf("expected no attempts left, got %v", err)
+	}
+}
+
+func TestReloadKeepsTicketOfCurrentEvent(t *testing.T) {
+	ticketed := &config.TicketedEvent{Attempts: 1}
+	now := time.Unix(1_700_000_000, 0)
+
+	em := newTestEventManager(ticketed, nil, now)
+
+	if _, _, err := em.EnterTicketed(0, 1); err != nil {
+		t.Fatalf("expected to enter, got %v", err)
+	}
+
+	var schedules [NumEventSlots]EventSlotSchedule
+
+	for i := range schedules {
+		schedules[i] = em.slotData[i].schedule
+		schedules[i].Ticketed = nil
+	}
+
+	em.Reload(schedules)
+
+	if ticket, paid := em.FinishTicketed(7, 1); ticket != ticketed || !paid {
+		t.Fatalf("expected the paid entry to keep its ticket, got %v, %v", ticket, paid)
+	}
+}
@@ -2,29 +2,38 @@ package core
 
 import "github.com/szcvak/sps/pkg/config"
 
-func EmbedMilestones(stream *ByteStream) {
-	goalIdx0 := config.MaximumRank - 1
+// MilestoneClaimed tells whether the Trophy Road reward, or the experience reward when
+// trophyRoad is false, reached at threshold has been claimed.
+type MilestoneClaimed func(trophyRoad bool, threshold int) bool
+
+// EmbedMilestones writes the Trophy Road and experience milestones, each with whether its
+// reward was claimed. claimed may be nil, in which case nothing is.
+func EmbedMilestones(stream *ByteStream, claimed MilestoneClaimed) {
+	goalIdx0 := config.Get().Gameplay.MaximumRank - 1
 	goalIdx5 := 499
 
+	if claimed == nil {
+		claimed = func(bool, int) bool { return false }
+	}
+
 	stream.Write(VInt(goalIdx0 + goalIdx5))
 
 	for i := 0; i < goalIdx0; i++ {
-		stream.Write(VInt(1))
-		stream.Write(VInt(i))
+		var start, progress int
 
 		if i >= 34 {
-			stream.Write(VInt(TrophyProgressStart[33] + 50*(i-33)))
+			start, progress = TrophyProgressStart[33]+50*(i-33), 50
 		} else {
-			stream.Write(VInt(TrophyProgressStart[i]))
+			start, progress = TrophyProgressStart[i], TrophyProgress[i]
 		}
 
-		if i >= 34 {
-			stream.Write(VInt(50))
-		} else {
-			stream.Write(VInt(TrophyProgress[i]))
-		}
+		stream.Write(VInt(1))
+		stream.Write(VInt(i))
 
-		stream.Write(VInt(0))
+		stream.Write(VInt(start))
+		stream.Write(VInt(progress))
+
+		stream.Write(claimedState(claimed(true, start+progress)))
 		stream.Write(VInt(1))
 		stream.Write(VInt(1))
 		stream.Write(VInt(10))
@@ -39,7 +48,7 @@ func EmbedMilestones(stream *ByteStream) {
 		stream.Write(VInt(ExperienceProgressStart[i]))
 		stream.Write(VInt(ExperienceProgress[i]))
 
-		stream.Write(VInt(0))
+		stream.Write(claimedState(claimed(false, ExperienceProgressStart[i]+ExperienceProgress[i])))
 		stream.Write(VInt(1))
 		stream.Write(VInt(12))
 		stream.Write(VInt(20))
@@ -48,6 +57,14 @@ func EmbedMilestones(stream *ByteStream) {
 	}
 }
 
+func claimedState(claimed bool) VInt {
+	if claimed {
+		return 1
+	}
+
+	return 0
+}
+
 // --- Ugly code ahead --- //
 
 var (
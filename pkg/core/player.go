@@ -1,6 +1,9 @@
 package core
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type PlayerBrawler struct {
 	BrawlerId int32 `db:"brawler_id"`
@@ -16,12 +19,62 @@ type PlayerBrawler struct {
 	SelectedGear1     *int32 `db:"selected_gear1"`
 	SelectedGear2     *int32 `db:"selected_gear2"`
 
+	// Unlocked accessory ids, loaded from the player_unlocked_* tables.
+	StarPowers []int32
+	Gadgets    []int32
+	Gears      []int32
+
 	UnlockedSkinIds []int32          `db:"unlocked_skins"`
 	Cards           map[string]int32 `db:"cards"`
 
 	SelectedSkinId int32 `db:"selected_skin"`
 }
 
+// Accessories is what a brawler takes into battle, 0 for an empty slot.
+type Accessories struct {
+	StarPower int32
+	Gadget    int32
+	Gear1     int32
+	Gear2     int32
+}
+
+// PlayerQuest is a quest assigned to a player for the period starting at AssignedAt.
+type PlayerQuest struct {
+	Period QuestPeriod `db:"period"`
+	Slot   int32       `db:"slot"`
+
+	TemplateId string `db:"template_id"`
+	Progress   int32  `db:"progress"`
+
+	Claimed  bool `db:"claimed"`
+	Rerolled bool `db:"rerolled"`
+
+	AssignedAt time.Time `db:"assigned_at"`
+}
+
+// PlayerBoost is a boost the player owns. ExpiresAt is set for time based boosts, Remaining is
+// what is left of quantity based ones.
+type PlayerBoost struct {
+	Kind BoostKind `db:"kind"`
+
+	ExpiresAt *time.Time `db:"expires_at"`
+	Remaining int32      `db:"remaining"`
+}
+
+// PlayerSanction is a moderation sanction issued against the player. ExpiresAt is nil for
+// permanent sanctions and LiftedAt is set once a sanction has been lifted early.
+type PlayerSanction struct {
+	Id   int64        `db:"id"`
+	Kind SanctionKind `db:"kind"`
+
+	Reason string `db:"reason"`
+	Issuer string `db:"issuer"`
+
+	IssuedAt  time.Time  `db:"issued_at"`
+	ExpiresAt *time.Time `db:"expires_at"`
+	LiftedAt  *time.Time `db:"lifted_at"`
+}
+
 type PlayerCurrency struct {
 	CurrencyId int32 `db:"currency_id"`
 	Balance    int64 `db:"balance"`
@@ -51,8 +104,6 @@ type Player struct {
 	ControlMode   int32 `db:"control_mode"`
 	TutorialState int32 `db:"tutorial_state"`
 
-	CoinBooster int32 `db:"coin_booster"`
-	CoinDoubler int32 `db:"coin_doubler"`
 	CoinsReward int32 `db:"coins_reward"`
 	Experience  int32 `db:"experience"`
 
@@ -70,6 +121,34 @@ type Player struct {
 	SelectedCardHigh int32 `db:"selected_card_high"`
 	SelectedCardLow  int32 `db:"selected_card_low"`
 
+	// PityCounters holds the number of box rewards since the last brawler, per rarity.
+	PityCounters map[int32]int32
+
+	// OfferPurchases counts purchases per shop offer key.
+	OfferPurchases map[string]int32
+
+	// ShopShownAt is when home data last listed the shop. The client buys offers by their
+	// position in that list.
+	ShopShownAt time.Time
+
+	ClaimedMilestones map[int32]bool
+
+	// Quests holds the daily quests followed by the weekly ones, by slot.
+	Quests []*PlayerQuest
+
+	Boosts map[BoostKind]*PlayerBoost
+
+	// sanctions holds the sanctions that were active when the player was loaded, replaced
+	// whenever one is issued or lifted while they are online. They are replaced from outside
+	// the connection of the player, so they are only accessed under sanctionsMu.
+	sanctions   []*PlayerSanction
+	sanctionsMu sync.RWMutex
+
+	// updates holds changes made elsewhere that the connection of the player applies before
+	// handling its next packet, so the rest of the player is only touched by that connection.
+	updates   []func(*Player)
+	updatesMu sync.Mutex
+
 	state PlayerState
 }
 
@@ -78,10 +157,165 @@ func NewPlayer() *Player {
 		Brawlers: make(map[int32]*PlayerBrawler),
 		Wallet:   make(map[int32]*PlayerCurrency),
 
+		PityCounters:   make(map[int32]int32),
+		OfferPurchases: make(map[string]int32),
+
+		ClaimedMilestones: make(map[int32]bool),
+		Quests:            make([]*PlayerQuest, 0),
+		Boosts:            make(map[BoostKind]*PlayerBoost),
+		sanctions:         make([]*PlayerSanction, 0),
+
 		state: StateSession,
 	}
 }
 
+// Active reports whether the boost still has an effect at now.
+func (b *PlayerBoost) Active(now time.Time) bool {
+	if b.ExpiresAt != nil {
+		return now.Before(*b.ExpiresAt)
+	}
+
+	return b.Remaining > 0
+}
+
+// TimeLeft returns how long a time based boost still lasts at now, zero once it has expired.
+func (b *PlayerBoost) TimeLeft(now time.Time) time.Duration {
+	if b.ExpiresAt == nil {
+		return 0
+	}
+
+	return max(b.ExpiresAt.Sub(now), 0)
+}
+
+// Boost returns the player's boost of kind, an empty one if they have none.
+func (p *Player) Boost(kind BoostKind) *PlayerBoost {
+	if boost, exists := p.Boosts[kind]; exists {
+		return boost
+	}
+
+	return &PlayerBoost{Kind: kind}
+}
+
+// Active reports whether the sanction is still in effect at now.
+func (s *PlayerSanction) Active(now time.Time) bool {
+	return s.LiftedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+// Sanction returns the player's active sanction of kind that lasts the longest, nil if they have
+// none.
+func (p *Player) Sanction(kind SanctionKind, now time.Time) *PlayerSanction {
+	p.sanctionsMu.RLock()
+	defer p.sanctionsMu.RUnlock()
+
+	var longest *PlayerSanction
+
+	for _, sanction := range p.sanctions {
+		if sanction.Kind != kind || !sanction.Active(now) {
+			continue
+		}
+
+		if sanction.ExpiresAt == nil {
+			return sanction
+		}
+
+		if longest == nil || sanction.ExpiresAt.After(*longest.ExpiresAt) {
+			longest = sanction
+		}
+	}
+
+	return longest
+}
+
+// SetSanctions replaces the player's sanctions with the ones active now.
+func (p *Player) SetSanctions(sanctions []*PlayerSanction) {
+	p.sanctionsMu.Lock()
+	defer p.sanctionsMu.Unlock()
+
+	p.sanctions = sanctions
+}
+
+// QueueUpdate hands update to the connection of the player, which applies it before handling
+// its next packet.
+func (p *Player) QueueUpdate(update func(*Player)) {
+	p.updatesMu.Lock()
+	defer p.updatesMu.Unlock()
+
+	p.updates = append(p.updates, update)
+}
+
+// ApplyUpdates applies the queued updates. Only the connection of the player calls it.
+func (p *Player) ApplyUpdates() {
+	p.updatesMu.Lock()
+	updates := p.updates
+	p.updates = nil
+	p.updatesMu.Unlock()
+
+	for _, update := range updates {
+		update(p)
+	}
+}
+
+func (b *PlayerBrawler) SelectedAccessories() Accessories {
+	value := func(id *int32) int32 {
+		if id == nil {
+			return 0
+		}
+
+		return *id
+	}
+
+	return Accessories{
+		StarPower: value(b.SelectedStarPower),
+		Gadget:    value(b.SelectedGadget),
+		Gear1:     value(b.SelectedGear1),
+		Gear2:     value(b.SelectedGear2),
+	}
+}
+
+// Unlocked returns the unlocked accessory ids of kind.
+func (b *PlayerBrawler) Unlocked(kind AccessoryKind) *[]int32 {
+	switch kind {
+	case AccessoryStarPower:
+		return &b.StarPowers
+	case AccessoryGadget:
+		return &b.Gadgets
+	default:
+		return &b.Gears
+	}
+}
+
+// SelectedAccessories returns the accessories of the brawler selected for team play.
+func (p *Player) SelectedAccessories() Accessories {
+	brawler, exists := p.Brawlers[p.SelectedCardLow]
+
+	if !exists {
+		return Accessories{}
+	}
+
+	return brawler.SelectedAccessories()
+}
+
+// Level returns the experience level of the player.
+func (p *Player) Level() int32 {
+	return ExperienceLevel(p.Experience)
+}
+
+// ExperienceLevel returns the level reached with experience, starting at 1. RequiredExp holds
+// the experience needed for every level.
+func ExperienceLevel(experience int32) int32 {
+	level := int32(1)
+
+	for i, required := range RequiredExp {
+		if experience < required {
+			break
+		}
+
+		level = int32(i + 1)
+	}
+
+	return level
+}
+
 func (p *Player) SetState(state PlayerState) {
 	p.state = state
 }
@@ -0,0 +1,45 @@
+package crypt
+
+// Codec encrypts the payloads sent on one connection and decrypts the ones received on it.
+// Payloads must be passed in the order they are sent or received.
+type Codec interface {
+	Encrypt(payload []byte) ([]byte, error)
+	Decrypt(payload []byte) ([]byte, error)
+}
+
+// Rc4Codec is the encryption legacy clients expect, one RC4 stream per direction keyed with
+// key and nonce.
+type Rc4Codec struct {
+	encryptor *Rc4
+	decryptor *Rc4
+}
+
+func NewRc4Codec(key []byte, nonce []byte) *Rc4Codec {
+	fullKey := append(append([]byte{}, key...), nonce...)
+
+	encryptor := NewRc4(fullKey)
+	decryptor := NewRc4(fullKey)
+
+	// the client skips as many bytes of the keystream as the key is long
+	encryptor.Process(append([]byte{}, fullKey...))
+	decryptor.Process(append([]byte{}, fullKey...))
+
+	return &Rc4Codec{
+		encryptor: encryptor,
+		decryptor: decryptor,
+	}
+}
+
+func (c *Rc4Codec) Encrypt(payload []byte) ([]byte, error) {
+	encrypted := make([]byte, len(payload))
+	copy(encrypted, payload)
+
+	c.encryptor.Process(encrypted)
+
+	return encrypted, nil
+}
+
+func (c *Rc4Codec) Decrypt(payload []byte) ([]byte, error) {
+	c.decryptor.Process(payload)
+	return payload, nil
+}
@@ -0,0 +1,215 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The handshake replaces the static RC4 key for clients that support it. The client opens the
+// connection with a ClientHello carrying an ephemeral X25519 public key, the server answers
+// with a ServerHello carrying its own, and both derive a pair of session keys from the shared
+// secret. Every later payload is sealed with AES-256-GCM under a per-direction counter nonce.
+//
+// The exchange is not authenticated, it keeps captures from being decrypted but does not stop
+// an active man in the middle.
+const (
+	ClientHelloId uint16 = 10199
+	ServerHelloId uint16 = 20199
+
+	handshakeVersion byte = 1
+
+	sessionKeyInfo = "sps session keys"
+)
+
+var (
+	ErrInvalidHello = errors.New("invalid handshake hello")
+	ErrDecrypt      = errors.New("failed to authenticate payload")
+)
+
+// SessionCodec seals payloads with the keys agreed in the handshake.
+type SessionCodec struct {
+	seal cipher.AEAD
+	open cipher.AEAD
+
+	sealCounter uint64
+	openCounter uint64
+}
+
+// ClientHandshake is the client side of the handshake, for tools and tests that talk to the
+// server like a client would.
+type ClientHandshake struct {
+	key *ecdh.PrivateKey
+}
+
+// AcceptHandshake answers a ClientHello payload. It returns the ServerHello payload, which is
+// sent unencrypted, and the codec for everything after it.
+func AcceptHandshake(hello []byte) ([]byte, *SessionCodec, error) {
+	clientKey, err := parseHello(hello)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serverKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+
+	clientToServer, serverToClient, err := deriveSessionKeys(serverKey, clientKey, clientKey, serverKey.PublicKey())
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codec, err := newSessionCodec(serverToClient, clientToServer)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeHello(serverKey.PublicKey()), codec, nil
+}
+
+func NewClientHandshake() (*ClientHandshake, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+
+	return &ClientHandshake{key: key}, nil
+}
+
+// Hello returns the ClientHello payload.
+func (h *ClientHandshake) Hello() []byte {
+	return encodeHello(h.key.PublicKey())
+}
+
+// Finish reads the ServerHello payload and returns the client's codec.
+func (h *ClientHandshake) Finish(hello []byte) (*SessionCodec, error) {
+	serverKey, err := parseHello(hello)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clientToServer, serverToClient, err := deriveSessionKeys(h.key, serverKey, h.key.PublicKey(), serverKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newSessionCodec(clientToServer, serverToClient)
+}
+
+func (c *SessionCodec) Encrypt(payload []byte) ([]byte, error) {
+	nonce := counterNonce(c.sealCounter, c.seal.NonceSize())
+	c.sealCounter++
+
+	return c.seal.Seal(nil, nonce, payload, nil), nil
+}
+
+func (c *SessionCodec) Decrypt(payload []byte) ([]byte, error) {
+	nonce := counterNonce(c.openCounter, c.open.NonceSize())
+
+	decrypted, err := c.open.Open(nil, nonce, payload, nil)
+
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	c.openCounter++
+
+	return decrypted, nil
+}
+
+// --- Helper functions --- //
+
+func encodeHello(key *ecdh.PublicKey) []byte {
+	return append([]byte{handshakeVersion}, key.Bytes()...)
+}
+
+func parseHello(hello []byte) (*ecdh.PublicKey, error) {
+	if len(hello) == 0 || hello[0] != handshakeVersion {
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidHello)
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(hello[1:])
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHello, err)
+	}
+
+	return key, nil
+}
+
+// deriveSessionKeys returns the client to server and the server to client keys. Both public
+// keys go into the salt so that each side derives the keys of exactly this exchange.
+func deriveSessionKeys(own *ecdh.PrivateKey, peer *ecdh.PublicKey, client *ecdh.PublicKey, server *ecdh.PublicKey) ([]byte, []byte, error) {
+	secret, err := own.ECDH(peer)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidHello, err)
+	}
+
+	salt := append(client.Bytes(), server.Bytes()...)
+	keys, err := hkdf.Key(sha256.New, secret, salt, sessionKeyInfo, 64)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to derive session keys: %w", err)
+	}
+
+	return keys[:32], keys[32:], nil
+}
+
+func newSessionCodec(sealKey []byte, openKey []byte) (*SessionCodec, error) {
+	seal, err := newAead(sealKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := newAead(openKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionCodec{
+		seal: seal,
+		open: open,
+	}, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aead: %w", err)
+	}
+
+	return aead, nil
+}
+
+// counterNonce turns the number of payloads sent so far into a nonce, which never repeats for
+// a key because every session has its own keys.
+func counterNonce(counter uint64, size int) []byte {
+	nonce := make([]byte, size)
+	binary.BigEndian.PutUint64(nonce[size-8:], counter)
+
+	return nonce
+}
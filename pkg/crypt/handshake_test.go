@@ -0,0 +1,136 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestHandshakeRoundTrip(t *testing.T) {
+	server, client := handshake(t)
+
+	payloads := [][]byte{[]byte("first"), {}, bytes.Repeat([]byte{0xAB}, 4096)}
+
+	for _, payload := range payloads {
+		sealed, err := client.Encrypt(payload)
+
+		if err != nil {
+			t.Fatalf("failed to encrypt: %v", err)
+		}
+
+		if len(payload) > 0 && bytes.Contains(sealed, payload) {
+			t.Fatalf("expected payload to be encrypted")
+		}
+
+		opened, err := server.Decrypt(sealed)
+
+		if err != nil {
+			t.Fatalf("failed to decrypt: %v", err)
+		}
+
+		if !bytes.Equal(opened, payload) {
+			t.Fatalf("expected %x, got %x", payload, opened)
+		}
+
+		sealed, _ = server.Encrypt(payload)
+		opened, err = client.Decrypt(sealed)
+
+		if err != nil || !bytes.Equal(opened, payload) {
+			t.Fatalf("expected %x, got %x (%v)", payload, opened, err)
+		}
+	}
+}
+
+func TestHandshakeRejectsTamperedPayload(t *testing.T) {
+	server, client := handshake(t)
+
+	sealed, _ := client.Encrypt([]byte("attack at dawn"))
+
+	for i := range sealed {
+		tampered := bytes.Clone(sealed)
+		tampered[i] ^= 0x01
+
+		if _, err := server.Decrypt(tampered); !errors.Is(err, ErrDecrypt) {
+			t.Fatalf("expected %v for byte %d, got %v", ErrDecrypt, i, err)
+		}
+	}
+
+	// a rejected payload does not advance the counter, so the real one still opens
+	if opened, err := server.Decrypt(sealed); err != nil || string(opened) != "attack at dawn" {
+		t.Fatalf("expected the original payload to open, got %q (%v)", opened, err)
+	}
+}
+
+func TestHandshakeRejectsReplayedPayload(t *testing.T) {
+	server, client := handshake(t)
+
+	first, _ := client.Encrypt([]byte("first"))
+	second, _ := client.Encrypt([]byte("second"))
+
+	if _, err := server.Decrypt(second); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected reordered payload to be rejected, got %v", err)
+	}
+
+	if _, err := server.Decrypt(first); err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+
+	if _, err := server.Decrypt(first); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected replayed payload to be rejected, got %v", err)
+	}
+}
+
+func TestHandshakeSessionsDiffer(t *testing.T) {
+	server, _ := handshake(t)
+	_, other := handshake(t)
+
+	sealed, _ := other.Encrypt([]byte("payload"))
+
+	if _, err := server.Decrypt(sealed); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected payload of another session to be rejected, got %v", err)
+	}
+}
+
+func TestHandshakeInvalidHello(t *testing.T) {
+	hellos := []struct {
+		name  string
+		hello []byte
+	}{
+		{name: "Empty", hello: nil},
+		{name: "Version", hello: append([]byte{handshakeVersion + 1}, make([]byte, 32)...)},
+		{name: "Short", hello: []byte{handshakeVersion, 1, 2, 3}},
+		{name: "LowOrder", hello: append([]byte{handshakeVersion}, make([]byte, 32)...)},
+	}
+
+	for _, vector := range hellos {
+		t.Run(vector.name, func(t *testing.T) {
+			if _, _, err := AcceptHandshake(vector.hello); !errors.Is(err, ErrInvalidHello) {
+				t.Fatalf("expected %v, got %v", ErrInvalidHello, err)
+			}
+		})
+	}
+}
+
+func handshake(t *testing.T) (*SessionCodec, *SessionCodec) {
+	t.Helper()
+
+	client, err := NewClientHandshake()
+
+	if err != nil {
+		t.Fatalf("failed to start handshake: %v", err)
+	}
+
+	hello, server, err := AcceptHandshake(client.Hello())
+
+	if err != nil {
+		t.Fatalf("failed to accept handshake: %v", err)
+	}
+
+	codec, err := client.Finish(hello)
+
+	if err != nil {
+		t.Fatalf("failed to finish handshake: %v", err)
+	}
+
+	return server, codec
+}
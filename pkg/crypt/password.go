@@ -0,0 +1,107 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Secrets are hashed with argon2id using the parameters recommended in RFC 9106 for memory
+// constrained servers, and stored in the PHC string format so the parameters can be raised
+// later without breaking existing hashes.
+const (
+	argonTime    uint32 = 3
+	argonMemory  uint32 = 64 * 1024
+	argonThreads uint8  = 4
+	argonKeyLen  uint32 = 32
+	argonSaltLen        = 16
+
+	// at most this many hashes run at once, each one takes argonMemory KiB
+	maxConcurrentHashes = 4
+)
+
+var (
+	ErrInvalidHash = errors.New("invalid secret hash")
+
+	hashSlots = make(chan struct{}, maxConcurrentHashes)
+)
+
+// HashSecret hashes a password or recovery code for storage.
+func HashSecret(secret string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := idKey([]byte(secret), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifySecret tells whether secret matches a hash made by HashSecret, in constant time.
+func VerifySecret(encoded string, secret string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrInvalidHash
+	}
+
+	var version int
+	var memory, time uint32
+	var threads uint8
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+
+	if err != nil {
+		return false, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+
+	if err != nil || len(key) == 0 {
+		return false, ErrInvalidHash
+	}
+
+	other := idKey([]byte(secret), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// RandomToken returns n random bytes, hex encoded.
+func RandomToken(n int) (string, error) {
+	data := make([]byte, n)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return hex.EncodeToString(data), nil
+}
+
+// --- Helper functions --- //
+
+// idKey runs argon2id once a hash slot is free, so a burst of logins or binds cannot make the
+// server allocate argonMemory for each of them at the same time.
+func idKey(secret []byte, salt []byte, time uint32, memory uint32, threads uint8, keyLen uint32) []byte {
+	hashSlots <- struct{}{}
+	defer func() { <-hashSlots }()
+
+	return argon2.IDKey(secret, salt, time, memory, threads, keyLen)
+}
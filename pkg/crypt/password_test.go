@@ -0,0 +1,62 @@
+package crypt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHashSecretVerifies(t *testing.T) {
+	hash, err := HashSecret("correct horse battery staple")
+
+	if err != nil {
+		t.Fatalf("failed to hash secret: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Fatalf("unexpected hash format %s", hash)
+	}
+
+	ok, err := VerifySecret(hash, "correct horse battery staple")
+
+	if err != nil || !ok {
+		t.Fatalf("expected secret to verify, got %v, %v", ok, err)
+	}
+
+	ok, err = VerifySecret(hash, "correct horse battery stapler")
+
+	if err != nil || ok {
+		t.Fatalf("expected wrong secret to be refused, got %v, %v", ok, err)
+	}
+}
+
+func TestHashSecretSalts(t *testing.T) {
+	first, _ := HashSecret("secret")
+	second, _ := HashSecret("secret")
+
+	if first == second {
+		t.Fatalf("expected different hashes for the same secret, got %s twice", first)
+	}
+}
+
+func TestVerifySecretInvalidHash(t *testing.T) {
+	hashes := []struct {
+		name string
+		hash string
+	}{
+		{name: "Empty", hash: ""},
+		{name: "Argon2i", hash: "$argon2i$v=19$m=65536,t=3,p=4$c2FsdA$a2V5"},
+		{name: "Version", hash: "$argon2id$v=16$m=65536,t=3,p=4$c2FsdA$a2V5"},
+		{name: "Parameters", hash: "$argon2id$v=19$m=x,t=3,p=4$c2FsdA$a2V5"},
+		{name: "Salt", hash: "$argon2id$v=19$m=65536,t=3,p=4$!!!$a2V5"},
+		{name: "NoKey", hash: "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$"},
+	}
+
+	for _, vector := range hashes {
+		t.Run(vector.name, func(t *testing.T) {
+			if _, err := VerifySecret(vector.hash, "secret"); !errors.Is(err, ErrInvalidHash) {
+				t.Fatalf("expected %v, got %v", ErrInvalidHash, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestRc4Vectors(t *testing.T) {
+	vectors := []struct {
+		name      string
+		key       string
+		plaintext string
+		expected  string
+	}{
+		{name: "Key", key: "Key", plaintext: "Plaintext", expected: "bbf316e8d940af0ad3"},
+		{name: "Wiki", key: "Wiki", plaintext: "pedia", expected: "1021bf0420"},
+		{name: "Secret", key: "Secret", plaintext: "Attack at dawn", expected: "45a01f645fc35b383552544b9bf5"},
+	}
+
+	for _, vector := range vectors {
+		t.Run(vector.name, func(t *testing.T) {
+			data := []byte(vector.plaintext)
+			NewRc4([]byte(vector.key)).Process(data)
+
+			if got := hex.EncodeToString(data); got != vector.expected {
+				t.Fatalf("expected %s, got %s", vector.expected, got)
+			}
+		})
+	}
+}
+
+// The keystream vectors come from RFC 6229 for the 40 bit key 0x0102030405.
+func TestRc4Keystream(t *testing.T) {
+	key, _ := hex.DecodeString("0102030405")
+
+	offsets := []struct {
+		offset   int
+		expected string
+	}{
+		{offset: 0, expected: "b2396305f03dc027ccc3524a0a1118a8"},
+		{offset: 16, expected: "6982944f18fc82d589c403a47a0d0919"},
+	}
+
+	keystream := make([]byte, 32)
+	NewRc4(key).Process(keystream)
+
+	for _, vector := range offsets {
+		got := hex.EncodeToString(keystream[vector.offset : vector.offset+16])
+
+		if got != vector.expected {
+			t.Fatalf("offset %d: expected %s, got %s", vector.offset, vector.expected, got)
+		}
+	}
+}
+
+func TestRc4ProcessIsStreaming(t *testing.T) {
+	key := []byte("fhsd6f86f67rt8fw78fw789we78r9789wer6renonce")
+	plaintext := []byte("the same keystream whether processed at once or in pieces")
+
+	whole := bytes.Clone(plaintext)
+	NewRc4(key).Process(whole)
+
+	pieces := bytes.Clone(plaintext)
+	rc4 := NewRc4(key)
+
+	for i := 0; i < len(pieces); i += 7 {
+		rc4.Process(pieces[i:min(i+7, len(pieces))])
+	}
+
+	if !bytes.Equal(whole, pieces) {
+		t.Fatalf("expected %x, got %x", whole, pieces)
+	}
+}
+
+func TestRc4CodecSkipsKeyLength(t *testing.T) {
+	key := []byte("fhsd6f86f67rt8fw78fw789we78r9789wer6re")
+	nonce := []byte("nonce")
+	fullKey := append(bytes.Clone(key), nonce...)
+
+	plaintext := []byte("login")
+
+	expected := make([]byte, len(fullKey)+len(plaintext))
+	copy(expected[len(fullKey):], plaintext)
+	NewRc4(fullKey).Process(expected)
+
+	encrypted, err := NewRc4Codec(key, nonce).Encrypt(plaintext)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(encrypted, expected[len(fullKey):]) {
+		t.Fatalf("expected %x, got %x", expected[len(fullKey):], encrypted)
+	}
+
+	decrypted, err := NewRc4Codec(key, nonce).Decrypt(encrypted)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected %q, got %q", plaintext, decrypted)
+	}
+}
@@ -1,306 +1,131 @@
 package csv
 
-import (
-	"encoding/csv"
-	"fmt"
-	"io"
-	"log/slog"
-	"os"
-	"strconv"
-)
-
-var (
-	cardsCsv      map[int][]string = nil
-	skinsCsv      map[int][]string = nil
-	locationsCsv  map[int][]string = nil
-	charactersCsv map[int][]string = nil
-	thumbnailsCsv map[int][]string = nil
-)
-
-// --- Private methods --- //
-
-func loadCards() error {
-	if cardsCsv != nil {
-		return nil
-	}
-
-	slog.Info("loading cards.csv")
-
-	file, err := os.Open("assets/csv_logic/cards.csv")
-
-	if err != nil {
-		return err
-	}
-
-	defer file.Close()
-
-	cardsCsv = make(map[int][]string)
-
-	reader := csv.NewReader(file)
-	line := 0
-
-	for {
-		record, err_ := reader.Read()
-
-		if err_ != nil {
-			if err_ == io.EOF {
-				break
-			} else {
-				return fmt.Errorf("error during reading: %w\n", err_)
-			}
-		}
-
-		if line < 2 {
-			line++
-			continue
-		}
-
-		cardsCsv[line-2] = record
-
-		line++
-	}
-
-	return nil
+import "log/slog"
+
+type Card struct {
+	Name         string `csv:"Name"`
+	Target       string `csv:"Target" ref:"characters"`
+	RequiresCard string `csv:"RequiresCard" ref:"cards"`
+	Type         string `csv:"Type"`
+	Value        int32  `csv:"Value"`
+	Rarity       string `csv:"Rarity"`
 }
 
-func loadLocations() error {
-	if locationsCsv != nil {
-		return nil
-	}
-
-	slog.Info("loading locations.csv")
-
-	file, err := os.Open("assets/csv_logic/locations.csv")
-
-	if err != nil {
-		return err
-	}
-
-	defer file.Close()
-
-	locationsCsv = make(map[int][]string)
-
-	reader := csv.NewReader(file)
-	line := 0
-
-	for {
-		record, err_ := reader.Read()
-
-		if err_ != nil {
-			if err_ == io.EOF {
-				break
-			} else {
-				return fmt.Errorf("error during reading: %w\n", err_)
-			}
-		}
-
-		if line < 2 {
-			line++
-			continue
-		}
-
-		locationsCsv[line-2] = record
-
-		line++
-	}
-
-	return nil
+type Location struct {
+	Name        string `csv:"Name"`
+	GameMode    string `csv:"GameMode"`
+	AllowedMaps string `csv:"AllowedMaps"`
 }
 
-func loadCharacters() error {
-	if charactersCsv != nil {
-		return nil
-	}
-
-	slog.Info("loading characters.csv")
-
-	file, err := os.Open("assets/csv_logic/characters.csv")
-
-	if err != nil {
-		return err
-	}
+type Character struct {
+	Name        string `csv:"Name"`
+	Disabled    bool   `csv:"Disabled"`
+	Type        string `csv:"Type"`
+	DefaultSkin string `csv:"DefaultSkin" ref:"skins"`
 
-	defer file.Close()
-
-	charactersCsv = make(map[int][]string)
-
-	reader := csv.NewReader(file)
-	line := 0
-
-	for {
-		record, err_ := reader.Read()
-
-		if err_ != nil {
-			if err_ == io.EOF {
-				break
-			} else {
-				return fmt.Errorf("error during reading: %w\n", err_)
-			}
-		}
-
-		if line < 2 {
-			line++
-			continue
-		}
-
-		charactersCsv[line-2] = record
-
-		line++
-	}
-
-	return nil
+	WeaponSkill   string `csv:"WeaponSkill" ref:"skills"`
+	UltimateSkill string `csv:"UltimateSkill" ref:"skills"`
+	Pet           string `csv:"Pet" ref:"characters"`
 }
 
-func loadThumbnails() error {
-	if thumbnailsCsv != nil {
-		return nil
-	}
-
-	slog.Info("loading player_thumbnails.csv")
-
-	file, err := os.Open("assets/csv_logic/player_thumbnails.csv")
-
-	if err != nil {
-		return err
-	}
-
-	defer file.Close()
-
-	thumbnailsCsv = make(map[int][]string)
-
-	reader := csv.NewReader(file)
-	line := 0
-
-	for {
-		record, err_ := reader.Read()
-
-		if err_ != nil {
-			if err_ == io.EOF {
-				break
-			} else {
-				return fmt.Errorf("error during reading: %w\n", err_)
-			}
-		}
-
-		if line < 2 {
-			line++
-			continue
-		}
-
-		thumbnailsCsv[line-2] = record
-
-		line++
-	}
-
-	return nil
+type Thumbnail struct {
+	Name                  string `csv:"Name"`
+	RequiredExpLevel      int32  `csv:"RequiredExpLevel"`
+	RequiredTotalTrophies int32  `csv:"RequiredTotalTrophies"`
+	RequiredHero          string `csv:"RequiredHero" ref:"characters"`
 }
 
-func loadSkins() error {
-	if skinsCsv != nil {
-		return nil
-	}
+type Skin struct {
+	Name      string `csv:"Name"`
+	Character string `csv:"Character" ref:"characters"`
+	CostGems  int32  `csv:"CostGems"`
+}
 
-	slog.Info("loading skins.csv")
+// BillingPackage is a row of billing_packages.csv. Price is in US cents.
+type BillingPackage struct {
+	Id        int32
+	ProductId string `csv:"Name"`
+	Disabled  bool   `csv:"Disabled"`
 
-	file, err := os.Open("assets/csv_logic/skins.csv")
+	Diamonds int32 `csv:"Diamonds"`
+	Price    int32 `csv:"USD"`
+	Order    int32 `csv:"Order"`
 
-	if err != nil {
-		return err
-	}
+	// StarterPackNumber is set for the one-time starter packs, zero for gem packs.
+	StarterPackNumber int32 `csv:"StarterPackNumber"`
+}
 
-	defer file.Close()
+// --- Public methods --- //
 
-	skinsCsv = make(map[int][]string)
+func (d *GameData) CardTable() *Table[Card] {
+	return &d.cards
+}
 
-	reader := csv.NewReader(file)
-	line := 0
+func (d *GameData) LocationTable() *Table[Location] {
+	return &d.locations
+}
 
-	for {
-		record, err_ := reader.Read()
+func (d *GameData) CharacterTable() *Table[Character] {
+	return &d.characters
+}
 
-		if err_ != nil {
-			if err_ == io.EOF {
-				break
-			} else {
-				return fmt.Errorf("error during reading: %w\n", err_)
-			}
-		}
+func (d *GameData) ThumbnailTable() *Table[Thumbnail] {
+	return &d.thumbnails
+}
 
-		if line < 2 {
-			line++
-			continue
-		}
+func (d *GameData) SkinTable() *Table[Skin] {
+	return &d.skins
+}
 
-		skinsCsv[line-2] = record
+func (d *GameData) CardIds() []int {
+	temp := make([]int, 0, d.cards.Len())
 
-		line++
+	for id := range d.cards.All() {
+		temp = append(temp, int(id))
 	}
 
-	return nil
+	return temp
 }
 
-// --- Public methods --- //
-
-func CardIds() []int {
-	if cardsCsv == nil {
-		slog.Error("cards.csv has not been loaded yet!")
-		return make([]int, 0)
-	}
-
-	temp := make([]int, 0)
+// UnlockCardIds returns the cards that unlock a brawler, as opposed to the ones upgrading it.
+func (d *GameData) UnlockCardIds() []int32 {
+	temp := make([]int32, 0)
 
-	for id, _ := range cardsCsv {
-		temp = append(temp, id)
+	for id, row := range d.cards.All() {
+		if row.Type == "unlock" {
+			temp = append(temp, id)
+		}
 	}
 
 	return temp
 }
 
-func IsCardUnlocked(card int) bool {
-	if cardsCsv == nil {
-		slog.Error("cards.csv has not been loaded yet!")
-		return false
-	}
+func (d *GameData) IsCardUnlocked(card int) bool {
+	row, exists := d.cards.Get(int32(card))
 
-	for line, row := range cardsCsv {
-		if line != card {
-			continue
-		}
-
-		data := row[5]
-
-		return data == "unlock"
+	if !exists {
+		slog.Error("failed to find card!", "cardId", card)
+		return false
 	}
 
-	slog.Error("failed to find card!", "cardId", card)
-	return false
+	return row.Type == "unlock"
 }
 
-func GetCardUnlock(card int32) int32 {
-	if cardsCsv == nil {
-		slog.Error("cards.csv has not been loaded yet!")
+func (d *GameData) GetCardUnlock(card int32) int32 {
+	row, exists := d.cards.Get(card)
+
+	if !exists {
+		slog.Error("failed to find card unlock!", "cardId", card)
 		return 0
 	}
 
-	data := ""
-
-	for line, row := range cardsCsv {
-		if int32(line) != card {
-			continue
-		}
-
-		data = row[3]
-
-		if row[5] == "unlock" {
-			return int32(line)
-		}
-
-		break
+	if row.Type == "unlock" {
+		return card
 	}
 
-	for line, row := range cardsCsv {
-		if row[3] == data && row[5] == "unlock" {
-			return int32(line)
+	for id, other := range d.cards.All() {
+		if other.Target == row.Target && other.Type == "unlock" {
+			return id
 		}
 	}
 
@@ -308,131 +133,92 @@ func GetCardUnlock(card int32) int32 {
 	return 0
 }
 
-func GetBrawlerRarity(id int32) string {
-	if cardsCsv == nil {
-		slog.Error("cards.csv has not been loaded yet!")
-		return "common"
-	}
+func (d *GameData) GetBrawlerRarity(id int32) string {
+	row, exists := d.cards.Get(id + 1)
 
-	rarity := "common"
-
-	for line, row := range cardsCsv {
-		if int32(line) == id+1 {
-			rarity = row[10]
-			break
-		}
+	if !exists {
+		return "common"
 	}
 
-	return rarity
+	return row.Rarity
 }
 
-func GetBrawlersWithRarity(rarity string) []int32 {
-	if cardsCsv == nil {
-		slog.Error("cards.csv has not been loaded yet!")
-		return []int32{}
-	}
-
+func (d *GameData) GetBrawlersWithRarity(rarity string) []int32 {
 	brawlers := make([]int32, 0)
 
-	for line, row := range cardsCsv {
-		if row[5] == "unlock" && row[10] == rarity {
-			brawlers = append(brawlers, int32(line))
+	for id, row := range d.cards.All() {
+		if row.Type == "unlock" && row.Rarity == rarity {
+			brawlers = append(brawlers, id)
 		}
 	}
 
 	return brawlers
 }
 
-func GetBrawlerId(card int32) int32 {
-	if cardsCsv == nil {
-		slog.Error("cards.csv has not been loaded yet!")
-		return -1
-	}
-
+func (d *GameData) GetBrawlerId(card int32) int32 {
 	brawler := ""
 
-	for line, row := range cardsCsv {
-		if int32(line) == card {
-			brawler = row[3]
-			break
-		}
+	if row, exists := d.cards.Get(card); exists {
+		brawler = row.Target
 	}
 
 	return GetCharacterIdByName(brawler)
 }
 
-func LocationIds() []int32 {
-	if locationsCsv == nil {
-		slog.Error("locations.csv has not been loaded yet!")
-		return make([]int32, 0)
-	}
-
-	temp := make([]int32, 0)
+func (d *GameData) LocationIds() []int32 {
+	temp := make([]int32, 0, d.locations.Len())
 
-	for id, _ := range locationsCsv {
-		temp = append(temp, int32(id))
+	for id := range d.locations.All() {
+		temp = append(temp, id)
 	}
 
 	return temp
 }
 
-func GetLocationsByGamemode(gamemode string) []int32 {
-	if locationsCsv == nil {
-		slog.Error("locations.csv has not been loaded yet!")
-		return []int32{}
-	}
-
+func (d *GameData) GetLocationsByGamemode(gamemode string) []int32 {
 	ids := make([]int32, 0)
 
-	for line, row := range locationsCsv {
-		if row[11] == gamemode {
-			ids = append(ids, int32(line))
+	for id, row := range d.locations.All() {
+		if row.GameMode == gamemode {
+			ids = append(ids, id)
 		}
 	}
 
 	return ids
 }
 
-func GetCharacterIdByName(name string) int32 {
-	if charactersCsv == nil {
-		slog.Error("characters.csv has not been loaded yet!")
-		return 0
-	}
+func (d *GameData) GetGamemodeForLocation(id int32) (string, bool) {
+	row, exists := d.locations.Get(id)
 
-	for line, row := range charactersCsv {
-		if row[0] == name {
-			return int32(line)
-		}
+	if !exists {
+		return "", false
 	}
 
-	slog.Error("failed to find character!", "name", name, "err", "not found in file")
-	return 0
+	return row.GameMode, true
 }
 
-func GetCardForCharacter(charId int32) (int32, bool) {
-	if charactersCsv == nil || cardsCsv == nil {
-		slog.Error("characters.csv or cards.csv not loaded yet!")
-		return -1, false
-	}
-
-	charName := ""
-	charRow, charFound := charactersCsv[int(charId)]
+func (d *GameData) GetCharacterIdByName(name string) int32 {
+	_, id, exists := d.characters.ByName(name)
 
-	if !charFound || len(charRow) == 0 {
-		slog.Warn("character id not found in characters.csv", "charId", charId)
-		return -1, false
+	if !exists {
+		slog.Error("failed to find character!", "name", name, "err", "not found in file")
+		return 0
 	}
 
-	charName = charRow[0]
+	return id
+}
+
+func (d *GameData) GetCardForCharacter(charId int32) (int32, bool) {
+	charName := d.characters.Name(charId)
 
 	if charName == "" {
-		slog.Warn("character name is empty in characters.csv", "charId", charId)
+		slog.Warn("character id not found in characters.csv", "charId", charId)
 		return -1, false
 	}
 
-	for cardIdInt, cardRow := range cardsCsv {
-		if len(cardRow) > 5 && cardRow[5] == "unlock" && len(cardRow) > 3 && cardRow[3] == charName {
-			return int32(cardIdInt), true
+	for id, row := range d.cards.All() {
+		if row.Type == "unlock" && row.Target == charName {
+			return id, true
 		}
 	}
 
@@ -441,191 +227,142 @@ func GetCardForCharacter(charId int32) (int32, bool) {
 	return -1, false
 }
 
-func GetTrophiesForThumbnail(id int32) int32 {
-	if thumbnailsCsv == nil {
-		slog.Error("player_thumbnails.csv has not been loaded yet!")
-		return 0
-	}
-
-	for line, row := range thumbnailsCsv {
-		if int32(line) == id {
-			amount, err := strconv.Atoi(row[2])
+// GetUpgradeCardsForCharacter returns the hp and skill cards of a character, in instance id
+// order.
+func (d *GameData) GetUpgradeCardsForCharacter(charId int32) []int32 {
+	charName := d.characters.Name(charId)
+	cards := make([]int32, 0, 2)
 
-			if err != nil {
-				slog.Error("failed to convert trophies thumbnail to int!", "err", err)
-				return 0
-			}
+	if charName == "" {
+		return cards
+	}
 
-			return int32(amount)
+	for id, row := range d.cards.All() {
+		if row.Type != "unlock" && row.Target == charName {
+			cards = append(cards, id)
 		}
 	}
 
-	slog.Error("failed to find trophies for thumbnail!", "id", id, "err", "not found in file")
-	return 0
+	return cards
 }
 
-func GetBrawlerForThumbnail(id int32) string {
-	if thumbnailsCsv == nil {
-		slog.Error("player_thumbnails.csv has not been loaded yet!")
-		return ""
-	}
+func (d *GameData) GetTrophiesForThumbnail(id int32) int32 {
+	row, exists := d.thumbnails.Get(id)
 
-	for line, row := range thumbnailsCsv {
-		if int32(line) == id {
-			return row[4]
-		}
+	if !exists {
+		slog.Error("failed to find trophies for thumbnail!", "id", id, "err", "not found in file")
+		return 0
 	}
 
-	slog.Error("failed to find brawler for thumbnail!", "id", id, "err", "not found in file")
-	return ""
+	return row.RequiredTotalTrophies
 }
 
-func GetExperienceLevelForThumbnail(id int32) int32 {
-	if thumbnailsCsv == nil {
-		slog.Error("player_thumbnails.csv has not been loaded yet!")
-		return 0
-	}
+func (d *GameData) GetBrawlerForThumbnail(id int32) string {
+	row, exists := d.thumbnails.Get(id)
 
-	if int(id) > len(thumbnailsCsv) {
-		slog.Error("thumbnail out of range")
-		return 0
+	if !exists {
+		slog.Error("failed to find brawler for thumbnail!", "id", id, "err", "not found in file")
+		return ""
 	}
 
-	row, exists := thumbnailsCsv[int(id)]
+	return row.RequiredHero
+}
+
+func (d *GameData) GetExperienceLevelForThumbnail(id int32) int32 {
+	row, exists := d.thumbnails.Get(id)
 
 	if !exists {
 		slog.Error("thumbnail does not exist")
 		return 0
 	}
 
-	amount, err := strconv.Atoi(row[1])
-
-	if err != nil {
-		slog.Error("failed to convert thumbnail experience to int!", "err", err)
-		return 0
-	}
-
-	return int32(amount)
-
-	slog.Error("failed to find experience for thumbnail!", "id", id, "err", "not found in file")
-	return 0
+	return row.RequiredExpLevel
 }
 
-func Thumbnails() []int32 {
-	if thumbnailsCsv == nil {
-		slog.Error("player_thumbnail.csv has not been loaded yet!")
-		return make([]int32, 0)
-	}
-
-	temp := make([]int32, 0)
+func (d *GameData) Thumbnails() []int32 {
+	temp := make([]int32, 0, d.thumbnails.Len())
 
-	for id, _ := range thumbnailsCsv {
-		temp = append(temp, int32(id))
+	for id := range d.thumbnails.All() {
+		temp = append(temp, id)
 	}
 
 	return temp
 }
 
-func Skins() []int32 {
-	if skinsCsv == nil {
-		slog.Error("skins.csv has not been loaded yet!")
-		return make([]int32, 0)
-	}
+func (d *GameData) Skins() []int32 {
+	temp := make([]int32, 0, d.skins.Len())
 
-	temp := make([]int32, 0)
-
-	for id, _ := range skinsCsv {
-		temp = append(temp, int32(id))
+	for id := range d.skins.All() {
+		temp = append(temp, id)
 	}
 
 	return temp
 }
 
-func IsSkinDefault(id int32) bool {
-	if charactersCsv == nil {
-		slog.Error("characters.csv has not been loaded yet!")
-		return false
-	}
-
-	if skinsCsv == nil {
-		slog.Error("skins.csv has not been loaded yet!")
-		return false
-	}
-
-	defaultSkins := make(map[string]bool)
+func (d *GameData) IsSkinDefault(id int32) bool {
+	name := d.skins.Name(id)
 
-	for _, row := range charactersCsv {
-		defaultSkins[row[20]] = true
-	}
-
-	if int(id) > len(skinsCsv) {
+	if name == "" {
 		slog.Error("skin out of range")
 		return false
 	}
 
-	name := skinsCsv[int(id)][0]
-	_, e := defaultSkins[name]
+	for _, row := range d.characters.All() {
+		if row.DefaultSkin == name {
+			return true
+		}
+	}
 
-	return e
+	return false
 }
 
-func GetSkinPrice(id int32) int32 {
-	if skinsCsv == nil {
-		slog.Error("skins.csv has not been loaded yet!")
-		return 0
-	}
+func (d *GameData) GetSkinPrice(id int32) int32 {
+	row, exists := d.skins.Get(id)
 
-	if int(id) > len(skinsCsv) {
+	if !exists {
+		slog.Error("row does not exist!")
 		return 0
 	}
 
-	row, exists := skinsCsv[int(id)]
+	return row.CostGems
+}
+
+func (d *GameData) GetBrawlerForSkin(id int32) int32 {
+	row, exists := d.skins.Get(id)
 
 	if !exists {
-		slog.Error("row does not exist!")
+		slog.Error("skin does not exist")
 		return 0
 	}
 
-	price, err := strconv.Atoi(row[3])
+	_, characterId, exists := d.characters.ByName(row.Character)
 
-	if err != nil {
-		slog.Error("failed to convert to int!", "err", err)
+	if !exists {
 		return 0
 	}
 
-	return int32(price)
+	return characterId
 }
 
-func GetBrawlerForSkin(id int32) int32 {
-	if charactersCsv == nil {
-		slog.Error("characters.csv has not been loaded yet!")
-		return 0
-	}
+func (d *GameData) BillingPackageIds() []int32 {
+	ids := make([]int32, 0, d.billingPackages.Len())
 
-	if skinsCsv == nil {
-		slog.Error("skins.csv has not been loaded yet!")
-		return 0
+	for id := range d.billingPackages.All() {
+		ids = append(ids, id)
 	}
 
-	if int(id) > len(skinsCsv) {
-		slog.Error("skin out of range")
-		return 0
-	}
+	return ids
+}
 
-	row, exists := skinsCsv[int(id)]
+func (d *GameData) GetBillingPackage(id int32) (BillingPackage, bool) {
+	row, exists := d.billingPackages.Get(id)
 
 	if !exists {
-		slog.Error("skin does not exist")
-		return 0
+		return BillingPackage{}, false
 	}
 
-	character := row[1]
-
-	for i, c := range charactersCsv {
-		if c[0] == character {
-			return int32(i)
-		}
-	}
+	pack := *row
+	pack.Id = id
 
-	return 0
+	return pack, true
 }
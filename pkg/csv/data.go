@@ -0,0 +1,332 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync/atomic"
+)
+
+var current atomic.Pointer[GameData]
+
+// GameData is one consistent load of every csv table. It is never modified once built, a reload
+// builds a new one and swaps it in, so whoever holds on to a GameData keeps reading the same
+// tables for as long as they need them.
+type GameData struct {
+	dir     string
+	content *content
+
+	cards      Table[Card]
+	skins      Table[Skin]
+	locations  Table[Location]
+	characters Table[Character]
+	thumbnails Table[Thumbnail]
+
+	billingPackages Table[BillingPackage]
+
+	globals        Table[Global]
+	resources      Table[Resource]
+	regions        Table[Region]
+	maps           Table[Map]
+	skills         Table[Skill]
+	projectiles    Table[Projectile]
+	areaEffects    Table[AreaEffect]
+	items          Table[Item]
+	bosses         Table[Boss]
+	campaign       Table[CampaignLevel]
+	allianceBadges Table[AllianceBadge]
+}
+
+func init() {
+	current.Store(&GameData{})
+}
+
+// Data returns the current game data. It is empty until LoadAll succeeds.
+func Data() *GameData {
+	return current.Load()
+}
+
+// Dir returns the asset directory the game data was loaded from.
+func (d *GameData) Dir() string {
+	return d.dir
+}
+
+// --- Private methods --- //
+
+// buildData loads every table under dir. Every table is attempted so that a failed load reports
+// all broken files at once, and nothing is returned unless all of them load and every
+// reference resolves.
+func buildData(dir string) (*GameData, error) {
+	d := &GameData{dir: dir}
+
+	errs := []error{
+		loadTable(&d.cards, dir, "csv_logic/cards.csv"),
+		loadTable(&d.locations, dir, "csv_logic/locations.csv"),
+		loadTable(&d.characters, dir, "csv_logic/characters.csv"),
+		loadTable(&d.thumbnails, dir, "csv_logic/player_thumbnails.csv"),
+		loadTable(&d.skins, dir, "csv_logic/skins.csv"),
+		loadTable(&d.billingPackages, dir, "csv_client/billing_packages.csv"),
+		loadTable(&d.globals, dir, "csv_logic/globals.csv"),
+		loadTable(&d.resources, dir, "csv_logic/resources.csv"),
+		loadTable(&d.regions, dir, "csv_logic/regions.csv"),
+		loadTable(&d.maps, dir, "csv_logic/maps.csv"),
+		loadTable(&d.skills, dir, "csv_logic/skills.csv"),
+		loadTable(&d.projectiles, dir, "csv_logic/projectiles.csv"),
+		loadTable(&d.areaEffects, dir, "csv_logic/area_effects.csv"),
+		loadTable(&d.items, dir, "csv_logic/items.csv"),
+		loadTable(&d.bosses, dir, "csv_logic/bosses.csv"),
+		loadTable(&d.campaign, dir, "csv_logic/campaign.csv"),
+		loadTable(&d.allianceBadges, dir, "csv_logic/alliance_badges.csv"),
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := d.checkReferences(); err != nil {
+		return nil, fmt.Errorf("failed to check references: %w", err)
+	}
+
+	content, err := loadContent(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	d.content = content
+
+	return d, nil
+}
+
+// checkReferences makes sure every ref column names an existing row of its table.
+func (d *GameData) checkReferences() error {
+	tables := map[string]names{
+		"cards":            &d.cards,
+		"skins":            &d.skins,
+		"locations":        &d.locations,
+		"characters":       &d.characters,
+		"thumbnails":       &d.thumbnails,
+		"billing_packages": &d.billingPackages,
+		"globals":          &d.globals,
+		"resources":        &d.resources,
+		"regions":          &d.regions,
+		"maps":             &d.maps,
+		"skills":           &d.skills,
+		"projectiles":      &d.projectiles,
+		"area_effects":     &d.areaEffects,
+		"items":            &d.items,
+		"bosses":           &d.bosses,
+		"campaign":         &d.campaign,
+		"alliance_badges":  &d.allianceBadges,
+	}
+
+	errs := make([]error, 0)
+
+	for _, table := range tables {
+		errs = append(errs, table.checkReferences(tables))
+	}
+
+	return errors.Join(errs...)
+}
+
+// --- Helper functions --- //
+
+func loadTable[T any](table *Table[T], dir string, file string) error {
+	slog.Info("loading " + filepath.Base(file))
+
+	loaded, err := LoadTable[T](filepath.Join(dir, file))
+
+	if err != nil {
+		return err
+	}
+
+	*table = *loaded
+
+	return nil
+}
+
+// --- Current data --- //
+
+// The functions below read the current game data. A caller doing several lookups that must
+// agree with each other should keep the result of Data() instead.
+
+func CardTable() *Table[Card] {
+	return Data().CardTable()
+}
+
+func LocationTable() *Table[Location] {
+	return Data().LocationTable()
+}
+
+func CharacterTable() *Table[Character] {
+	return Data().CharacterTable()
+}
+
+func ThumbnailTable() *Table[Thumbnail] {
+	return Data().ThumbnailTable()
+}
+
+func SkinTable() *Table[Skin] {
+	return Data().SkinTable()
+}
+
+func CardIds() []int {
+	return Data().CardIds()
+}
+
+func IsCardUnlocked(card int) bool {
+	return Data().IsCardUnlocked(card)
+}
+
+func GetCardUnlock(card int32) int32 {
+	return Data().GetCardUnlock(card)
+}
+
+func GetBrawlerRarity(id int32) string {
+	return Data().GetBrawlerRarity(id)
+}
+
+func GetBrawlersWithRarity(rarity string) []int32 {
+	return Data().GetBrawlersWithRarity(rarity)
+}
+
+func GetBrawlerId(card int32) int32 {
+	return Data().GetBrawlerId(card)
+}
+
+func LocationIds() []int32 {
+	return Data().LocationIds()
+}
+
+func GetLocationsByGamemode(gamemode string) []int32 {
+	return Data().GetLocationsByGamemode(gamemode)
+}
+
+func GetGamemodeForLocation(id int32) (string, bool) {
+	return Data().GetGamemodeForLocation(id)
+}
+
+func GetCharacterIdByName(name string) int32 {
+	return Data().GetCharacterIdByName(name)
+}
+
+func GetCardForCharacter(charId int32) (int32, bool) {
+	return Data().GetCardForCharacter(charId)
+}
+
+func GetTrophiesForThumbnail(id int32) int32 {
+	return Data().GetTrophiesForThumbnail(id)
+}
+
+func GetBrawlerForThumbnail(id int32) string {
+	return Data().GetBrawlerForThumbnail(id)
+}
+
+func GetExperienceLevelForThumbnail(id int32) int32 {
+	return Data().GetExperienceLevelForThumbnail(id)
+}
+
+func Thumbnails() []int32 {
+	return Data().Thumbnails()
+}
+
+func Skins() []int32 {
+	return Data().Skins()
+}
+
+func IsSkinDefault(id int32) bool {
+	return Data().IsSkinDefault(id)
+}
+
+func GetSkinPrice(id int32) int32 {
+	return Data().GetSkinPrice(id)
+}
+
+func GetBrawlerForSkin(id int32) int32 {
+	return Data().GetBrawlerForSkin(id)
+}
+
+func BillingPackageIds() []int32 {
+	return Data().BillingPackageIds()
+}
+
+func GetBillingPackage(id int32) (BillingPackage, bool) {
+	return Data().GetBillingPackage(id)
+}
+
+func GlobalTable() *Table[Global] {
+	return Data().GlobalTable()
+}
+
+func ResourceTable() *Table[Resource] {
+	return Data().ResourceTable()
+}
+
+func RegionTable() *Table[Region] {
+	return Data().RegionTable()
+}
+
+func MapTable() *Table[Map] {
+	return Data().MapTable()
+}
+
+func SkillTable() *Table[Skill] {
+	return Data().SkillTable()
+}
+
+func ProjectileTable() *Table[Projectile] {
+	return Data().ProjectileTable()
+}
+
+func AreaEffectTable() *Table[AreaEffect] {
+	return Data().AreaEffectTable()
+}
+
+func ItemTable() *Table[Item] {
+	return Data().ItemTable()
+}
+
+func BossTable() *Table[Boss] {
+	return Data().BossTable()
+}
+
+func CampaignTable() *Table[CampaignLevel] {
+	return Data().CampaignTable()
+}
+
+func AllianceBadgeTable() *Table[AllianceBadge] {
+	return Data().AllianceBadgeTable()
+}
+
+func GetGlobalInt(name string) (int32, bool) {
+	return Data().GetGlobalInt(name)
+}
+
+func GetGlobalBool(name string) (bool, bool) {
+	return Data().GetGlobalBool(name)
+}
+
+func GetGlobalText(name string) (string, bool) {
+	return Data().GetGlobalText(name)
+}
+
+func GetGlobalNumbers(name string) []int32 {
+	return Data().GetGlobalNumbers(name)
+}
+
+func GetResourceCap(name string) int32 {
+	return Data().GetResourceCap(name)
+}
+
+func IsRegion(name string) bool {
+	return Data().IsRegion(name)
+}
+
+func IsAllianceBadge(id int32) bool {
+	return Data().IsAllianceBadge(id)
+}
+
+func GetMap(group string) []string {
+	return Data().GetMap(group)
+}
@@ -0,0 +1,163 @@
+package csv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// contentDirs are the directories under the asset directory that clients ship and patch.
+var contentDirs = []string{"csv_client", "csv_logic"}
+
+// Fingerprint lists the content files clients must have, in the fingerprint.json layout the
+// client reads. Sha identifies the whole set and is what clients report at login.
+type Fingerprint struct {
+	Files []FingerprintFile `json:"files"`
+	Sha   string            `json:"sha"`
+}
+
+type FingerprintFile struct {
+	File string `json:"file"`
+	Sha  string `json:"sha"`
+}
+
+// content is the fingerprint of a load together with its files, compressed for serving.
+type content struct {
+	fingerprint *Fingerprint
+	encoded     []byte
+	files       map[string][]byte
+}
+
+// Fingerprint returns the fingerprint of the content files the game data was loaded with.
+func (d *GameData) Fingerprint() *Fingerprint {
+	if d.content == nil {
+		return &Fingerprint{Files: []FingerprintFile{}}
+	}
+
+	return d.content.fingerprint
+}
+
+// FingerprintJson returns the fingerprint as served to clients.
+func (d *GameData) FingerprintJson() []byte {
+	if d.content == nil {
+		return []byte("{}")
+	}
+
+	return d.content.encoded
+}
+
+// CompressedFile returns a content file gzip compressed, by its path in the fingerprint.
+func (d *GameData) CompressedFile(file string) ([]byte, bool) {
+	if d.content == nil {
+		return nil, false
+	}
+
+	data, exists := d.content.files[file]
+
+	return data, exists
+}
+
+// --- Private methods --- //
+
+// loadContent reads and fingerprints every content file under dir. The set sha is taken over
+// the file list, so renaming a file changes it as much as editing one.
+func loadContent(dir string) (*content, error) {
+	c := &content{
+		fingerprint: &Fingerprint{Files: make([]FingerprintFile, 0)},
+		files:       make(map[string][]byte),
+	}
+
+	for _, contentDir := range contentDirs {
+		root := filepath.Join(dir, contentDir)
+
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+
+			data, err := os.ReadFile(path)
+
+			if err != nil {
+				return err
+			}
+
+			name, err := filepath.Rel(dir, path)
+
+			if err != nil {
+				return err
+			}
+
+			name = filepath.ToSlash(name)
+			sum := sha1.Sum(data)
+
+			c.fingerprint.Files = append(c.fingerprint.Files, FingerprintFile{File: name, Sha: hex.EncodeToString(sum[:])})
+
+			c.files[name], err = compress(data)
+
+			return err
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read content files in %s: %w", root, err)
+		}
+	}
+
+	slices.SortFunc(c.fingerprint.Files, func(a, b FingerprintFile) int {
+		if a.File < b.File {
+			return -1
+		}
+
+		if a.File > b.File {
+			return 1
+		}
+
+		return 0
+	})
+
+	set := sha1.New()
+
+	for _, file := range c.fingerprint.Files {
+		_, _ = fmt.Fprintf(set, "%s %s\n", file.File, file.Sha)
+	}
+
+	c.fingerprint.Sha = hex.EncodeToString(set.Sum(nil))
+
+	encoded, err := json.Marshal(c.fingerprint)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fingerprint: %w", err)
+	}
+
+	c.encoded = encoded
+
+	return c, nil
+}
+
+// --- Helper functions --- //
+
+func compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer, err := gzip.NewWriterLevel(&buffer, gzip.BestCompression)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -1,27 +1,132 @@
 package csv
 
-import "fmt"
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
 
+const defaultDir = "assets"
+
+var loadMu sync.Mutex
+
+// LoadAll loads the game data from the assets directory.
 func LoadAll() error {
-	if err := loadCards(); err != nil {
-		return fmt.Errorf("failed to load cards: %w\n", err)
+	return LoadDir(defaultDir)
+}
+
+// LoadDir loads the game data from dir and makes it current. Nothing is replaced if any table
+// fails to load.
+func LoadDir(dir string) error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	d, err := buildData(dir)
+
+	if err != nil {
+		return fmt.Errorf("failed to load game data from %s: %w", dir, err)
+	}
+
+	current.Store(d)
+
+	return nil
+}
+
+// Reload loads the game data again from the directory it was last loaded from. Players keep
+// playing on the old data until the new one is complete, and keep it if the reload fails.
+func Reload() error {
+	dir := dataDir()
+
+	if err := LoadDir(dir); err != nil {
+		return err
 	}
 
-	if err := loadLocations(); err != nil {
-		return fmt.Errorf("failed to load locations: %w\n", err)
+	slog.Info("reloaded game data", "dir", dir)
+
+	return nil
+}
+
+// Watch reloads the game data whenever a csv file under its directory changes, checking every
+// interval until stop is closed.
+func Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, err := fingerprint(dataDir())
+
+	if err != nil {
+		slog.Error("failed to read game data files!", "err", err)
 	}
 
-	if err := loadCharacters(); err != nil {
-		return fmt.Errorf("failed to load characters: %w\n", err)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		sum, err := fingerprint(dataDir())
+
+		if err != nil {
+			slog.Error("failed to read game data files!", "err", err)
+			continue
+		}
+
+		if sum == last {
+			continue
+		}
+
+		// a broken file is only reported once, the next change tries again
+		last = sum
+
+		if err = Reload(); err != nil {
+			slog.Error("failed to reload game data, keeping the old one!", "err", err)
+		}
 	}
+}
 
-	if err := loadThumbnails(); err != nil {
-		return fmt.Errorf("failed to load thumbnails: %w\n", err)
+// --- Helper functions --- //
+
+func dataDir() string {
+	if dir := Data().Dir(); dir != "" {
+		return dir
 	}
 
-	if err := loadSkins(); err != nil {
-		return fmt.Errorf("failed to load skins: %w\n", err)
+	return defaultDir
+}
+
+// fingerprint sums the names, sizes and modification times of the csv files under dir.
+func fingerprint(dir string) (string, error) {
+	hash := sha256.New()
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || !strings.HasSuffix(path, ".csv") {
+			return nil
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintf(hash, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+
+		return nil
+	})
+
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
@@ -0,0 +1,254 @@
+package csv
+
+// Global is a row of globals.csv. Array globals continue over the unnamed rows after them.
+type Global struct {
+	Name         string `csv:"Name"`
+	NumberValue  int32  `csv:"NumberValue"`
+	BooleanValue bool   `csv:"BooleanValue"`
+	TextValue    string `csv:"TextValue"`
+	StringArray  string `csv:"StringArray"`
+	NumberArray  int32  `csv:"NumberArray"`
+}
+
+// Resource is a row of resources.csv. A Cap of zero leaves the resource uncapped.
+type Resource struct {
+	Name            string `csv:"Name"`
+	Type            string `csv:"Type"`
+	Rarity          string `csv:"Rarity"`
+	PremiumCurrency bool   `csv:"PremiumCurrency"`
+	Cap             int32  `csv:"Cap"`
+}
+
+// Region is a row of regions.csv, either a country code or an area such as _EU.
+type Region struct {
+	Name        string `csv:"Name"`
+	DisplayName string `csv:"DisplayName"`
+	IsCountry   bool   `csv:"IsCountry"`
+}
+
+// Map is a row of maps.csv, one line of tiles. A map starts at the row naming its Group and
+// runs until the next one.
+type Map struct {
+	Name  string `csv:"CodeName"`
+	Group string `csv:"Group"`
+	Data  string `csv:"Data"`
+}
+
+type Skill struct {
+	Name              string `csv:"Name"`
+	BehaviorType      string `csv:"BehaviorType"`
+	Cooldown          int32  `csv:"Cooldown"`
+	ActiveTime        int32  `csv:"ActiveTime"`
+	CastingRange      int32  `csv:"CastingRange"`
+	RechargeTime      int32  `csv:"RechargeTime"`
+	MaxCharge         int32  `csv:"MaxCharge"`
+	Damage            int32  `csv:"Damage"`
+	Projectile        string `csv:"Projectile" ref:"projectiles"`
+	SummonedCharacter string `csv:"SummonedCharacter" ref:"characters"`
+	AreaEffectObject  string `csv:"AreaEffectObject" ref:"area_effects"`
+	SpawnedItem       string `csv:"SpawnedItem" ref:"items"`
+}
+
+type Projectile struct {
+	Name                  string `csv:"Name"`
+	Speed                 int32  `csv:"Speed"`
+	Radius                int32  `csv:"Radius"`
+	SpawnAreaEffectObject string `csv:"SpawnAreaEffectObject" ref:"area_effects"`
+	SpawnCharacter        string `csv:"SpawnCharacter" ref:"characters"`
+	SpawnItem             string `csv:"SpawnItem" ref:"items"`
+}
+
+type AreaEffect struct {
+	Name                  string `csv:"Name"`
+	Type                  string `csv:"Type"`
+	TimeMs                int32  `csv:"TimeMs"`
+	Radius                int32  `csv:"Radius"`
+	Damage                int32  `csv:"Damage"`
+	BulletExplosionBullet string `csv:"BulletExplosionBullet" ref:"projectiles"`
+}
+
+type Item struct {
+	Name              string `csv:"Name"`
+	Value             int32  `csv:"Value"`
+	Value2            int32  `csv:"Value2"`
+	TriggerAreaEffect string `csv:"TriggerAreaEffect" ref:"area_effects"`
+	CanBePickedUp     bool   `csv:"CanBePickedUp"`
+}
+
+// Boss is a row of bosses.csv. The file predates the current characters and maps, so its
+// columns are kept as plain names instead of references.
+type Boss struct {
+	Name                             string `csv:"Name"`
+	PlayerCount                      int32  `csv:"PlayerCount"`
+	RequiredCampaignProgressToUnlock int32  `csv:"RequiredCampaignProgressToUnlock"`
+	Reward                           string `csv:"Reward"`
+	Map                              string `csv:"Map"`
+	Boss                             string `csv:"Boss"`
+	BossLevel                        int32  `csv:"BossLevel"`
+}
+
+// CampaignLevel is a row of campaign.csv, which like bosses.csv names characters and maps that
+// no longer exist.
+type CampaignLevel struct {
+	Name          string `csv:"Name"`
+	Reward        string `csv:"Reward"`
+	Map           string `csv:"Map"`
+	Enemies       string `csv:"Enemies"`
+	EnemyLevel    int32  `csv:"EnemyLevel"`
+	Boss          string `csv:"Boss" ref:"characters"`
+	RequiredStars int32  `csv:"RequiredStars"`
+}
+
+type AllianceBadge struct {
+	Name     string `csv:"Name"`
+	Category string `csv:"Category"`
+}
+
+// --- Public methods --- //
+
+func (d *GameData) GlobalTable() *Table[Global] {
+	return &d.globals
+}
+
+func (d *GameData) ResourceTable() *Table[Resource] {
+	return &d.resources
+}
+
+func (d *GameData) RegionTable() *Table[Region] {
+	return &d.regions
+}
+
+func (d *GameData) MapTable() *Table[Map] {
+	return &d.maps
+}
+
+func (d *GameData) SkillTable() *Table[Skill] {
+	return &d.skills
+}
+
+func (d *GameData) ProjectileTable() *Table[Projectile] {
+	return &d.projectiles
+}
+
+func (d *GameData) AreaEffectTable() *Table[AreaEffect] {
+	return &d.areaEffects
+}
+
+func (d *GameData) ItemTable() *Table[Item] {
+	return &d.items
+}
+
+func (d *GameData) BossTable() *Table[Boss] {
+	return &d.bosses
+}
+
+func (d *GameData) CampaignTable() *Table[CampaignLevel] {
+	return &d.campaign
+}
+
+func (d *GameData) AllianceBadgeTable() *Table[AllianceBadge] {
+	return &d.allianceBadges
+}
+
+func (d *GameData) GetGlobalInt(name string) (int32, bool) {
+	global, exists := d.global(name)
+
+	if !exists {
+		return 0, false
+	}
+
+	return global.NumberValue, true
+}
+
+func (d *GameData) GetGlobalBool(name string) (bool, bool) {
+	global, exists := d.global(name)
+
+	if !exists {
+		return false, false
+	}
+
+	return global.BooleanValue, true
+}
+
+func (d *GameData) GetGlobalText(name string) (string, bool) {
+	global, exists := d.global(name)
+
+	if !exists {
+		return "", false
+	}
+
+	return global.TextValue, true
+}
+
+// GetGlobalNumbers returns the NumberArray of an array global.
+func (d *GameData) GetGlobalNumbers(name string) []int32 {
+	_, id, exists := d.globals.ByName(name)
+
+	if !exists {
+		return []int32{}
+	}
+
+	numbers := make([]int32, 0)
+
+	for next := id; int(next) < d.globals.Len(); next++ {
+		if next != id && d.globals.Name(next) != "" {
+			break
+		}
+
+		row, _ := d.globals.Get(next)
+		numbers = append(numbers, row.NumberArray)
+	}
+
+	return numbers
+}
+
+// GetResourceCap returns the most of a resource a player may hold, zero when it is uncapped.
+func (d *GameData) GetResourceCap(name string) int32 {
+	resource, _, exists := d.resources.ByName(name)
+
+	if !exists {
+		return 0
+	}
+
+	return resource.Cap
+}
+
+func (d *GameData) IsRegion(name string) bool {
+	return d.regions.Has(name)
+}
+
+func (d *GameData) IsAllianceBadge(id int32) bool {
+	_, exists := d.allianceBadges.Get(id)
+
+	return exists
+}
+
+// GetMap returns the tile lines of the map group, as named by the AllowedMaps of a location.
+func (d *GameData) GetMap(group string) []string {
+	lines := make([]string, 0)
+	inGroup := false
+
+	for _, row := range d.maps.All() {
+		if row.Group != "" {
+			if inGroup {
+				break
+			}
+
+			inGroup = row.Group == group
+		}
+
+		if inGroup {
+			lines = append(lines, row.Data)
+		}
+	}
+
+	return lines
+}
+
+// --- Helper functions --- //
+
+func (d *GameData) global(name string) (*Global, bool) {
+	row, _, exists := d.globals.ByName(name)
+
+	return row, exists
+}
@@ -0,0 +1,321 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Table is a csv file loaded into typed rows. The instance id of a row is its position after
+// the two header lines, the first column holds the row's name. Rows are mapped into T through
+// struct tags: csv names the column a field is read from, and ref names the table a string
+// column refers to by row name, which LoadAll checks once every table is loaded.
+//
+//	type Skin struct {
+//		Name      string `csv:"Name"`
+//		Character string `csv:"Character" ref:"characters"`
+//		CostGems  int32  `csv:"CostGems"`
+//	}
+type Table[T any] struct {
+	file string
+
+	rows   []T
+	names  []string
+	byName map[string]int32
+
+	references []reference
+}
+
+// reference is a cell that names a row of another table.
+type reference struct {
+	id     int32
+	column string
+	table  string
+	name   string
+}
+
+type column struct {
+	index int
+	field int
+	name  string
+	kind  reflect.Kind
+	ref   string
+}
+
+// names is what reference checks need from a table.
+type names interface {
+	Has(name string) bool
+	checkReferences(tables map[string]names) error
+}
+
+// LoadTable reads path into a table, failing when a tagged column is missing, its declared type
+// does not match the field or a cell cannot be parsed.
+func LoadTable[T any](path string) (*Table[T], error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+
+	header, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of %s: %w", path, err)
+	}
+
+	types, err := reader.Read()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read types of %s: %w", path, err)
+	}
+
+	columns, err := mapColumns(reflect.TypeFor[T](), header, types)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema for %s: %w", path, err)
+	}
+
+	t := &Table[T]{
+		file:   path,
+		byName: make(map[string]int32),
+	}
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		id := int32(len(t.rows))
+
+		var row T
+
+		value := reflect.ValueOf(&row).Elem()
+
+		for _, c := range columns {
+			cell := record[c.index]
+
+			if err = setCell(value.Field(c.field), c.kind, cell); err != nil {
+				return nil, fmt.Errorf("failed to parse %s row %d column %s: %w", path, id, c.name, err)
+			}
+
+			if c.ref != "" && cell != "" {
+				t.references = append(t.references, reference{id: id, column: c.name, table: c.ref, name: cell})
+			}
+		}
+
+		name := record[0]
+
+		if name != "" {
+			if previous, exists := t.byName[name]; exists {
+				return nil, fmt.Errorf("duplicate name %q in %s rows %d and %d", name, path, previous, id)
+			}
+
+			t.byName[name] = id
+		}
+
+		t.rows = append(t.rows, row)
+		t.names = append(t.names, name)
+	}
+
+	return t, nil
+}
+
+// Len returns the number of rows, instance ids run from 0 to Len()-1.
+func (t *Table[T]) Len() int {
+	return len(t.rows)
+}
+
+// Get returns the row with the given instance id. The row is shared and must not be modified.
+func (t *Table[T]) Get(id int32) (*T, bool) {
+	if id < 0 || int(id) >= len(t.rows) {
+		return nil, false
+	}
+
+	return &t.rows[id], true
+}
+
+// ByName returns the row with the given name and its instance id.
+func (t *Table[T]) ByName(name string) (*T, int32, bool) {
+	id, exists := t.byName[name]
+
+	if !exists {
+		return nil, -1, false
+	}
+
+	return &t.rows[id], id, true
+}
+
+func (t *Table[T]) Has(name string) bool {
+	_, exists := t.byName[name]
+	return exists
+}
+
+// Name returns the name of the row with the given instance id.
+func (t *Table[T]) Name(id int32) string {
+	if id < 0 || int(id) >= len(t.names) {
+		return ""
+	}
+
+	return t.names[id]
+}
+
+// All iterates over the rows in instance id order.
+func (t *Table[T]) All() iter.Seq2[int32, *T] {
+	return func(yield func(int32, *T) bool) {
+		for i := range t.rows {
+			if !yield(int32(i), &t.rows[i]) {
+				return
+			}
+		}
+	}
+}
+
+// --- Private methods --- //
+
+func (t *Table[T]) checkReferences(tables map[string]names) error {
+	errs := make([]error, 0)
+
+	for _, ref := range t.references {
+		target, exists := tables[ref.table]
+
+		if !exists {
+			errs = append(errs, fmt.Errorf("%s column %s refers to unknown table %s", t.file, ref.column, ref.table))
+			continue
+		}
+
+		if !target.Has(ref.name) {
+			errs = append(errs, fmt.Errorf("%s row %d column %s refers to missing %s row %q", t.file, ref.id, ref.column, ref.table, ref.name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// --- Helper functions --- //
+
+func mapColumns(rowType reflect.Type, header []string, types []string) ([]column, error) {
+	if rowType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("row type %s is not a struct", rowType)
+	}
+
+	if len(header) == 0 {
+		return nil, fmt.Errorf("no columns")
+	}
+
+	indices := make(map[string]int, len(header))
+
+	for i, name := range header {
+		indices[name] = i
+	}
+
+	columns := make([]column, 0, rowType.NumField())
+	errs := make([]error, 0)
+
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		name, tagged := field.Tag.Lookup("csv")
+
+		if !tagged {
+			continue
+		}
+
+		index, exists := indices[name]
+
+		if !exists {
+			errs = append(errs, fmt.Errorf("column %s of field %s is missing", name, field.Name))
+			continue
+		}
+
+		declared := ""
+
+		if index < len(types) {
+			declared = strings.ToLower(types[index])
+		}
+
+		kind := field.Type.Kind()
+
+		if !typeMatches(declared, kind) {
+			errs = append(errs, fmt.Errorf("column %s is declared %q, which field %s of kind %s cannot hold", name, declared, field.Name, kind))
+			continue
+		}
+
+		ref := field.Tag.Get("ref")
+
+		if ref != "" && kind != reflect.String {
+			errs = append(errs, fmt.Errorf("field %s refers to %s but is not a string", field.Name, ref))
+			continue
+		}
+
+		columns = append(columns, column{
+			index: index,
+			field: i,
+			name:  name,
+			kind:  kind,
+			ref:   ref,
+		})
+	}
+
+	return columns, errors.Join(errs...)
+}
+
+func typeMatches(declared string, kind reflect.Kind) bool {
+	switch declared {
+	case "string":
+		return kind == reflect.String
+	case "int":
+		return kind == reflect.Int || kind == reflect.Int32 || kind == reflect.Int64
+	case "boolean":
+		return kind == reflect.Bool
+	}
+
+	return false
+}
+
+func setCell(field reflect.Value, kind reflect.Kind, cell string) error {
+	switch kind {
+	case reflect.String:
+		field.SetString(cell)
+	case reflect.Bool:
+		if cell == "" {
+			return nil
+		}
+
+		v, err := strconv.ParseBool(strings.ToLower(cell))
+
+		if err != nil {
+			return err
+		}
+
+		field.SetBool(v)
+	default:
+		if cell == "" {
+			return nil
+		}
+
+		v, err := strconv.ParseInt(cell, 10, field.Type().Bits())
+
+		if err != nil {
+			return err
+		}
+
+		field.SetInt(v)
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package csv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testCharacter struct {
+	Name     string `csv:"Name"`
+	Disabled bool   `csv:"Disabled"`
+	Speed    int32  `csv:"Speed"`
+	Untagged string
+}
+
+type testSkin struct {
+	Name      string `csv:"Name"`
+	Character string `csv:"Character" ref:"characters"`
+	CostGems  int32  `csv:"CostGems"`
+}
+
+func TestLoadTable(t *testing.T) {
+	path := writeTable(t, `"Name","Disabled","Speed","Ignored"
+"String","Boolean","int","String"
+"Shelly",,720,"x"
+"Colt","TRUE",,"y"
+,,,`)
+
+	table, err := LoadTable[testCharacter](path)
+
+	if err != nil {
+		t.Fatalf("failed to load table: %v", err)
+	}
+
+	if table.Len() != 3 {
+		t.Fatalf("expected 3 rows, got %d", table.Len())
+	}
+
+	shelly, id, ok := table.ByName("Shelly")
+
+	if !ok || id != 0 || shelly.Speed != 720 || shelly.Disabled {
+		t.Fatalf("unexpected row %d %+v (%v)", id, shelly, ok)
+	}
+
+	colt, ok := table.Get(1)
+
+	if !ok || colt.Name != "Colt" || !colt.Disabled || colt.Speed != 0 {
+		t.Fatalf("unexpected row %+v (%v)", colt, ok)
+	}
+
+	if name := table.Name(2); name != "" || table.Has("") {
+		t.Fatalf("expected the unnamed row to have no name, got %q", name)
+	}
+
+	if _, ok = table.Get(3); ok {
+		t.Fatalf("expected no row past the end")
+	}
+
+	if _, _, ok = table.ByName("Bull"); ok {
+		t.Fatalf("expected no row named Bull")
+	}
+}
+
+func TestLoadTableSchemaErrors(t *testing.T) {
+	tables := []struct {
+		name     string
+		contents string
+		expected string
+	}{
+		{
+			name:     "MissingColumn",
+			contents: "\"Name\",\"Disabled\"\n\"String\",\"Boolean\"\n",
+			expected: "column Speed of field Speed is missing",
+		},
+		{
+			name:     "TypeMismatch",
+			contents: "\"Name\",\"Disabled\",\"Speed\"\n\"String\",\"Boolean\",\"String\"\n",
+			expected: `column Speed is declared "string", which field Speed of kind int32 cannot hold`,
+		},
+		{
+			name:     "BadCell",
+			contents: "\"Name\",\"Disabled\",\"Speed\"\n\"String\",\"Boolean\",\"int\"\n\"Shelly\",,fast\n",
+			expected: "row 0 column Speed",
+		},
+		{
+			name:     "DuplicateName",
+			contents: "\"Name\",\"Disabled\",\"Speed\"\n\"String\",\"Boolean\",\"int\"\n\"Shelly\",,1\n\"Shelly\",,2\n",
+			expected: `duplicate name "Shelly"`,
+		},
+	}
+
+	for _, vector := range tables {
+		t.Run(vector.name, func(t *testing.T) {
+			_, err := LoadTable[testCharacter](writeTable(t, vector.contents))
+
+			if err == nil || !strings.Contains(err.Error(), vector.expected) {
+				t.Fatalf("expected error containing %q, got %v", vector.expected, err)
+			}
+		})
+	}
+}
+
+func TestLoadTableRefMustBeString(t *testing.T) {
+	type badRef struct {
+		Character int32 `csv:"Character" ref:"characters"`
+	}
+
+	_, err := LoadTable[badRef](writeTable(t, "\"Name\",\"Character\"\n\"String\",\"int\"\n"))
+
+	if err == nil || !strings.Contains(err.Error(), "field Character refers to characters but is not a string") {
+		t.Fatalf("expected ref error, got %v", err)
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	characters, err := LoadTable[testCharacter](writeTable(t, "\"Name\",\"Disabled\",\"Speed\"\n\"String\",\"Boolean\",\"int\"\n\"Shelly\",,720\n"))
+
+	if err != nil {
+		t.Fatalf("failed to load characters: %v", err)
+	}
+
+	skins, err := LoadTable[testSkin](writeTable(t, `"Name","Character","CostGems"
+"String","String","int"
+"ShellyDefault","Shelly",0
+"BanditShelly","Shelly",30
+"Unowned",,0
+"ColtDefault","Colt",0`))
+
+	if err != nil {
+		t.Fatalf("failed to load skins: %v", err)
+	}
+
+	err = skins.checkReferences(map[string]names{"characters": characters})
+
+	if err == nil || !strings.Contains(err.Error(), `row 3 column Character refers to missing characters row "Colt"`) {
+		t.Fatalf("expected missing row error, got %v", err)
+	}
+
+	if strings.Count(err.Error(), "\n") != 0 {
+		t.Fatalf("expected only the Colt reference to fail, got %v", err)
+	}
+
+	err = skins.checkReferences(map[string]names{})
+
+	if err == nil || !strings.Contains(err.Error(), "refers to unknown table characters") {
+		t.Fatalf("expected unknown table error, got %v", err)
+	}
+
+	if err = characters.checkReferences(map[string]names{}); err != nil {
+		t.Fatalf("expected a table without references to pass, got %v", err)
+	}
+}
+
+func writeTable(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "table.csv")
+
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write table: %v", err)
+	}
+
+	return path
+}
@@ -0,0 +1,1014 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	AccountExportVersion = 1
+
+	deletedPlayerName = "Deleted"
+)
+
+type AccountExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+
+	Player      AccountPlayer      `json:"player"`
+	Progression AccountProgression `json:"progression"`
+
+	Brawlers []AccountBrawler  `json:"brawlers"`
+	Wallet   []AccountCurrency `json:"wallet"`
+
+	// BoxPity maps a rarity to the box rewards since the last brawler of it.
+	BoxPity map[int32]int32 `json:"box_pity,omitempty"`
+
+	OfferPurchases map[string]int32 `json:"offer_purchases,omitempty"`
+
+	MilestoneClaims []int32 `json:"milestone_claims,omitempty"`
+
+	Quests []AccountQuest `json:"quests,omitempty"`
+
+	Boosts []AccountBoost `json:"boosts,omitempty"`
+
+	// Credentials only holds hashes, the password and recovery code are never stored.
+	Credentials *AccountCredentials `json:"credentials,omitempty"`
+
+	// Purchases are kept so the receipts cannot be redeemed again on the importing server.
+	Purchases []AccountPurchase `json:"purchases,omitempty"`
+
+	SeasonHistory []AccountSeasonResult `json:"season_history,omitempty"`
+
+	Sanctions []AccountSanction `json:"sanctions,omitempty"`
+
+	Alliance *AccountAlliance `json:"alliance,omitempty"`
+}
+
+type AccountPlayer struct {
+	Name   string `json:"name"`
+	Token  string `json:"token"`
+	Region string `json:"region"`
+
+	HighId int32 `json:"high_id"`
+	LowId  int32 `json:"low_id"`
+
+	ProfileIcon int32 `json:"profile_icon"`
+
+	BattleHints   bool  `json:"battle_hints"`
+	ControlMode   int32 `json:"control_mode"`
+	TutorialState int32 `json:"tutorial_state"`
+
+	// CoinBooster and CoinDoubler are only set in exports made before boosts had their own
+	// table, they are turned into boosts on import.
+	CoinBooster int32 `json:"coin_booster,omitempty"`
+	CoinDoubler int32 `json:"coin_doubler,omitempty"`
+	CoinsReward int32 `json:"coins_reward"`
+
+	SelectedCardHigh int32 `json:"selected_card_high"`
+	SelectedCardLow  int32 `json:"selected_card_low"`
+
+	CreatedAt time.Time `json:"created_at"`
+	LastLogin time.Time `json:"last_login"`
+}
+
+type AccountProgression struct {
+	SoloVictories int32 `json:"solo_victories"`
+	DuoVictories  int32 `json:"duo_victories"`
+	TrioVictories int32 `json:"trio_victories"`
+
+	Trophies        int32 `json:"trophies"`
+	HighestTrophies int32 `json:"highest_trophies"`
+
+	Experience int32 `json:"experience"`
+}
+
+type AccountBrawler struct {
+	BrawlerId int32 `json:"brawler_id"`
+
+	Trophies        int32 `json:"trophies"`
+	HighestTrophies int32 `json:"highest_trophies"`
+
+	PowerLevel  int32 `json:"power_level"`
+	PowerPoints int32 `json:"power_points"`
+
+	SelectedGadget    *int32 `json:"selected_gadget"`
+	SelectedStarPower *int32 `json:"selected_star_power"`
+	SelectedGear1     *int32 `json:"selected_gear1"`
+	SelectedGear2     *int32 `json:"selected_gear2"`
+
+	UnlockedSkinIds []int32          `json:"unlocked_skins"`
+	Cards           map[string]int32 `json:"cards"`
+
+	SelectedSkinId int32 `json:"selected_skin"`
+
+	StarPowers []int32 `json:"star_powers"`
+	Gadgets    []int32 `json:"gadgets"`
+	Gears      []int32 `json:"gears"`
+
+	UnlockedAt time.Time `json:"unlocked_at"`
+}
+
+type AccountCurrency struct {
+	CurrencyId int32 `json:"currency_id"`
+	Balance    int64 `json:"balance"`
+}
+
+type AccountQuest struct {
+	Period     int32     `json:"period"`
+	Slot       int32     `json:"slot"`
+	TemplateId string    `json:"template_id"`
+	Progress   int32     `json:"progress"`
+	Claimed    bool      `json:"claimed"`
+	Rerolled   bool      `json:"rerolled"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+type AccountBoost struct {
+	Kind      int32      `json:"kind"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Remaining int32      `json:"remaining,omitempty"`
+}
+
+type AccountCredentials struct {
+	Username     *string `json:"username,omitempty"`
+	PasswordHash *string `json:"password_hash,omitempty"`
+
+	RecoverySelector *string `json:"recovery_selector,omitempty"`
+	RecoveryHash     *string `json:"recovery_hash,omitempty"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AccountPurchase struct {
+	ReceiptId   string    `json:"receipt_id"`
+	ProductId   string    `json:"product_id"`
+	CurrencyId  int32     `json:"currency_id"`
+	Amount      int32     `json:"amount"`
+	PurchasedAt time.Time `json:"purchased_at"`
+}
+
+// AccountSeasonResult is a brawler's trophy reset at the end of a season. Season ids differ
+// between servers, so the season is identified by when it started.
+type AccountSeasonResult struct {
+	SeasonStartedAt time.Time `json:"season_started_at"`
+	BrawlerId       int32     `json:"brawler_id"`
+	TrophiesBefore  int32     `json:"trophies_before"`
+	TrophiesAfter   int32     `json:"trophies_after"`
+	Payout          int32     `json:"payout"`
+	ResetAt         time.Time `json:"reset_at"`
+}
+
+type AccountSanction struct {
+	Kind      int16      `json:"kind"`
+	Reason    string     `json:"reason"`
+	Issuer    string     `json:"issuer"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+}
+
+type AccountAlliance struct {
+	Name     string    `json:"name"`
+	Role     int16     `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// ExportAccount collects every row that belongs to the player with the given ids
+// into a self-contained, versioned structure that can be imported on another server.
+func (m *Manager) ExportAccount(ctx context.Context, highId int32, lowId int32) (*AccountExport, error) {
+	conn, err := m.pool.Acquire(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	defer conn.Release()
+
+	export := &AccountExport{
+		Version:    AccountExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Brawlers:   make([]AccountBrawler, 0),
+		Wallet:     make([]AccountCurrency, 0),
+		BoxPity:    make(map[int32]int32),
+
+		OfferPurchases: make(map[string]int32),
+
+		MilestoneClaims: make([]int32, 0),
+		Quests:          make([]AccountQuest, 0),
+		Boosts:          make([]AccountBoost, 0),
+		Purchases:       make([]AccountPurchase, 0),
+		SeasonHistory:   make([]AccountSeasonResult, 0),
+		Sanctions:       make([]AccountSanction, 0),
+	}
+
+	p := &export.Player
+	pp := &export.Progression
+
+	var playerId int64
+
+	stmt := `
+		select
+			p.id, p.name, p.token, p.region, p.high_id, p.low_id, p.profile_icon,
+			p.battle_hints, p.control_mode, p.tutorial_state, p.coins_reward,
+			p.selected_card_high, p.selected_card_low, p.created_at, p.last_login,
+			pp.solo_victories, pp.duo_victories, pp.trio_victories,
+			pp.trophies, pp.highest_trophies, pp.experience
+		from players p
+		join player_progression pp on p.id = pp.player_id
+		where p.high_id = $1 and p.low_id = $2`
+
+	err = conn.QueryRow(ctx, stmt, highId, lowId).Scan(
+		&playerId, &p.Name, &p.Token, &p.Region, &p.HighId, &p.LowId, &p.ProfileIcon,
+		&p.BattleHints, &p.ControlMode, &p.TutorialState, &p.CoinsReward,
+		&p.SelectedCardHigh, &p.SelectedCardLow, &p.CreatedAt, &p.LastLogin,
+		&pp.SoloVictories, &pp.DuoVictories, &pp.TrioVictories,
+		&pp.Trophies, &pp.HighestTrophies, &pp.Experience,
+	)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w", ErrPlayerNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to query player: %w", err)
+	}
+
+	// brawlers
+	stmt = `
+		select
+			brawler_id, trophies, highest_trophies, power_level, power_points,
+			selected_gadget, selected_star_power, selected_gear1, selected_gear2,
+			unlocked_skins, selected_skin, cards, unlocked_at
+		from player_brawlers
+		where player_id = $1
+		order by brawler_id`
+
+	rows, err := conn.Query(ctx, stmt, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query brawlers for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		b := AccountBrawler{
+			StarPowers: make([]int32, 0),
+			Gadgets:    make([]int32, 0),
+			Gears:      make([]int32, 0),
+		}
+
+		err = rows.Scan(
+			&b.BrawlerId, &b.Trophies, &b.HighestTrophies, &b.PowerLevel, &b.PowerPoints,
+			&b.SelectedGadget, &b.SelectedStarPower, &b.SelectedGear1, &b.SelectedGear2,
+			&b.UnlockedSkinIds, &b.SelectedSkinId, &b.Cards, &b.UnlockedAt,
+		)
+
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan brawler for player %d: %w", playerId, err)
+		}
+
+		export.Brawlers = append(export.Brawlers, b)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating brawler rows for player %d: %w", playerId, err)
+	}
+
+	// unlocked star powers, gadgets and gears
+	unlockables := []struct {
+		Table  string
+		Column string
+		Target func(b *AccountBrawler) *[]int32
+	}{
+		{"player_unlocked_star_powers", "star_power_id", func(b *AccountBrawler) *[]int32 { return &b.StarPowers }},
+		{"player_unlocked_gadgets", "gadget_id", func(b *AccountBrawler) *[]int32 { return &b.Gadgets }},
+		{"player_unlocked_gears", "gear_id", func(b *AccountBrawler) *[]int32 { return &b.Gears }},
+	}
+
+	for _, u := range unlockables {
+		stmt = fmt.Sprintf("select brawler_id, %s from %s where player_id = $1 order by brawler_id, %s", u.Column, u.Table, u.Column)
+
+		rows, err = conn.Query(ctx, stmt, playerId)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s for player %d: %w", u.Table, playerId, err)
+		}
+
+		for rows.Next() {
+			var brawlerId, itemId int32
+
+			if err = rows.Scan(&brawlerId, &itemId); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s for player %d: %w", u.Table, playerId, err)
+			}
+
+			for i := range export.Brawlers {
+				if export.Brawlers[i].BrawlerId == brawlerId {
+					target := u.Target(&export.Brawlers[i])
+					*target = append(*target, itemId)
+					break
+				}
+			}
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating %s rows for player %d: %w", u.Table, playerId, err)
+		}
+	}
+
+	// wallet
+	rows, err = conn.Query(ctx, "select currency_id, balance from player_wallet where player_id = $1 order by currency_id", playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallet for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var c AccountCurrency
+
+		if err = rows.Scan(&c.CurrencyId, &c.Balance); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan wallet for player %d: %w", playerId, err)
+		}
+
+		export.Wallet = append(export.Wallet, c)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating wallet rows for player %d: %w", playerId, err)
+	}
+
+	// box pity
+	rows, err = conn.Query(ctx, "select rarity, misses from player_box_pity where player_id = $1", playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query box pity for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var rarity, misses int32
+
+		if err = rows.Scan(&rarity, &misses); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan box pity for player %d: %w", playerId, err)
+		}
+
+		export.BoxPity[rarity] = misses
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating box pity rows for player %d: %w", playerId, err)
+	}
+
+	// offer purchases
+	rows, err = conn.Query(ctx, "select offer_key, purchases from player_offer_purchases where player_id = $1", playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query offer purchases for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var key string
+		var purchases int32
+
+		if err = rows.Scan(&key, &purchases); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan offer purchases for player %d: %w", playerId, err)
+		}
+
+		export.OfferPurchases[key] = purchases
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating offer purchase rows for player %d: %w", playerId, err)
+	}
+
+	// milestone claims
+	rows, err = conn.Query(ctx, "select milestone_id from player_milestone_claims where player_id = $1 order by milestone_id", playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query milestone claims for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var milestoneId int32
+
+		if err = rows.Scan(&milestoneId); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan milestone claims for player %d: %w", playerId, err)
+		}
+
+		export.MilestoneClaims = append(export.MilestoneClaims, milestoneId)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating milestone claim rows for player %d: %w", playerId, err)
+	}
+
+	// quests
+	rows, err = conn.Query(ctx, `
+		select period, slot, template_id, progress, claimed, rerolled, assigned_at
+		from player_quests where player_id = $1 order by period, slot`, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quests for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var quest AccountQuest
+
+		if err = rows.Scan(&quest.Period, &quest.Slot, &quest.TemplateId, &quest.Progress, &quest.Claimed, &quest.Rerolled, &quest.AssignedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan quests for player %d: %w", playerId, err)
+		}
+
+		export.Quests = append(export.Quests, quest)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating quest rows for player %d: %w", playerId, err)
+	}
+
+	// boosts
+	rows, err = conn.Query(ctx, "select kind, expires_at, remaining from player_boosts where player_id = $1 order by kind", playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query boosts for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var boost AccountBoost
+
+		if err = rows.Scan(&boost.Kind, &boost.ExpiresAt, &boost.Remaining); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan boosts for player %d: %w", playerId, err)
+		}
+
+		export.Boosts = append(export.Boosts, boost)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating boost rows for player %d: %w", playerId, err)
+	}
+
+	// credentials
+	credentials := &AccountCredentials{}
+
+	err = conn.QueryRow(ctx, `
+		select username, password_hash, recovery_selector, recovery_hash, updated_at
+		from player_credentials where player_id = $1`, playerId).Scan(
+		&credentials.Username, &credentials.PasswordHash, &credentials.RecoverySelector, &credentials.RecoveryHash, &credentials.UpdatedAt,
+	)
+
+	if err == nil {
+		export.Credentials = credentials
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to query credentials for player %d: %w", playerId, err)
+	}
+
+	// purchases
+	rows, err = conn.Query(ctx, `
+		select receipt_id, product_id, currency_id, amount, purchased_at
+		from player_purchases where player_id = $1 order by purchased_at`, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchases for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var purchase AccountPurchase
+
+		if err = rows.Scan(&purchase.ReceiptId, &purchase.ProductId, &purchase.CurrencyId, &purchase.Amount, &purchase.PurchasedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan purchases for player %d: %w", playerId, err)
+		}
+
+		export.Purchases = append(export.Purchases, purchase)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating purchase rows for player %d: %w", playerId, err)
+	}
+
+	// season history
+	rows, err = conn.Query(ctx, `
+		select s.started_at, h.brawler_id, h.trophies_before, h.trophies_after, h.payout, h.reset_at
+		from player_season_history h
+		join seasons s on s.id = h.season_id
+		where h.player_id = $1
+		order by s.started_at, h.brawler_id`, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query season history for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var result AccountSeasonResult
+
+		if err = rows.Scan(&result.SeasonStartedAt, &result.BrawlerId, &result.TrophiesBefore, &result.TrophiesAfter, &result.Payout, &result.ResetAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan season history for player %d: %w", playerId, err)
+		}
+
+		export.SeasonHistory = append(export.SeasonHistory, result)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating season history rows for player %d: %w", playerId, err)
+	}
+
+	// sanctions
+	rows, err = conn.Query(ctx, `
+		select kind, reason, issuer, issued_at, expires_at, lifted_at
+		from player_sanctions where player_id = $1 order by issued_at`, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sanctions for player %d: %w", playerId, err)
+	}
+
+	for rows.Next() {
+		var sanction AccountSanction
+
+		if err = rows.Scan(&sanction.Kind, &sanction.Reason, &sanction.Issuer, &sanction.IssuedAt, &sanction.ExpiresAt, &sanction.LiftedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan sanctions for player %d: %w", playerId, err)
+		}
+
+		export.Sanctions = append(export.Sanctions, sanction)
+	}
+
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sanction rows for player %d: %w", playerId, err)
+	}
+
+	// alliance
+	stmt = `
+		select a.name, am.role, am.joined_at
+		from alliance_members am
+		join alliances a on a.id = am.alliance_id
+		where am.player_id = $1`
+
+	alliance := &AccountAlliance{}
+
+	err = conn.QueryRow(ctx, stmt, playerId).Scan(&alliance.Name, &alliance.Role, &alliance.JoinedAt)
+
+	if err == nil {
+		export.Alliance = alliance
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to query alliance membership for player %d: %w", playerId, err)
+	}
+
+	slog.Info("exported account", "playerId", playerId, "brawlers", len(export.Brawlers))
+
+	return export, nil
+}
+
+// ImportAccount recreates an exported account inside a single transaction. The player keeps
+// their ids and token, so the import fails if either is already taken on this server.
+// Alliance membership is restored only when an alliance with the same name exists.
+func (m *Manager) ImportAccount(ctx context.Context, export *AccountExport) (int64, error) {
+	if export.Version != AccountExportVersion {
+		return 0, fmt.Errorf("%w: got %d, expected %d", ErrUnsupportedExport, export.Version, AccountExportVersion)
+	}
+
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	p := export.Player
+	pp := export.Progression
+
+	var playerId int64
+
+	stmt := `
+		insert into players (
+			name, token, region, high_id, low_id, profile_icon,
+			battle_hints, control_mode, tutorial_state, coins_reward,
+			selected_card_high, selected_card_low, created_at, last_login
+		)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		returning id`
+
+	err = tx.QueryRow(ctx, stmt,
+		p.Name, p.Token, p.Region, p.HighId, p.LowId, p.ProfileIcon,
+		p.BattleHints, p.ControlMode, p.TutorialState, p.CoinsReward,
+		p.SelectedCardHigh, p.SelectedCardLow, p.CreatedAt, p.LastLogin,
+	).Scan(&playerId)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return 0, fmt.Errorf("%w: %v", ErrAccountAlreadyExists, pgErr.ConstraintName)
+		}
+
+		return 0, fmt.Errorf("failed to insert player: %w", err)
+	}
+
+	if _, err = tx.Exec(ctx, syncLowIdSequence); err != nil {
+		return 0, fmt.Errorf("failed to reset low id sequence: %w", err)
+	}
+
+	stmt = `
+		insert into player_progression (player_id, solo_victories, duo_victories, trio_victories, trophies, highest_trophies, experience)
+		values ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.Exec(ctx, stmt, playerId, pp.SoloVictories, pp.DuoVictories, pp.TrioVictories, pp.Trophies, pp.HighestTrophies, pp.Experience)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert progression for player %d: %w", playerId, err)
+	}
+
+	for _, b := range export.Brawlers {
+		skinsJson, err := json.Marshal(b.UnlockedSkinIds)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal skins of brawler %d: %w", b.BrawlerId, err)
+		}
+
+		cardsJson, err := json.Marshal(b.Cards)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal cards of brawler %d: %w", b.BrawlerId, err)
+		}
+
+		stmt = `
+			insert into player_brawlers (
+				player_id, brawler_id, trophies, highest_trophies,
+				power_level, power_points,
+				selected_gadget, selected_star_power, selected_gear1, selected_gear2,
+				unlocked_skins, selected_skin, cards, unlocked_at
+			)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+
+		_, err = tx.Exec(ctx, stmt,
+			playerId, b.BrawlerId, b.Trophies, b.HighestTrophies,
+			b.PowerLevel, b.PowerPoints,
+			b.SelectedGadget, b.SelectedStarPower, b.SelectedGear1, b.SelectedGear2,
+			skinsJson, b.SelectedSkinId, cardsJson, b.UnlockedAt,
+		)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert brawler %d for player %d: %w", b.BrawlerId, playerId, err)
+		}
+
+		for _, id := range b.StarPowers {
+			if _, err = tx.Exec(ctx, "insert into player_unlocked_star_powers (player_id, brawler_id, star_power_id) values ($1, $2, $3)", playerId, b.BrawlerId, id); err != nil {
+				return 0, fmt.Errorf("failed to insert star power %d for player %d: %w", id, playerId, err)
+			}
+		}
+
+		for _, id := range b.Gadgets {
+			if _, err = tx.Exec(ctx, "insert into player_unlocked_gadgets (player_id, brawler_id, gadget_id) values ($1, $2, $3)", playerId, b.BrawlerId, id); err != nil {
+				return 0, fmt.Errorf("failed to insert gadget %d for player %d: %w", id, playerId, err)
+			}
+		}
+
+		for _, id := range b.Gears {
+			if _, err = tx.Exec(ctx, "insert into player_unlocked_gears (player_id, brawler_id, gear_id) values ($1, $2, $3)", playerId, b.BrawlerId, id); err != nil {
+				return 0, fmt.Errorf("failed to insert gear %d for player %d: %w", id, playerId, err)
+			}
+		}
+	}
+
+	for _, c := range export.Wallet {
+		_, err = tx.Exec(ctx, "insert into player_wallet (player_id, currency_id, balance) values ($1, $2, $3)", playerId, c.CurrencyId, c.Balance)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert currency %d for player %d: %w", c.CurrencyId, playerId, err)
+		}
+	}
+
+	for rarity, misses := range export.BoxPity {
+		_, err = tx.Exec(ctx, "insert into player_box_pity (player_id, rarity, misses) values ($1, $2, $3)", playerId, rarity, misses)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert box pity %d for player %d: %w", rarity, playerId, err)
+		}
+	}
+
+	for key, purchases := range export.OfferPurchases {
+		_, err = tx.Exec(ctx, "insert into player_offer_purchases (player_id, offer_key, purchases) values ($1, $2, $3)", playerId, key, purchases)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert offer purchases %s for player %d: %w", key, playerId, err)
+		}
+	}
+
+	for _, milestoneId := range export.MilestoneClaims {
+		_, err = tx.Exec(ctx, "insert into player_milestone_claims (player_id, milestone_id) values ($1, $2)", playerId, milestoneId)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert milestone claim %d for player %d: %w", milestoneId, playerId, err)
+		}
+	}
+
+	for _, quest := range export.Quests {
+		_, err = tx.Exec(ctx, `
+			insert into player_quests (player_id, period, slot, template_id, progress, claimed, rerolled, assigned_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			playerId, quest.Period, quest.Slot, quest.TemplateId, quest.Progress, quest.Claimed, quest.Rerolled, quest.AssignedAt)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert quest %s for player %d: %w", quest.TemplateId, playerId, err)
+		}
+	}
+
+	boosts := export.Boosts
+
+	if expiresAt := time.Unix(int64(p.CoinBooster), 0); p.CoinBooster > 0 && expiresAt.After(time.Now()) {
+		boosts = append(boosts, AccountBoost{Kind: 1, ExpiresAt: &expiresAt})
+	}
+
+	if p.CoinDoubler > 0 {
+		boosts = append(boosts, AccountBoost{Kind: 2, Remaining: p.CoinDoubler})
+	}
+
+	for _, boost := range boosts {
+		_, err = tx.Exec(ctx, `
+			insert into player_boosts (player_id, kind, expires_at, remaining) values ($1, $2, $3, $4)
+			on conflict (player_id, kind) do nothing`,
+			playerId, boost.Kind, boost.ExpiresAt, boost.Remaining)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert boost %d for player %d: %w", boost.Kind, playerId, err)
+		}
+	}
+
+	if c := export.Credentials; c != nil {
+		_, err = tx.Exec(ctx, `
+			insert into player_credentials (player_id, username, password_hash, recovery_selector, recovery_hash, updated_at)
+			values ($1, $2, $3, $4, $5, $6)`,
+			playerId, c.Username, c.PasswordHash, c.RecoverySelector, c.RecoveryHash, c.UpdatedAt)
+
+		if err != nil {
+			var pgErr *pgconn.PgError
+
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return 0, fmt.Errorf("%w: %v", ErrUsernameTaken, pgErr.ConstraintName)
+			}
+
+			return 0, fmt.Errorf("failed to insert credentials for player %d: %w", playerId, err)
+		}
+	}
+
+	// a receipt already on this server is never granted again, it is only handed back to the
+	// account when its owner was deleted
+	for _, purchase := range export.Purchases {
+		_, err = tx.Exec(ctx, `
+			insert into player_purchases (receipt_id, player_id, product_id, currency_id, amount, purchased_at)
+			values ($1, $2, $3, $4, $5, $6)
+			on conflict (receipt_id) do update set player_id = excluded.player_id
+			where player_purchases.player_id is null`,
+			purchase.ReceiptId, playerId, purchase.ProductId, purchase.CurrencyId, purchase.Amount, purchase.PurchasedAt)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert purchase %s for player %d: %w", purchase.ReceiptId, playerId, err)
+		}
+	}
+
+	skippedSeasons := 0
+
+	for _, result := range export.SeasonHistory {
+		tag, err := tx.Exec(ctx, `
+			insert into player_season_history (season_id, player_id, brawler_id, trophies_before, trophies_after, payout, reset_at)
+			select id, $2, $3, $4, $5, $6, $7 from seasons where started_at = $1`,
+			result.SeasonStartedAt, playerId, result.BrawlerId, result.TrophiesBefore, result.TrophiesAfter, result.Payout, result.ResetAt)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert season history of brawler %d for player %d: %w", result.BrawlerId, playerId, err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			skippedSeasons++
+		}
+	}
+
+	if skippedSeasons > 0 {
+		slog.Warn("seasons from export do not exist, skipping their history", "playerId", playerId, "skipped", skippedSeasons)
+	}
+
+	for _, sanction := range export.Sanctions {
+		_, err = tx.Exec(ctx, `
+			insert into player_sanctions (player_id, kind, reason, issuer, issued_at, expires_at, lifted_at)
+			values ($1, $2, $3, $4, $5, $6, $7)`,
+			playerId, sanction.Kind, sanction.Reason, sanction.Issuer, sanction.IssuedAt, sanction.ExpiresAt, sanction.LiftedAt)
+
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert sanction for player %d: %w", playerId, err)
+		}
+	}
+
+	if export.Alliance != nil {
+		var allianceId int64
+
+		err = tx.QueryRow(ctx, "select id from alliances where name = $1", export.Alliance.Name).Scan(&allianceId)
+
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			slog.Warn("alliance from export does not exist, skipping membership", "alliance", export.Alliance.Name)
+		case err != nil:
+			return 0, fmt.Errorf("failed to look up alliance %s: %w", export.Alliance.Name, err)
+		default:
+			// imported players never take over leadership of an existing alliance
+			role := export.Alliance.Role
+
+			if role == 2 {
+				role = 4
+			}
+
+			_, err = tx.Exec(ctx, "insert into alliance_members (alliance_id, player_id, role, joined_at) values ($1, $2, $3, $4)", allianceId, playerId, role, export.Alliance.JoinedAt)
+
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert alliance membership: %w", err)
+			}
+
+			_, err = tx.Exec(ctx, "update alliances set total_trophies = total_trophies + $1 where id = $2", pp.Trophies, allianceId)
+
+			if err != nil {
+				return 0, fmt.Errorf("failed to increase alliance trophies: %w", err)
+			}
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit import for player %d: %w", playerId, err)
+	}
+
+	slog.Info("imported account", "playerId", playerId, "highId", p.HighId, "lowId", p.LowId)
+
+	return playerId, nil
+}
+
+// PlayerId returns the database id of the player with the given ids.
+func (m *Manager) PlayerId(ctx context.Context, highId int32, lowId int32) (int64, error) {
+	var playerId int64
+
+	err := m.pool.QueryRow(ctx, "select id from players where high_id = $1 and low_id = $2", highId, lowId).Scan(&playerId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w", ErrPlayerNotFound)
+		}
+
+		return 0, fmt.Errorf("failed to query player: %w", err)
+	}
+
+	return playerId, nil
+}
+
+// DeleteAccount permanently removes a player. Alliance messages written by or about the player
+// are kept for the other members but stripped of anything that identifies them, and if the
+// player led an alliance the highest ranked remaining member is promoted in their place.
+// Purchase receipts are kept without an owner, so they cannot be redeemed again.
+func (m *Manager) DeleteAccount(ctx context.Context, highId int32, lowId int32) error {
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var playerId int64
+	var trophies int32
+
+	err = tx.QueryRow(ctx, `
+		select p.id, pp.trophies
+		from players p
+		join player_progression pp on p.id = pp.player_id
+		where p.high_id = $1 and p.low_id = $2`, highId, lowId).Scan(&playerId, &trophies)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w", ErrPlayerNotFound)
+		}
+
+		return fmt.Errorf("failed to query player: %w", err)
+	}
+
+	var allianceId sql.NullInt64
+	var role sql.NullInt16
+
+	err = tx.QueryRow(ctx, "select alliance_id, role from alliance_members where player_id = $1", playerId).Scan(&allianceId, &role)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to query alliance membership: %w", err)
+	}
+
+	if allianceId.Valid {
+		if err = m.removeDeletedMember(ctx, tx, playerId, trophies, allianceId.Int64, role.Int16); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		update alliance_messages
+		set player_id = null, player_high_id = 0, player_low_id = 0, player_name = $2, player_icon = 0
+		where player_id = $1`, playerId, deletedPlayerName)
+
+	if err != nil {
+		return fmt.Errorf("failed to anonymize authored alliance messages: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "update alliance_messages set target_id = null, target_name = $2 where target_id = $1", playerId, deletedPlayerName)
+
+	if err != nil {
+		return fmt.Errorf("failed to anonymize targeted alliance messages: %w", err)
+	}
+
+	if _, err = tx.Exec(ctx, "delete from players where id = $1", playerId); err != nil {
+		return fmt.Errorf("failed to delete player: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit deletion of player %d: %w", playerId, err)
+	}
+
+	slog.Info("deleted account", "playerId", playerId, "highId", highId, "lowId", lowId)
+
+	return nil
+}
+
+func (m *Manager) removeDeletedMember(ctx context.Context, tx pgx.Tx, playerId int64, trophies int32, allianceId int64, role int16) error {
+	_, err := tx.Exec(ctx, "delete from alliance_members where player_id = $1", playerId)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete alliance membership: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "update alliances set total_trophies = greatest(total_trophies - $1, 0) where id = $2", trophies, allianceId)
+
+	if err != nil {
+		return fmt.Errorf("failed to decrease alliance trophies: %w", err)
+	}
+
+	var members int32
+
+	if err = tx.QueryRow(ctx, "select count(*) from alliance_members where alliance_id = $1", allianceId).Scan(&members); err != nil {
+		return fmt.Errorf("failed to count members: %w", err)
+	}
+
+	if members == 0 {
+		return m.DeleteAlliance(ctx, allianceId, tx)
+	}
+
+	if role != 2 {
+		return nil
+	}
+
+	// co-leaders first, then elders, then regular members; trophies break ties
+	stmt := `
+		update alliance_members set role = 2
+		where player_id = (
+			select am.player_id
+			from alliance_members am
+			join player_progression pp on pp.player_id = am.player_id
+			where am.alliance_id = $1
+			order by case am.role when 4 then 3 when 3 then 2 else 1 end desc, pp.trophies desc
+			limit 1
+		)`
+
+	if _, err = tx.Exec(ctx, stmt, allianceId); err != nil {
+		return fmt.Errorf("failed to promote new alliance leader: %w", err)
+	}
+
+	slog.Info("promoted new alliance leader after account deletion", "allianceId", allianceId)
+
+	return nil
+}
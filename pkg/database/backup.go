@@ -0,0 +1,234 @@
+package database
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	BackupFormat  = "sps-backup"
+	BackupVersion = 1
+
+	backupManifestName = "manifest.json"
+)
+
+type BackupManifest struct {
+	Format        string    `json:"format"`
+	Version       int       `json:"version"`
+	SchemaVersion int       `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Tables        []string  `json:"tables"`
+}
+
+// Backup writes a gzip-compressed tar archive holding a manifest and one csv COPY dump per
+// table in schema. All tables are read from the same repeatable read snapshot.
+func (m *Manager) Backup(ctx context.Context, w io.Writer) (*BackupManifest, error) {
+	tx, err := m.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	manifest := &BackupManifest{
+		Format:        BackupFormat,
+		Version:       BackupVersion,
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Tables:        make([]string, 0, len(schema)),
+	}
+
+	for _, entry := range schema {
+		manifest.Tables = append(manifest.Tables, entry.Table)
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err = writeArchiveEntry(archive, backupManifestName, manifestJson, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range schema {
+		var buf bytes.Buffer
+
+		stmt := fmt.Sprintf("copy %s to stdout with (format csv, header true)", entry.Table)
+
+		tag, err := tx.Conn().PgConn().CopyTo(ctx, &buf, stmt)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", entry.Table, err)
+		}
+
+		if err = writeArchiveEntry(archive, entry.Table+".csv", buf.Bytes(), manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		slog.Info("backed up table", "table", entry.Table, "rows", tag.RowsAffected())
+	}
+
+	if err = archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	if err = gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish compression: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Restore replaces the contents of every table with the data from a Backup archive. The whole
+// restore runs in one transaction, so a failure at any point leaves the database untouched.
+func (m *Manager) Restore(ctx context.Context, r io.Reader) (*BackupManifest, error) {
+	gz, err := gzip.NewReader(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedBackup, err)
+	}
+
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+
+	var manifest *BackupManifest
+	dumps := make(map[string][]byte)
+
+	for {
+		header, err := archive.Next()
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		data, err := io.ReadAll(archive)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+		}
+
+		if header.Name == backupManifestName {
+			manifest = &BackupManifest{}
+
+			if err = json.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("%w: invalid manifest: %v", ErrUnsupportedBackup, err)
+			}
+
+			continue
+		}
+
+		dumps[strings.TrimSuffix(header.Name, ".csv")] = data
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: missing manifest", ErrUnsupportedBackup)
+	}
+
+	if manifest.Format != BackupFormat || manifest.Version != BackupVersion {
+		return nil, fmt.Errorf("%w: format %s v%d", ErrUnsupportedBackup, manifest.Format, manifest.Version)
+	}
+
+	if manifest.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("%w: schema version %d, server is at %d", ErrUnsupportedBackup, manifest.SchemaVersion, SchemaVersion)
+	}
+
+	for _, entry := range schema {
+		if _, exists := dumps[entry.Table]; !exists {
+			return nil, fmt.Errorf("%w: missing table %s", ErrUnsupportedBackup, entry.Table)
+		}
+	}
+
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tables := make([]string, 0, len(schema))
+
+	for _, entry := range schema {
+		tables = append(tables, entry.Table)
+	}
+
+	if _, err = tx.Exec(ctx, "truncate "+strings.Join(tables, ", ")+" restart identity cascade"); err != nil {
+		return nil, fmt.Errorf("failed to truncate tables: %w", err)
+	}
+
+	for _, entry := range schema {
+		stmt := fmt.Sprintf("copy %s from stdin with (format csv, header true)", entry.Table)
+
+		tag, err := tx.Conn().PgConn().CopyFrom(ctx, bytes.NewReader(dumps[entry.Table]), stmt)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", entry.Table, err)
+		}
+
+		if entry.Serial != "" {
+			stmt = fmt.Sprintf(
+				"select setval(pg_get_serial_sequence('%[1]s', '%[2]s'), coalesce(max(%[2]s), 1), max(%[2]s) is not null) from %[1]s",
+				entry.Table, entry.Serial,
+			)
+
+			if _, err = tx.Exec(ctx, stmt); err != nil {
+				return nil, fmt.Errorf("failed to reset sequence of %s: %w", entry.Table, err)
+			}
+		}
+
+		slog.Info("restored table", "table", entry.Table, "rows", tag.RowsAffected())
+	}
+
+	if _, err = tx.Exec(ctx, syncLowIdSequence); err != nil {
+		return nil, fmt.Errorf("failed to reset low id sequence: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// --- Helper functions --- //
+
+func writeArchiveEntry(archive *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write archive header for %s: %w", name, err)
+	}
+
+	if _, err := archive.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Credentials are what a player can prove they own an account with, besides its token. Only
+// hashes are stored, the recovery selector is the public half of a recovery code that finds
+// the account it belongs to.
+type Credentials struct {
+	PlayerId int64 `db:"player_id"`
+	HighId   int32 `db:"high_id"`
+	LowId    int32 `db:"low_id"`
+
+	Username     *string `db:"username"`
+	PasswordHash *string `db:"password_hash"`
+
+	RecoverySelector *string `db:"recovery_selector"`
+	RecoveryHash     *string `db:"recovery_hash"`
+}
+
+// SetPassword binds a username and password to a player, replacing the ones they had.
+func (m *Manager) SetPassword(ctx context.Context, playerId int64, username string, passwordHash string) error {
+	_, err := m.pool.Exec(ctx, `
+		insert into player_credentials (player_id, username, password_hash) values ($1, $2, $3)
+		on conflict (player_id) do update
+		set username = excluded.username, password_hash = excluded.password_hash, updated_at = current_timestamp`,
+		playerId, username, passwordHash)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+		}
+
+		return fmt.Errorf("failed to set password for player %d: %w", playerId, err)
+	}
+
+	return nil
+}
+
+// SetRecoveryCode binds a recovery code to a player, replacing the one they had.
+func (m *Manager) SetRecoveryCode(ctx context.Context, playerId int64, selector string, codeHash string) error {
+	_, err := m.pool.Exec(ctx, `
+		insert into player_credentials (player_id, recovery_selector, recovery_hash) values ($1, $2, $3)
+		on conflict (player_id) do update
+		set recovery_selector = excluded.recovery_selector, recovery_hash = excluded.recovery_hash, updated_at = current_timestamp`,
+		playerId, selector, codeHash)
+
+	if err != nil {
+		return fmt.Errorf("failed to set recovery code for player %d: %w", playerId, err)
+	}
+
+	return nil
+}
+
+func (m *Manager) CredentialsByUsername(ctx context.Context, username string) (*Credentials, error) {
+	return m.queryCredentials(ctx, "c.username = $1", username)
+}
+
+func (m *Manager) CredentialsByRecoverySelector(ctx context.Context, selector string) (*Credentials, error) {
+	return m.queryCredentials(ctx, "c.recovery_selector = $1", selector)
+}
+
+// RotateToken gives a player a new token, after which the old one no longer logs in. A used
+// recovery code is cleared at the same time, as it is only good for one transfer.
+func (m *Manager) RotateToken(ctx context.Context, playerId int64, token string, clearRecovery bool) error {
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, "update players set token = $2 where id = $1", playerId, token)
+
+	if err != nil {
+		return fmt.Errorf("failed to rotate token of player %d: %w", playerId, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w", ErrPlayerNotFound)
+	}
+
+	if clearRecovery {
+		_, err = tx.Exec(ctx, `
+			update player_credentials set recovery_selector = null, recovery_hash = null, updated_at = current_timestamp
+			where player_id = $1`, playerId)
+
+		if err != nil {
+			return fmt.Errorf("failed to clear recovery code of player %d: %w", playerId, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit token rotation: %w", err)
+	}
+
+	slog.Info("rotated player token", "playerId", playerId)
+
+	return nil
+}
+
+// --- Private methods --- //
+
+func (m *Manager) queryCredentials(ctx context.Context, condition string, arg any) (*Credentials, error) {
+	rows, err := m.pool.Query(ctx, `
+		select c.player_id, p.high_id, p.low_id, c.username, c.password_hash, c.recovery_selector, c.recovery_hash
+		from player_credentials c
+		join players p on p.id = c.player_id
+		where `+condition, arg)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query credentials: %w", err)
+	}
+
+	credentials, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Credentials])
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w", ErrCredentialsNotFound)
+		}
+
+		return nil, fmt.Errorf("failed to collect credentials: %w", err)
+	}
+
+	return credentials, nil
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/core"
 	"log/slog"
-	"os"
+	"sync/atomic"
 	"time"
 )
 
@@ -39,10 +39,13 @@ type LeaderboardAllianceEntry struct {
 
 type Manager struct {
 	pool *pgxpool.Pool
+
+	// seasonEnd is the unix time the current season ends, kept up to date by SeasonScheduler.
+	seasonEnd atomic.Int64
 }
 
 func NewManager() (*Manager, error) {
-	pool, err := pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+	pool, err := pgxpool.New(context.Background(), config.Get().Server.DatabaseUrl)
 
 	if err != nil {
 		return nil, fmt.Errorf("unable to create database pool: %v", err)
@@ -75,28 +78,18 @@ func (m *Manager) CreateDefault() error {
 
 	defer tx.Rollback(ctx)
 
-	ddlStatements := []struct {
-		Name string
-		Stmt string
-	}{
-		{"players table", players},
-		{"player progression table", playerProgression},
-		{"player brawlers table", playerBrawlers},
-		{"player unlocked star powers table", playerUnlockedStarPowers},
-		{"player unlocked star gadgets table", playerUnlockedGadgets},
-		{"player unlocked star gears table", playerUnlockedGears},
-		{"player wallet table", playerWallet},
-		{"alliances", alliances},
-		{"alliance members", allianceMembers},
-		{"alliance messages", allianceMessages},
-	}
-
-	for _, entry := range ddlStatements {
+	for _, entry := range schema {
 		if _, err = tx.Exec(ctx, entry.Stmt); err != nil {
 			return fmt.Errorf("could not create %s: %v", entry.Name, err)
 		}
 	}
 
+	for _, migration := range migrations {
+		if _, err = tx.Exec(ctx, migration.Stmt); err != nil {
+			return fmt.Errorf("could not migrate %s: %v", migration.Name, err)
+		}
+	}
+
 	err = tx.Commit(ctx)
 
 	if err != nil {
@@ -108,7 +101,49 @@ func (m *Manager) CreateDefault() error {
 	return nil
 }
 
-func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, name string, token string, region string) (*core.Player, error) {
+// MigrateLegacyPowerLevels gives brawlers levelled with hp and skill cards the power level those
+// cards stood for. It needs the unlock cards of the loaded game data and only ever runs once,
+// the run is recorded in schema_migrations.
+func (m *Manager) MigrateLegacyPowerLevels(ctx context.Context, unlockCards []int32) error {
+	if len(unlockCards) == 0 {
+		return fmt.Errorf("%w: no unlock cards", ErrMissingGameData)
+	}
+
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		insert into schema_migrations (name, schema_version) values ('brawler power levels', $1)
+		on conflict (name) do nothing`, SchemaVersion)
+
+	if err != nil {
+		return fmt.Errorf("failed to record power level migration: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if tag, err = tx.Exec(ctx, legacyPowerLevels, unlockCards); err != nil {
+		return fmt.Errorf("failed to migrate power levels: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit power level migration: %w", err)
+	}
+
+	slog.Info("migrated legacy power levels", "brawlers", tag.RowsAffected())
+
+	return nil
+}
+
+// CreatePlayer creates an account with the next free low id and the high id of this server.
+func (m *Manager) CreatePlayer(ctx context.Context, name string, token string, region string) (*core.Player, error) {
 	tx, err := m.pool.Begin(ctx)
 
 	if err != nil {
@@ -117,15 +152,20 @@ func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, n
 
 	defer tx.Rollback(ctx)
 
+	cfg := config.Get()
+
 	var newPlayerId int64
+	var lowId int32
 	var createdAt time.Time
 
+	highId := cfg.Server.HighId
+
 	playerInsertSQL := `
 		insert into players (name, token, high_id, low_id, region, last_login)
-		values ($1, $2, $3, $4, $5, current_timestamp)
-		returning id, created_at`
+		values ($1, $2, $3, nextval('player_low_id_seq'), $4, current_timestamp)
+		returning id, low_id, created_at`
 
-	err = tx.QueryRow(ctx, playerInsertSQL, name, token, highId, lowId, region).Scan(&newPlayerId, &createdAt)
+	err = tx.QueryRow(ctx, playerInsertSQL, name, token, highId, region).Scan(&newPlayerId, &lowId, &createdAt)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -141,7 +181,7 @@ func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, n
 		insert into player_progression (player_id, trophies, highest_trophies, solo_victories, duo_victories, trio_victories)
 		values ($1, $2, $2, 0, 0, 0)`
 
-	_, err = tx.Exec(ctx, progressionInsertSQL, newPlayerId, config.NewPlayerTrophies)
+	_, err = tx.Exec(ctx, progressionInsertSQL, newPlayerId, cfg.Gameplay.NewPlayerTrophies)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert player progression for player %d: %w", newPlayerId, err)
@@ -151,7 +191,7 @@ func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, n
 	newPlayerWallet := make(map[int32]*core.PlayerCurrency)
 
 	for _, currencyId := range config.DefaultCurrencies {
-		balance := config.DefaultCurrencyBalance[currencyId]
+		balance := cfg.Economy.StartingCurrencies[currencyId]
 
 		_, err = tx.Exec(ctx, walletInsertSQL, newPlayerId, currencyId, balance)
 
@@ -182,7 +222,7 @@ func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, n
 	}
 
 	startingBrawler := &core.PlayerBrawler{
-		BrawlerId:         config.NewPlayerStartingBrawlerId,
+		BrawlerId:         cfg.Gameplay.NewPlayerStartingBrawlerId,
 		Trophies:          0,
 		HighestTrophies:   0,
 		PowerLevel:        1,
@@ -206,14 +246,14 @@ func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, n
 
 	_, err = tx.Exec(ctx, brawlerInsertSQL,
 		newPlayerId,
-		config.NewPlayerStartingBrawlerId,
+		cfg.Gameplay.NewPlayerStartingBrawlerId,
 		defaultUnlockedSkinsJson,
 		defaultSkinId,
 		defaultBrawlerCards,
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert starting brawler %d for player %d: %w", config.NewPlayerStartingBrawlerId, newPlayerId, err)
+		return nil, fmt.Errorf("failed to insert starting brawler %d for player %d: %w", cfg.Gameplay.NewPlayerStartingBrawlerId, newPlayerId, err)
 	}
 
 	err = tx.Commit(ctx)
@@ -234,23 +274,22 @@ func (m *Manager) CreatePlayer(ctx context.Context, highId int32, lowId int32, n
 	player.LastLogin = createdAt
 	player.ProfileIcon = 0
 
-	player.Trophies = config.NewPlayerTrophies
-	player.HighestTrophies = config.NewPlayerTrophies
+	player.Trophies = cfg.Gameplay.NewPlayerTrophies
+	player.HighestTrophies = cfg.Gameplay.NewPlayerTrophies
 	player.SoloVictories = 0
 	player.DuoVictories = 0
 	player.TrioVictories = 0
 
 	player.BattleHints = false
 	player.ControlMode = 0
-	player.CoinBooster = 0
 
 	player.Wallet = newPlayerWallet
 
-	player.Brawlers[config.NewPlayerStartingBrawlerId] = startingBrawler
+	player.Brawlers[cfg.Gameplay.NewPlayerStartingBrawlerId] = startingBrawler
 
 	player.SetState(core.StateSession)
 
-	slog.Info("created player", "id", newPlayerId, "name", name)
+	slog.Info("created player", "id", newPlayerId, "highId", highId, "lowId", lowId, "name", name)
 
 	return player, nil
 }
@@ -272,7 +311,7 @@ func (m *Manager) LoadPlayerByIds(ctx context.Context, high int32, low int32) (*
 	// core/progression
 	stmt := `
 		select
-			p.id, p.control_mode, p.battle_hints, p.coin_booster, p.high_id, p.low_id, p.name, p.region, p.profile_icon, p.created_at, p.last_login, p.tutorial_state, p.coins_reward, p.coin_doubler, p.selected_card_high, p.selected_card_low,
+			p.id, p.control_mode, p.battle_hints, p.high_id, p.low_id, p.name, p.region, p.profile_icon, p.created_at, p.last_login, p.tutorial_state, p.coins_reward, p.selected_card_high, p.selected_card_low,
 			pp.trophies, pp.highest_trophies, pp.solo_victories, pp.duo_victories, pp.trio_victories, pp.experience,
 			am.alliance_id, am.role
 		from players p
@@ -281,7 +320,7 @@ func (m *Manager) LoadPlayerByIds(ctx context.Context, high int32, low int32) (*
 		where p.high_id = $1 and p.low_id = $2`
 
 	err = conn.QueryRow(ctx, stmt, high, low).Scan(
-		&player.DbId, &player.ControlMode, &player.BattleHints, &player.CoinBooster, &player.HighId, &player.LowId, &player.Name, &player.Region, &player.ProfileIcon, &player.CreatedAt, &player.LastLogin, &player.TutorialState, &player.CoinsReward, &player.CoinDoubler, &player.SelectedCardHigh, &player.SelectedCardLow,
+		&player.DbId, &player.ControlMode, &player.BattleHints, &player.HighId, &player.LowId, &player.Name, &player.Region, &player.ProfileIcon, &player.CreatedAt, &player.LastLogin, &player.TutorialState, &player.CoinsReward, &player.SelectedCardHigh, &player.SelectedCardLow,
 		&player.Trophies, &player.HighestTrophies, &player.SoloVictories, &player.DuoVictories, &player.TrioVictories, &player.Experience,
 		&allianceId, &allianceRole,
 	)
@@ -382,6 +421,34 @@ func (m *Manager) LoadPlayerByIds(ctx context.Context, high int32, low int32) (*
 		return nil, fmt.Errorf("error iterating wallet rows for player %d: %w", playerId, err)
 	}
 
+	if err = loadPlayerAccessories(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerPity(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerOfferPurchases(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerMilestoneClaims(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerQuests(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerBoosts(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerSanctions(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
 	return player, nil
 }
 
@@ -402,7 +469,7 @@ func (m *Manager) LoadPlayerByToken(ctx context.Context, token string) (*core.Pl
 	// core/progression
 	stmt := `
 		select
-			p.id, p.control_mode, p.battle_hints, p.coin_booster, p.high_id, p.low_id, p.name, p.region, p.profile_icon, p.created_at, p.last_login, p.tutorial_state, p.coins_reward, p.coin_doubler, p.selected_card_high, p.selected_card_low,
+			p.id, p.control_mode, p.battle_hints, p.high_id, p.low_id, p.name, p.region, p.profile_icon, p.created_at, p.last_login, p.tutorial_state, p.coins_reward, p.selected_card_high, p.selected_card_low,
 			pp.trophies, pp.highest_trophies, pp.solo_victories, pp.duo_victories, pp.trio_victories, pp.experience,
 			am.alliance_id, am.role
 		from players p
@@ -411,7 +478,7 @@ func (m *Manager) LoadPlayerByToken(ctx context.Context, token string) (*core.Pl
 		where p.token = $1`
 
 	err = conn.QueryRow(ctx, stmt, token).Scan(
-		&player.DbId, &player.ControlMode, &player.BattleHints, &player.CoinBooster, &player.HighId, &player.LowId, &player.Name, &player.Region, &player.ProfileIcon, &player.CreatedAt, &player.LastLogin, &player.TutorialState, &player.CoinsReward, &player.CoinDoubler, &player.SelectedCardHigh, &player.SelectedCardLow,
+		&player.DbId, &player.ControlMode, &player.BattleHints, &player.HighId, &player.LowId, &player.Name, &player.Region, &player.ProfileIcon, &player.CreatedAt, &player.LastLogin, &player.TutorialState, &player.CoinsReward, &player.SelectedCardHigh, &player.SelectedCardLow,
 		&player.Trophies, &player.HighestTrophies, &player.SoloVictories, &player.DuoVictories, &player.TrioVictories, &player.Experience,
 		&allianceId, &allianceRole,
 	)
@@ -512,9 +579,346 @@ func (m *Manager) LoadPlayerByToken(ctx context.Context, token string) (*core.Pl
 		return nil, fmt.Errorf("error iterating wallet rows for player %d: %w", playerId, err)
 	}
 
+	if err = loadPlayerAccessories(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerPity(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerOfferPurchases(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerMilestoneClaims(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerQuests(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerBoosts(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
+	if err = loadPlayerSanctions(ctx, conn, player); err != nil {
+		return nil, err
+	}
+
 	return player, nil
 }
 
+// UpdatePlayerPity stores the box pity counters of player in one statement.
+func (m *Manager) UpdatePlayerPity(ctx context.Context, player *core.Player) error {
+	rarities := make([]int32, 0, len(player.PityCounters))
+	misses := make([]int32, 0, len(player.PityCounters))
+
+	for rarity, count := range player.PityCounters {
+		rarities = append(rarities, rarity)
+		misses = append(misses, count)
+	}
+
+	stmt := `
+		insert into player_box_pity (player_id, rarity, misses)
+		select $1, unnest($2::int[]), unnest($3::int[])
+		on conflict (player_id, rarity) do update set misses = excluded.misses, updated_at = current_timestamp`
+
+	if _, err := m.pool.Exec(ctx, stmt, player.DbId, rarities, misses); err != nil {
+		return fmt.Errorf("failed to update box pity for player %d: %w", player.DbId, err)
+	}
+
+	return nil
+}
+
+// RecordOfferPurchase counts one more purchase of the shop offer identified by key, unless the
+// player already bought it limit times. limit 0 is unlimited. recorded is false when the limit
+// was reached, which keeps two sessions from both buying the last one.
+func (m *Manager) RecordOfferPurchase(ctx context.Context, playerId int64, key string, limit int32) (bool, error) {
+	stmt := `
+		insert into player_offer_purchases (player_id, offer_key, purchases)
+		values ($1, $2, 1)
+		on conflict (player_id, offer_key) do update
+		set purchases = player_offer_purchases.purchases + 1, last_purchased_at = current_timestamp
+		where $3 = 0 or player_offer_purchases.purchases < $3`
+
+	tag, err := m.pool.Exec(ctx, stmt, playerId, key, limit)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to record purchase of %s for player %d: %w", key, playerId, err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// RevertOfferPurchase takes back one purchase recorded by RecordOfferPurchase.
+func (m *Manager) RevertOfferPurchase(ctx context.Context, playerId int64, key string) error {
+	stmt := `
+		update player_offer_purchases set purchases = purchases - 1
+		where player_id = $1 and offer_key = $2 and purchases > 0`
+
+	if _, err := m.pool.Exec(ctx, stmt, playerId, key); err != nil {
+		return fmt.Errorf("failed to revert purchase of %s for player %d: %w", key, playerId, err)
+	}
+
+	return nil
+}
+
+// ClaimMilestone records a milestone claim. claimed is false if the player already had it,
+// which keeps two sessions from both granting the rewards.
+func (m *Manager) ClaimMilestone(ctx context.Context, playerId int64, milestoneId int32) (bool, error) {
+	tag, err := m.pool.Exec(ctx, "insert into player_milestone_claims (player_id, milestone_id) values ($1, $2) on conflict do nothing", playerId, milestoneId)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to claim milestone %d for player %d: %w", milestoneId, playerId, err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// GrantPurchase records a verified purchase and adds its amount to the player's wallet in one
+// transaction. granted is false if the receipt was already used, by this or any other player.
+func (m *Manager) GrantPurchase(ctx context.Context, playerId int64, receiptId string, productId string, currencyId int32, amount int32) (bool, error) {
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		insert into player_purchases (receipt_id, player_id, product_id, currency_id, amount)
+		values ($1, $2, $3, $4, $5)
+		on conflict (receipt_id) do nothing`,
+		receiptId, playerId, productId, currencyId, amount)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to record purchase %s for player %d: %w", receiptId, playerId, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into player_wallet (player_id, currency_id, balance) values ($1, $2, $3)
+		on conflict (player_id, currency_id) do update set balance = player_wallet.balance + excluded.balance`,
+		playerId, currencyId, amount)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to grant purchase %s to player %d: %w", receiptId, playerId, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("transaction commit error: %w", err)
+	}
+
+	return true, nil
+}
+
+// ExtendBoost adds duration to a time based boost. A boost that has expired starts again from
+// now, the new state is returned.
+func (m *Manager) ExtendBoost(ctx context.Context, playerId int64, kind core.BoostKind, duration time.Duration) (*core.PlayerBoost, error) {
+	rows, err := m.pool.Query(ctx, `
+		insert into player_boosts (player_id, kind, expires_at) values ($1, $2, current_timestamp + make_interval(secs => $3))
+		on conflict (player_id, kind) do update
+		set expires_at = greatest(player_boosts.expires_at, current_timestamp) + make_interval(secs => $3)
+		returning kind, expires_at, remaining`,
+		playerId, kind, duration.Seconds())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend boost %d for player %d: %w", kind, playerId, err)
+	}
+
+	boost, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[core.PlayerBoost])
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect boost %d for player %d: %w", kind, playerId, err)
+	}
+
+	return boost, nil
+}
+
+// AddBoost adds amount to a quantity based boost and returns its new state.
+func (m *Manager) AddBoost(ctx context.Context, playerId int64, kind core.BoostKind, amount int32) (*core.PlayerBoost, error) {
+	rows, err := m.pool.Query(ctx, `
+		insert into player_boosts (player_id, kind, remaining) values ($1, $2, $3)
+		on conflict (player_id, kind) do update set remaining = player_boosts.remaining + excluded.remaining
+		returning kind, expires_at, remaining`,
+		playerId, kind, amount)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to add boost %d for player %d: %w", kind, playerId, err)
+	}
+
+	boost, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[core.PlayerBoost])
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect boost %d for player %d: %w", kind, playerId, err)
+	}
+
+	return boost, nil
+}
+
+// ConsumeBoost takes up to amount from a quantity based boost in a single statement, so two
+// battles ending at once cannot both use the same remainder. It returns how much was used and
+// what is left.
+func (m *Manager) ConsumeBoost(ctx context.Context, playerId int64, kind core.BoostKind, amount int32) (int32, int32, error) {
+	var used, remaining int32
+
+	err := m.pool.QueryRow(ctx, `
+		with previous as (
+			select remaining from player_boosts
+			where player_id = $1 and kind = $2 and remaining > 0
+			for update
+		)
+		update player_boosts b
+		set remaining = b.remaining - least(previous.remaining, $3)
+		from previous
+		where b.player_id = $1 and b.kind = $2
+		returning least(previous.remaining, $3), b.remaining`,
+		playerId, kind, amount).Scan(&used, &remaining)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, nil
+	}
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to consume boost %d for player %d: %w", kind, playerId, err)
+	}
+
+	return used, remaining, nil
+}
+
+// ReplaceQuests swaps the player's quests of period for quests.
+func (m *Manager) ReplaceQuests(ctx context.Context, playerId int64, period core.QuestPeriod, quests []*core.PlayerQuest) error {
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "delete from player_quests where player_id = $1 and period = $2", playerId, period); err != nil {
+		return fmt.Errorf("failed to delete quests of player %d: %w", playerId, err)
+	}
+
+	for _, quest := range quests {
+		_, err = tx.Exec(ctx, `
+			insert into player_quests (player_id, period, slot, template_id, progress, claimed, rerolled, assigned_at)
+			values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			playerId, quest.Period, quest.Slot, quest.TemplateId, quest.Progress, quest.Claimed, quest.Rerolled, quest.AssignedAt)
+
+		if err != nil {
+			return fmt.Errorf("failed to insert quest for player %d: %w", playerId, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("transaction commit error: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) UpdateQuest(ctx context.Context, playerId int64, quest *core.PlayerQuest) error {
+	stmt := `
+		update player_quests set template_id = $1, progress = $2, rerolled = $3
+		where player_id = $4 and period = $5 and slot = $6 and assigned_at = $7`
+
+	if _, err := m.pool.Exec(ctx, stmt, quest.TemplateId, quest.Progress, quest.Rerolled, playerId, quest.Period, quest.Slot, quest.AssignedAt); err != nil {
+		return fmt.Errorf("failed to update quest %s for player %d: %w", quest.TemplateId, playerId, err)
+	}
+
+	return nil
+}
+
+// ClaimQuest marks a quest as claimed. claimed is false if it already was, or if the quest has
+// been replaced since, which keeps two sessions from both granting the rewards.
+func (m *Manager) ClaimQuest(ctx context.Context, playerId int64, quest *core.PlayerQuest) (bool, error) {
+	stmt := `
+		update player_quests set claimed = true
+		where player_id = $1 and period = $2 and slot = $3 and assigned_at = $4 and template_id = $5 and not claimed`
+
+	tag, err := m.pool.Exec(ctx, stmt, playerId, quest.Period, quest.Slot, quest.AssignedAt, quest.TemplateId)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to claim quest %s for player %d: %w", quest.TemplateId, playerId, err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// UnclaimQuest undoes ClaimQuest for a quest whose rewards could not be granted.
+func (m *Manager) UnclaimQuest(ctx context.Context, playerId int64, quest *core.PlayerQuest) error {
+	stmt := `
+		update player_quests set claimed = false
+		where player_id = $1 and period = $2 and slot = $3 and assigned_at = $4 and template_id = $5`
+
+	if _, err := m.pool.Exec(ctx, stmt, playerId, quest.Period, quest.Slot, quest.AssignedAt, quest.TemplateId); err != nil {
+		return fmt.Errorf("failed to unclaim quest %s for player %d: %w", quest.TemplateId, playerId, err)
+	}
+
+	return nil
+}
+
+// LoadTicketEntries returns the entries of the ticketed event of slot that started at
+// startTime, and drops the ones of the slot's earlier events.
+func (m *Manager) LoadTicketEntries(ctx context.Context, slot int, startTime time.Time) (map[int64]*core.TicketEntry, error) {
+	if _, err := m.pool.Exec(ctx, "delete from ticket_entries where slot = $1 and start_time < $2", slot, startTime); err != nil {
+		return nil, fmt.Errorf("failed to delete old ticket entries of slot %d: %w", slot, err)
+	}
+
+	rows, err := m.pool.Query(ctx, "select player_id, attempts, pending from ticket_entries where slot = $1 and start_time = $2", slot, startTime)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ticket entries of slot %d: %w", slot, err)
+	}
+
+	defer rows.Close()
+
+	entries := make(map[int64]*core.TicketEntry)
+
+	for rows.Next() {
+		var playerId int64
+		entry := &core.TicketEntry{}
+
+		if err = rows.Scan(&playerId, &entry.Attempts, &entry.Pending); err != nil {
+			return nil, fmt.Errorf("failed to scan ticket entry: %w", err)
+		}
+
+		entries[playerId] = entry
+	}
+
+	return entries, rows.Err()
+}
+
+func (m *Manager) SaveTicketEntry(ctx context.Context, slot int, startTime time.Time, playerId int64, entry core.TicketEntry) error {
+	stmt := `
+		insert into ticket_entries (slot, start_time, player_id, attempts, pending) values ($1, $2, $3, $4, $5)
+		on conflict (slot, start_time, player_id) do update set attempts = excluded.attempts, pending = excluded.pending`
+
+	if _, err := m.pool.Exec(ctx, stmt, slot, startTime, playerId, entry.Attempts, entry.Pending); err != nil {
+		return fmt.Errorf("failed to save ticket entry of player %d: %w", playerId, err)
+	}
+
+	return nil
+}
+
+func (m *Manager) UnlockAccessory(ctx context.Context, playerId int64, brawlerId int32, kind core.AccessoryKind, id int32) error {
+	table := accessoryTables[kind]
+	stmt := fmt.Sprintf("insert into %s (player_id, brawler_id, %s) values ($1, $2, $3) on conflict do nothing", table.name, table.column)
+
+	if _, err := m.pool.Exec(ctx, stmt, playerId, brawlerId, id); err != nil {
+		return fmt.Errorf("failed to unlock accessory %d: %w", id, err)
+	}
+
+	return nil
+}
+
 func (m *Manager) Exec(query string, args ...interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -1145,6 +1549,7 @@ func (m *Manager) GetPlayerTrophyLeaderboard(ctx context.Context, limit int, reg
 	        from players p
 	        join player_progression pp on p.id = pp.player_id
 	        left join alliance_members am on p.id = am.player_id
+	        where `+rankedPlayer+`
 	        order by pp.trophies desc
 	        limit $1`
 
@@ -1157,7 +1562,7 @@ func (m *Manager) GetPlayerTrophyLeaderboard(ctx context.Context, limit int, reg
 	        from players p
 	        join player_progression pp on p.id = pp.player_id
 	        left join alliance_members am on p.id = am.player_id
-			where p.region = $2
+			where p.region = $2 and `+rankedPlayer+`
 	        order by pp.trophies desc
 	        limit $1`
 
@@ -1216,7 +1621,7 @@ func (m *Manager) GetBrawlerTrophyLeaderboard(ctx context.Context, brawlerId int
 	        join player_brawlers pb on p.id = pb.player_id
 	        join player_progression pp on p.id = pp.player_id
 	        left join alliance_members am on p.id = am.player_id
-	        where pb.brawler_id = $1
+	        where pb.brawler_id = $1 and `+rankedPlayer+`
 	        order by pb.trophies desc
 	        limit $2`
 		rows, err = m.pool.Query(ctx, query, brawlerId, limit)
@@ -1229,7 +1634,7 @@ func (m *Manager) GetBrawlerTrophyLeaderboard(ctx context.Context, brawlerId int
 	        join player_brawlers pb on p.id = pb.player_id
 	        join player_progression pp on p.id = pp.player_id
 	        left join alliance_members am on p.id = am.player_id
-	        where pb.brawler_id = $1 and p.region = $3
+	        where pb.brawler_id = $1 and p.region = $3 and `+rankedPlayer+`
 	        order by pb.trophies desc
 	        limit $2`
 		rows, err = m.pool.Query(ctx, query, brawlerId, limit, *region)
@@ -1342,6 +1747,172 @@ func (m *Manager) GetAllianceTrophyLeaderboard(ctx context.Context, limit int, r
 	return entries, nil
 }
 
+func loadPlayerAccessories(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	for kind, table := range accessoryTables {
+		stmt := fmt.Sprintf("select brawler_id, %s from %s where player_id = $1 order by %s", table.column, table.name, table.column)
+
+		rows, err := conn.Query(ctx, stmt, player.DbId)
+
+		if err != nil {
+			return fmt.Errorf("failed to query %s for player %d: %w", table.name, player.DbId, err)
+		}
+
+		for rows.Next() {
+			var brawlerId, id int32
+
+			if err = rows.Scan(&brawlerId, &id); err != nil {
+				slog.Warn("skipping row while loading player data", "playerId", player.DbId, "err", err)
+				continue
+			}
+
+			brawler, exists := player.Brawlers[brawlerId]
+
+			if !exists {
+				continue
+			}
+
+			unlocked := brawler.Unlocked(kind)
+			*unlocked = append(*unlocked, id)
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return fmt.Errorf("error iterating %s rows for player %d: %w", table.name, player.DbId, err)
+		}
+	}
+
+	return nil
+}
+
+func loadPlayerPity(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	rows, err := conn.Query(ctx, "select rarity, misses from player_box_pity where player_id = $1", player.DbId)
+
+	if err != nil {
+		return fmt.Errorf("failed to query box pity for player %d: %w", player.DbId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var rarity, misses int32
+
+		if err = rows.Scan(&rarity, &misses); err != nil {
+			slog.Warn("skipping row while loading player data", "playerId", player.DbId, "err", err)
+			continue
+		}
+
+		player.PityCounters[rarity] = misses
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating box pity rows for player %d: %w", player.DbId, err)
+	}
+
+	return nil
+}
+
+// loadPlayerOfferPurchases skips purchases of rotated offers older than offerPurchaseRetention,
+// their keys can never be offered again.
+func loadPlayerOfferPurchases(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	stmt := `
+		select offer_key, purchases from player_offer_purchases
+		where player_id = $1 and (position('@' in offer_key) = 0 or last_purchased_at > current_timestamp - $2::interval)`
+
+	rows, err := conn.Query(ctx, stmt, player.DbId, offerPurchaseRetention)
+
+	if err != nil {
+		return fmt.Errorf("failed to query offer purchases for player %d: %w", player.DbId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var purchases int32
+
+		if err = rows.Scan(&key, &purchases); err != nil {
+			slog.Warn("skipping row while loading player data", "playerId", player.DbId, "err", err)
+			continue
+		}
+
+		player.OfferPurchases[key] = purchases
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating offer purchase rows for player %d: %w", player.DbId, err)
+	}
+
+	return nil
+}
+
+func loadPlayerMilestoneClaims(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	rows, err := conn.Query(ctx, "select milestone_id from player_milestone_claims where player_id = $1", player.DbId)
+
+	if err != nil {
+		return fmt.Errorf("failed to query milestone claims for player %d: %w", player.DbId, err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var milestoneId int32
+
+		if err = rows.Scan(&milestoneId); err != nil {
+			slog.Warn("skipping row while loading player data", "playerId", player.DbId, "err", err)
+			continue
+		}
+
+		player.ClaimedMilestones[milestoneId] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating milestone claim rows for player %d: %w", player.DbId, err)
+	}
+
+	return nil
+}
+
+func loadPlayerQuests(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	rows, err := conn.Query(ctx, `
+		select period, slot, template_id, progress, claimed, rerolled, assigned_at
+		from player_quests where player_id = $1 order by period, slot`, player.DbId)
+
+	if err != nil {
+		return fmt.Errorf("failed to query quests for player %d: %w", player.DbId, err)
+	}
+
+	quests, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[core.PlayerQuest])
+
+	if err != nil {
+		return fmt.Errorf("error iterating quest rows for player %d: %w", player.DbId, err)
+	}
+
+	player.Quests = quests
+
+	return nil
+}
+
+func loadPlayerBoosts(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	rows, err := conn.Query(ctx, "select kind, expires_at, remaining from player_boosts where player_id = $1", player.DbId)
+
+	if err != nil {
+		return fmt.Errorf("failed to query boosts for player %d: %w", player.DbId, err)
+	}
+
+	boosts, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[core.PlayerBoost])
+
+	if err != nil {
+		return fmt.Errorf("error iterating boost rows for player %d: %w", player.DbId, err)
+	}
+
+	for _, boost := range boosts {
+		player.Boosts[boost.Kind] = boost
+	}
+
+	return nil
+}
+
 func reverseMessages(s []core.AllianceMessage) {
 	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
 		s[i], s[j] = s[j], s[i]
@@ -1,6 +1,10 @@
 package database
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/szcvak/sps/pkg/core"
+)
 
 // --- Database tables --- //
 
@@ -160,13 +164,237 @@ const (
 
     created_at timestamptz not null default current_timestamp
 );`
+
+	playerBoxPity = `create table if not exists player_box_pity (
+	player_id bigint references players (id) on delete cascade,
+	rarity int not null,
+
+	misses int not null default 0 check ( misses >= 0 ),
+	updated_at timestamptz not null default current_timestamp,
+
+	primary key (player_id, rarity)
+);`
+
+	playerOfferPurchases = `create table if not exists player_offer_purchases (
+	player_id bigint references players (id) on delete cascade,
+	offer_key text not null,
+
+	purchases int not null default 0,
+	last_purchased_at timestamptz not null default current_timestamp,
+
+	primary key (player_id, offer_key)
+);`
+
+	playerMilestoneClaims = `create table if not exists player_milestone_claims (
+	player_id bigint references players (id) on delete cascade,
+	milestone_id int not null,
+
+	claimed_at timestamptz not null default current_timestamp,
+
+	primary key (player_id, milestone_id)
+);`
+
+	seasons = `create table if not exists seasons (
+	id serial primary key,
+
+	started_at timestamptz not null,
+	ends_at timestamptz not null,
+
+	reset_started_at timestamptz default null,
+	reset_completed_at timestamptz default null
+);`
+
+	playerSeasonHistory = `create table if not exists player_season_history (
+	season_id int references seasons (id) on delete cascade,
+	player_id bigint references players (id) on delete cascade,
+	brawler_id int not null,
+
+	trophies_before int not null,
+	trophies_after int not null,
+	payout int not null,
+
+	reset_at timestamptz not null default current_timestamp,
+
+	primary key (season_id, player_id, brawler_id)
+);`
+
+	playerQuests = `create table if not exists player_quests (
+	player_id bigint references players (id) on delete cascade,
+	period smallint not null,
+	slot smallint not null,
+
+	template_id text not null,
+	progress int not null default 0 check ( progress >= 0 ),
+
+	claimed boolean not null default false,
+	rerolled boolean not null default false,
+
+	assigned_at timestamptz not null,
+
+	primary key (player_id, period, slot)
+);`
+
+	ticketEntries = `create table if not exists ticket_entries (
+	slot smallint not null,
+	start_time timestamptz not null,
+	player_id bigint references players (id) on delete cascade,
+
+	attempts int not null default 0 check ( attempts >= 0 ),
+	pending boolean not null default false,
+
+	primary key (slot, start_time, player_id)
+);`
+
+	playerBoosts = `create table if not exists player_boosts (
+	player_id bigint references players (id) on delete cascade,
+	kind smallint not null,
+
+	expires_at timestamptz,
+	remaining int not null default 0 check ( remaining >= 0 ),
+
+	primary key (player_id, kind)
+);`
+
+	playerSanctions = `create table if not exists player_sanctions (
+	id bigserial primary key,
+	player_id bigint references players (id) on delete cascade,
+	kind smallint not null,
+
+	reason text not null,
+	issuer text not null,
+
+	issued_at timestamptz not null default current_timestamp,
+	expires_at timestamptz,
+	lifted_at timestamptz default null
+);`
+
+	playerCredentials = `create table if not exists player_credentials (
+	player_id bigint primary key references players (id) on delete cascade,
+
+	username text unique,
+	password_hash text,
+
+	recovery_selector text unique,
+	recovery_hash text,
+
+	updated_at timestamptz not null default current_timestamp
+);`
+
+	schemaMigrations = `create table if not exists schema_migrations (
+	name text primary key,
+	schema_version int not null,
+
+	applied_at timestamptz not null default current_timestamp
+);`
+
+	playerPurchases = `create table if not exists player_purchases (
+	receipt_id text primary key,
+	player_id bigint references players (id) on delete set null,
+
+	product_id text not null,
+	currency_id int not null,
+	amount int not null check ( amount >= 0 ),
+
+	purchased_at timestamptz not null default current_timestamp
+);`
 )
 
+// syncLowIdSequence moves the low id sequence past every low id in use, after accounts were
+// inserted with ids of their own. A sequence that was never used and has no ids to skip is
+// left to hand out its first value.
+const syncLowIdSequence = `select setval('player_low_id_seq',
+	greatest(used.low_id, seq.last_value),
+	seq.is_called or used.low_id >= seq.last_value
+)
+from (select coalesce(max(low_id), 0) as low_id from players) used, player_low_id_seq seq`
+
+// legacyPowerLevels raises the power level of brawlers to one more than the upgrade cards
+// they hold, the level those cards gave them before power levels were stored. $1 holds the
+// unlock cards, which are not upgrades. It runs once, see MigrateLegacyPowerLevels.
+const legacyPowerLevels = `update player_brawlers b set power_level = least(1 + legacy.levels, 11)
+from (
+	select player_id, brawler_id, sum(value::int) as levels
+	from player_brawlers, jsonb_each_text(cards)
+	where not (key::int = any($1::int[]))
+	group by player_id, brawler_id
+) legacy
+where b.player_id = legacy.player_id and b.brawler_id = legacy.brawler_id
+and b.power_level < least(1 + legacy.levels, 11)`
+
+// SchemaVersion must be bumped whenever a table is added to or changed in schema,
+// backups taken with a different version are refused on restore.
+const SchemaVersion = 14
+
+type schemaTable struct {
+	Name   string
+	Table  string
+	Stmt   string
+	Serial string
+}
+
+// schema lists every table in creation order, parents before the tables referencing them.
+var schema = []schemaTable{
+	{"players table", "players", players, "id"},
+	{"player progression table", "player_progression", playerProgression, ""},
+	{"player brawlers table", "player_brawlers", playerBrawlers, ""},
+	{"player unlocked star powers table", "player_unlocked_star_powers", playerUnlockedStarPowers, ""},
+	{"player unlocked star gadgets table", "player_unlocked_gadgets", playerUnlockedGadgets, ""},
+	{"player unlocked star gears table", "player_unlocked_gears", playerUnlockedGears, ""},
+	{"player wallet table", "player_wallet", playerWallet, ""},
+	{"alliances", "alliances", alliances, "id"},
+	{"alliance members", "alliance_members", allianceMembers, ""},
+	{"alliance messages", "alliance_messages", allianceMessages, "id"},
+	{"player box pity table", "player_box_pity", playerBoxPity, ""},
+	{"player offer purchases table", "player_offer_purchases", playerOfferPurchases, ""},
+	{"player milestone claims table", "player_milestone_claims", playerMilestoneClaims, ""},
+	{"seasons table", "seasons", seasons, "id"},
+	{"player season history table", "player_season_history", playerSeasonHistory, ""},
+	{"player quests table", "player_quests", playerQuests, ""},
+	{"player purchases table", "player_purchases", playerPurchases, ""},
+	{"player boosts table", "player_boosts", playerBoosts, ""},
+	{"player sanctions table", "player_sanctions", playerSanctions, "id"},
+	{"player credentials table", "player_credentials", playerCredentials, ""},
+	{"schema migrations table", "schema_migrations", schemaMigrations, ""},
+	{"ticket entries table", "ticket_entries", ticketEntries, ""},
+}
+
+// migrations run after the tables are created, in order. Each one must be safe to run again.
+var migrations = []struct {
+	Name string
+	Stmt string
+}{
+	// boosts used to be kept in players as an int32 unix timestamp and a coin count
+	{Name: "coin booster", Stmt: `
+		insert into player_boosts (player_id, kind, expires_at)
+		select id, 1, to_timestamp(coin_booster) from players where coin_booster > extract(epoch from current_timestamp)
+		on conflict do nothing`},
+	{Name: "coin doubler", Stmt: `
+		insert into player_boosts (player_id, kind, remaining)
+		select id, 2, coin_doubler from players where coin_doubler > 0
+		on conflict do nothing`},
+	{Name: "legacy boost columns", Stmt: "update players set coin_booster = 0, coin_doubler = 0 where coin_booster <> 0 or coin_doubler <> 0"},
+	{Name: "player sanctions index", Stmt: "create index if not exists player_sanctions_player_id_idx on player_sanctions (player_id)"},
+	// low ids used to be picked by the client
+	{Name: "player low id sequence", Stmt: "create sequence if not exists player_low_id_seq"},
+	{Name: "player low id sequence position", Stmt: syncLowIdSequence},
+	// receipts used to be deleted with their player, which let them be redeemed again
+	{Name: "player purchases owner", Stmt: `
+		alter table player_purchases
+		drop constraint if exists player_purchases_player_id_fkey,
+		add constraint player_purchases_player_id_fkey foreign key (player_id) references players (id) on delete set null`},
+}
+
 // --- Errors --- //
 
 var (
 	ErrAccountAlreadyExists = errors.New("account already exists")
 	ErrPlayerNotFound       = errors.New("player not found")
+	ErrUnsupportedExport    = errors.New("unsupported account export version")
+	ErrUnsupportedBackup    = errors.New("unsupported backup archive")
+	ErrInvalidSanction      = errors.New("invalid sanction")
+	ErrUsernameTaken        = errors.New("username taken")
+	ErrCredentialsNotFound  = errors.New("credentials not found")
+	ErrMissingGameData      = errors.New("game data has not been loaded")
 )
 
 // --- Other --- //
@@ -175,4 +403,15 @@ var (
 	defaultUnlockedSkinsJson = `[0]`
 	defaultBrawlerCards      = `{"0": 1}`
 	defaultSkinId            = 0
+
+	offerPurchaseRetention = "2 days"
+
+	accessoryTables = map[core.AccessoryKind]struct {
+		name   string
+		column string
+	}{
+		core.AccessoryStarPower: {"player_unlocked_star_powers", "star_power_id"},
+		core.AccessoryGadget:    {"player_unlocked_gadgets", "gadget_id"},
+		core.AccessoryGear:      {"player_unlocked_gears", "gear_id"},
+	}
 )
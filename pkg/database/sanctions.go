@@ -0,0 +1,305 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/szcvak/sps/pkg/core"
+)
+
+// sanctionChannel is notified with the player id whenever a sanction is issued or lifted, so
+// the server applies sanctions issued from the command line to players who are online.
+const sanctionChannel = "player_sanctions"
+
+const sanctionRetryInterval = 5 * time.Second
+
+// activeSanction is the condition a player_sanctions row aliased s must meet to be in effect.
+const activeSanction = "s.lifted_at is null and (s.expires_at is null or s.expires_at > current_timestamp)"
+
+// rankedPlayer is the condition a players row aliased p must meet to show up on leaderboards,
+// which banned and excluded players do not.
+var rankedPlayer = fmt.Sprintf(`not exists (
+	select 1 from player_sanctions s
+	where s.player_id = p.id and s.kind in (%d, %d) and %s
+)`, core.SanctionBan, core.SanctionLeaderboardExclusion, activeSanction)
+
+// IssueSanction sanctions a player for duration, forever when it is zero. Suspensions are
+// temporary by definition and need a duration.
+func (m *Manager) IssueSanction(ctx context.Context, playerId int64, kind core.SanctionKind, reason string, issuer string, duration time.Duration) (*core.PlayerSanction, error) {
+	if kind < core.SanctionBan || kind > core.SanctionLeaderboardExclusion {
+		return nil, fmt.Errorf("%w: unknown kind %d", ErrInvalidSanction, kind)
+	}
+
+	if duration < 0 || (kind == core.SanctionSuspension && duration == 0) {
+		return nil, fmt.Errorf("%w: %s is not a valid duration for kind %d", ErrInvalidSanction, duration, kind)
+	}
+
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var expiresAt *time.Time
+
+	if duration > 0 {
+		expiry := time.Now().Add(duration)
+		expiresAt = &expiry
+	}
+
+	rows, err := tx.Query(ctx, `
+		insert into player_sanctions (player_id, kind, reason, issuer, expires_at) values ($1, $2, $3, $4, $5)
+		returning id, kind, reason, issuer, issued_at, expires_at, lifted_at`,
+		playerId, kind, reason, issuer, expiresAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue sanction for player %d: %w", playerId, err)
+	}
+
+	sanction, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[core.PlayerSanction])
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect sanction for player %d: %w", playerId, err)
+	}
+
+	if err = notifySanction(ctx, tx, playerId); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit sanction: %w", err)
+	}
+
+	slog.Info("issued sanction", "playerId", playerId, "kind", kind, "issuer", issuer, "expiresAt", expiresAt)
+
+	return sanction, nil
+}
+
+// LiftSanctions lifts every active sanction of kind on a player and returns how many there were.
+func (m *Manager) LiftSanctions(ctx context.Context, playerId int64, kind core.SanctionKind) (int64, error) {
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		update player_sanctions s set lifted_at = current_timestamp
+		where s.player_id = $1 and s.kind = $2 and `+activeSanction,
+		playerId, kind)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to lift sanctions for player %d: %w", playerId, err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	if err = notifySanction(ctx, tx, playerId); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit lifted sanctions: %w", err)
+	}
+
+	slog.Info("lifted sanctions", "playerId", playerId, "kind", kind, "count", tag.RowsAffected())
+
+	return tag.RowsAffected(), nil
+}
+
+// ActiveSanctions returns the sanctions in effect on a player.
+func (m *Manager) ActiveSanctions(ctx context.Context, playerId int64) ([]*core.PlayerSanction, error) {
+	return querySanctions(ctx, m.pool, playerId, true)
+}
+
+// SanctionHistory returns every sanction ever issued against a player, the newest first.
+func (m *Manager) SanctionHistory(ctx context.Context, playerId int64) ([]*core.PlayerSanction, error) {
+	return querySanctions(ctx, m.pool, playerId, false)
+}
+
+// --- Listener --- //
+
+// SanctionListener reports players whose sanctions have changed, wherever they were issued
+// from. It holds a connection of its own for as long as it runs.
+type SanctionListener struct {
+	dbm *Manager
+
+	// OnChange is called with the id of a player who was sanctioned or had a sanction lifted.
+	OnChange func(playerId int64)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewSanctionListener(dbm *Manager) *SanctionListener {
+	return &SanctionListener{
+		dbm: dbm,
+	}
+}
+
+// Start subscribes to sanction changes and keeps listening in the background until Close,
+// reconnecting whenever the connection is lost.
+func (s *SanctionListener) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	conn, err := s.listen(ctx)
+
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.loop(ctx, conn)
+
+	return nil
+}
+
+func (s *SanctionListener) Close() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	<-s.done
+
+	slog.Info("sanction listener stopped")
+}
+
+// --- Private methods --- //
+
+func (s *SanctionListener) listen(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := s.dbm.pool.Acquire(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	if _, err = conn.Exec(ctx, "listen "+sanctionChannel); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to listen for sanctions: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (s *SanctionListener) loop(ctx context.Context, conn *pgxpool.Conn) {
+	defer close(s.done)
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+
+		if err != nil {
+			// the connection is in an unknown state once waiting fails, so it is dropped
+			// instead of going back to the pool
+			_ = conn.Hijack().Close(context.Background())
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			slog.Error("lost sanction listener connection!", "err", err)
+
+			if conn, err = s.reconnect(ctx); err != nil {
+				return
+			}
+
+			continue
+		}
+
+		playerId, err := strconv.ParseInt(notification.Payload, 10, 64)
+
+		if err != nil {
+			slog.Warn("got invalid sanction notification", "payload", notification.Payload)
+			continue
+		}
+
+		if s.OnChange != nil {
+			s.OnChange(playerId)
+		}
+	}
+}
+
+// reconnect keeps trying to listen again until it succeeds or ctx is done.
+func (s *SanctionListener) reconnect(ctx context.Context) (*pgxpool.Conn, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(sanctionRetryInterval):
+		}
+
+		conn, err := s.listen(ctx)
+
+		if err == nil {
+			return conn, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		slog.Error("failed to reconnect sanction listener!", "err", err)
+	}
+}
+
+// --- Helper functions --- //
+
+func notifySanction(ctx context.Context, tx pgx.Tx, playerId int64) error {
+	if _, err := tx.Exec(ctx, "select pg_notify($1, $2)", sanctionChannel, strconv.FormatInt(playerId, 10)); err != nil {
+		return fmt.Errorf("failed to notify sanction change: %w", err)
+	}
+
+	return nil
+}
+
+func querySanctions(ctx context.Context, q interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}, playerId int64, activeOnly bool) ([]*core.PlayerSanction, error) {
+	query := `
+		select s.id, s.kind, s.reason, s.issuer, s.issued_at, s.expires_at, s.lifted_at
+		from player_sanctions s where s.player_id = $1`
+
+	if activeOnly {
+		query += " and " + activeSanction
+	}
+
+	rows, err := q.Query(ctx, query+" order by s.issued_at desc", playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sanctions for player %d: %w", playerId, err)
+	}
+
+	sanctions, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[core.PlayerSanction])
+
+	if err != nil {
+		return nil, fmt.Errorf("error iterating sanction rows for player %d: %w", playerId, err)
+	}
+
+	return sanctions, nil
+}
+
+func loadPlayerSanctions(ctx context.Context, conn *pgxpool.Conn, player *core.Player) error {
+	sanctions, err := querySanctions(ctx, conn, player.DbId, true)
+
+	if err != nil {
+		return err
+	}
+
+	player.SetSanctions(sanctions)
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/szcvak/sps/pkg/config"
+)
+
+type Season struct {
+	Id int32 `db:"id"`
+
+	StartedAt time.Time `db:"started_at"`
+	EndsAt    time.Time `db:"ends_at"`
+
+	ResetStartedAt   *time.Time `db:"reset_started_at"`
+	ResetCompletedAt *time.Time `db:"reset_completed_at"`
+}
+
+// SeasonResetResult is what the season reset took from and gave to one player.
+type SeasonResetResult struct {
+	PlayerId int64
+
+	// Trophies maps every reset brawler to its new trophy count.
+	Trophies map[int32]int32
+
+	TrophiesLost int32
+
+	CurrencyId int32
+	Payout     int32
+}
+
+// SeasonScheduler ends seasons once their end time has passed. Brawler trophies above the
+// configured thresholds are reset and the excess is paid out in the season currency.
+//
+// Every player is reset in its own transaction and recorded in player_season_history, so an
+// interrupted reset picks up where it stopped and never pays anyone twice.
+type SeasonScheduler struct {
+	dbm *Manager
+
+	// OnReset is called after a player's reset has been committed, for example to update
+	// players who are online.
+	OnReset func(result SeasonResetResult)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+const seasonCheckInterval = time.Minute
+
+func NewSeasonScheduler(dbm *Manager) *SeasonScheduler {
+	return &SeasonScheduler{
+		dbm: dbm,
+	}
+}
+
+// Start checks the current season once, finishing a pending reset before returning, and then
+// keeps checking in the background until Close.
+func (s *SeasonScheduler) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := s.Check(ctx); err != nil {
+		cancel()
+		return err
+	}
+
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.loop(ctx)
+
+	return nil
+}
+
+func (s *SeasonScheduler) Close() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	<-s.done
+
+	slog.Info("season scheduler stopped")
+}
+
+// Check starts the first season if there is none and ends the current one if it is over.
+func (s *SeasonScheduler) Check(ctx context.Context) error {
+	season, err := s.dbm.currentSeason(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if time.Now().Before(season.EndsAt) {
+		s.dbm.seasonEnd.Store(season.EndsAt.Unix())
+		return nil
+	}
+
+	slog.Info("season ended, resetting trophies", "season", season.Id, "endedAt", season.EndsAt)
+
+	if err = s.reset(ctx, season); err != nil {
+		return err
+	}
+
+	next, err := s.dbm.finishSeason(ctx, season)
+
+	if err != nil {
+		return err
+	}
+
+	s.dbm.seasonEnd.Store(next.EndsAt.Unix())
+
+	slog.Info("started new season", "season", next.Id, "endsAt", next.EndsAt)
+
+	return nil
+}
+
+// SeasonEnd returns the end of the current season, or the zero time if no season scheduler
+// has run yet.
+func (m *Manager) SeasonEnd() time.Time {
+	end := m.seasonEnd.Load()
+
+	if end == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(end, 0)
+}
+
+// --- Private methods --- //
+
+func (s *SeasonScheduler) loop(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(seasonCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Check(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("failed to check season!", "err", err)
+			}
+		}
+	}
+}
+
+func (s *SeasonScheduler) reset(ctx context.Context, season *Season) error {
+	gameplay := config.Get().Gameplay
+
+	_, err := s.dbm.pool.Exec(ctx, "update seasons set reset_started_at = coalesce(reset_started_at, current_timestamp) where id = $1", season.Id)
+
+	if err != nil {
+		return fmt.Errorf("failed to mark season reset: %w", err)
+	}
+
+	rows, err := s.dbm.pool.Query(ctx, `
+		select distinct b.player_id
+		from player_brawlers b
+		where b.trophies >= $1
+		  and not exists (
+		      select 1 from player_season_history h
+		      where h.season_id = $2 and h.player_id = b.player_id and h.brawler_id = b.brawler_id
+		  )
+		order by b.player_id`,
+		gameplay.SeasonResets[0].MinTrophies, season.Id)
+
+	if err != nil {
+		return fmt.Errorf("failed to query season reset players: %w", err)
+	}
+
+	playerIds, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+
+	if err != nil {
+		return fmt.Errorf("failed to collect season reset players: %w", err)
+	}
+
+	reset := 0
+
+	for _, playerId := range playerIds {
+		result, err := s.dbm.resetPlayerSeason(ctx, season.Id, playerId, gameplay)
+
+		if err != nil {
+			return fmt.Errorf("failed to reset player %d: %w", playerId, err)
+		}
+
+		if result == nil {
+			continue
+		}
+
+		reset++
+
+		if s.OnReset != nil {
+			s.OnReset(*result)
+		}
+	}
+
+	slog.Info("reset season trophies", "season", season.Id, "players", reset)
+
+	return nil
+}
+
+func (m *Manager) currentSeason(ctx context.Context) (*Season, error) {
+	rows, err := m.pool.Query(ctx, "select id, started_at, ends_at, reset_started_at, reset_completed_at from seasons order by id desc limit 1")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query current season: %w", err)
+	}
+
+	season, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[Season])
+
+	if err == nil {
+		return season, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to collect current season: %w", err)
+	}
+
+	length := time.Duration(config.Get().Gameplay.SeasonEndTime) * time.Second
+	now := time.Now()
+
+	season = &Season{StartedAt: now, EndsAt: now.Add(length)}
+
+	err = m.pool.QueryRow(ctx, "insert into seasons (started_at, ends_at) values ($1, $2) returning id", season.StartedAt, season.EndsAt).Scan(&season.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create first season: %w", err)
+	}
+
+	slog.Info("started first season", "season", season.Id, "endsAt", season.EndsAt)
+
+	return season, nil
+}
+
+// finishSeason marks the reset of season as complete and starts the next one where season
+// ended. If the server was down for longer than a whole season, the next one starts now.
+func (m *Manager) finishSeason(ctx context.Context, season *Season) (*Season, error) {
+	length := time.Duration(config.Get().Gameplay.SeasonEndTime) * time.Second
+	now := time.Now()
+
+	next := &Season{StartedAt: season.EndsAt}
+
+	if !next.StartedAt.Add(length).After(now) {
+		next.StartedAt = now
+	}
+
+	next.EndsAt = next.StartedAt.Add(length)
+
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "update seasons set reset_completed_at = current_timestamp where id = $1", season.Id); err != nil {
+		return nil, fmt.Errorf("failed to complete season: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, "insert into seasons (started_at, ends_at) values ($1, $2) returning id", next.StartedAt, next.EndsAt).Scan(&next.Id)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to start next season: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("transaction commit error: %w", err)
+	}
+
+	return next, nil
+}
+
+// resetPlayerSeason resets the brawlers of one player, returning nil if there was nothing left
+// to reset.
+func (m *Manager) resetPlayerSeason(ctx context.Context, seasonId int32, playerId int64, gameplay config.GameplayConfig) (*SeasonResetResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.pool.Begin(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "select brawler_id, trophies from player_brawlers where player_id = $1 and trophies >= $2 order by brawler_id for update", playerId, gameplay.SeasonResets[0].MinTrophies)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to query brawlers: %w", err)
+	}
+
+	type brawlerTrophies struct {
+		BrawlerId int32 `db:"brawler_id"`
+		Trophies  int32 `db:"trophies"`
+	}
+
+	brawlers, err := pgx.CollectRows(rows, pgx.RowToStructByName[brawlerTrophies])
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect brawlers: %w", err)
+	}
+
+	result := &SeasonResetResult{
+		PlayerId:   playerId,
+		Trophies:   make(map[int32]int32),
+		CurrencyId: gameplay.SeasonCurrencyId,
+	}
+
+	for _, brawler := range brawlers {
+		resetTo := seasonResetTrophies(gameplay.SeasonResets, brawler.Trophies)
+		excess := brawler.Trophies - resetTo
+
+		tag, err := tx.Exec(ctx, `
+			insert into player_season_history (season_id, player_id, brawler_id, trophies_before, trophies_after, payout)
+			values ($1, $2, $3, $4, $5, $6)
+			on conflict (season_id, player_id, brawler_id) do nothing`,
+			seasonId, playerId, brawler.BrawlerId, brawler.Trophies, resetTo, excess)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to record season history: %w", err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+
+		if _, err = tx.Exec(ctx, "update player_brawlers set trophies = $1 where player_id = $2 and brawler_id = $3", resetTo, playerId, brawler.BrawlerId); err != nil {
+			return nil, fmt.Errorf("failed to reset brawler trophies: %w", err)
+		}
+
+		result.Trophies[brawler.BrawlerId] = resetTo
+		result.TrophiesLost += excess
+		result.Payout += excess
+	}
+
+	if len(result.Trophies) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(ctx, "update player_progression set trophies = greatest(trophies - $1, 0) where player_id = $2", result.TrophiesLost, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to update player trophies: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		update alliances set total_trophies = greatest(total_trophies - $1, 0)
+		where id = (select alliance_id from alliance_members where player_id = $2)`,
+		result.TrophiesLost, playerId)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to update alliance trophies: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		insert into player_wallet (player_id, currency_id, balance) values ($1, $2, $3)
+		on conflict (player_id, currency_id) do update set balance = player_wallet.balance + excluded.balance`,
+		playerId, result.CurrencyId, result.Payout)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to pay season reward: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("transaction commit error: %w", err)
+	}
+
+	return result, nil
+}
+
+// seasonResetTrophies returns the trophies a brawler is reset to, using the highest
+// threshold it has reached. resets is sorted by MinTrophies.
+func seasonResetTrophies(resets []config.SeasonReset, trophies int32) int32 {
+	resetTo := trophies
+
+	for _, reset := range resets {
+		if trophies < reset.MinTrophies {
+			break
+		}
+
+		resetTo = reset.ResetTo
+	}
+
+	return resetTo
+}
@@ -0,0 +1,55 @@
+package messages
+
+import (
+	"context"
+
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+const (
+	accountBindPassword     int32 = 0
+	accountBindRecoveryCode int32 = 1
+)
+
+// AccountBindMessage asks to bind a username and password or a new recovery code to the
+// account, so it can be moved to another device. It is specific to this server.
+type AccountBindMessage struct {
+	kind     int32
+	username string
+	password string
+}
+
+func NewAccountBindMessage() *AccountBindMessage {
+	return &AccountBindMessage{}
+}
+
+func (a *AccountBindMessage) Unmarshal(data []byte) {
+	stream := core.NewByteStream(data)
+	defer stream.Close()
+
+	a.kind, _ = stream.ReadInt()
+	a.username, _ = stream.ReadString()
+	a.password, _ = stream.ReadString()
+}
+
+func (a *AccountBindMessage) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
+	if wrapper.Player == nil || wrapper.Player.State() != core.StateLoggedIn {
+		return
+	}
+
+	var msg *AccountBindResultMessage
+
+	switch a.kind {
+	case accountBindPassword:
+		msg = NewAccountBindResultMessage(messaging.BindPassword(context.Background(), dbm, wrapper.Player, a.username, a.password), "")
+	case accountBindRecoveryCode:
+		code, result := messaging.BindRecoveryCode(context.Background(), dbm, wrapper.Player)
+		msg = NewAccountBindResultMessage(result, code)
+	default:
+		return
+	}
+
+	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+}
@@ -0,0 +1,35 @@
+package messages
+
+import (
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+type AccountBindResultMessage struct {
+	result       messaging.AccountResult
+	recoveryCode string
+}
+
+func NewAccountBindResultMessage(result messaging.AccountResult, recoveryCode string) *AccountBindResultMessage {
+	return &AccountBindResultMessage{
+		result:       result,
+		recoveryCode: recoveryCode,
+	}
+}
+
+func (a *AccountBindResultMessage) PacketId() uint16 {
+	return 20190
+}
+
+func (a *AccountBindResultMessage) PacketVersion() uint16 {
+	return 1
+}
+
+func (a *AccountBindResultMessage) Marshal() []byte {
+	stream := core.NewByteStreamWithCapacity(32)
+
+	stream.Write(int32(a.result))
+	stream.Write(a.recoveryCode)
+
+	return stream.Buffer()
+}
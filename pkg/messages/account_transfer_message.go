@@ -0,0 +1,73 @@
+package messages
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+// AccountTransferMessage moves an account to the device that sends it, proven with a username
+// and password or, when the username is empty, a recovery code. The account gets a fresh token
+// that is sent back, the old one stops working and its session is ended. It is specific to this
+// server.
+type AccountTransferMessage struct {
+	username string
+	secret   string
+}
+
+func NewAccountTransferMessage() *AccountTransferMessage {
+	return &AccountTransferMessage{}
+}
+
+func (a *AccountTransferMessage) Unmarshal(data []byte) {
+	stream := core.NewByteStream(data)
+	defer stream.Close()
+
+	a.username, _ = stream.ReadString()
+	a.secret, _ = stream.ReadString()
+}
+
+func (a *AccountTransferMessage) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
+	transfer, retryAfter, result := messaging.TransferAccount(context.Background(), dbm, remoteHost(wrapper), a.username, a.secret)
+
+	if result != messaging.AccountOk {
+		msg := NewAccountTransferResultMessage(result, int32(retryAfter.Seconds()+0.5), nil)
+		wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+		return
+	}
+
+	for _, user := range LoggedInUsers() {
+		if user.Wrapper == wrapper || user.Player.DbId != transfer.PlayerId {
+			continue
+		}
+
+		msg := NewLoginFailedMessage(nil, "Your account has been moved to another device.", messaging.LoginFailed)
+		user.Wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+		user.Wrapper.Close()
+
+		slog.Info("ended session of transferred account", "playerId", transfer.PlayerId)
+	}
+
+	msg := NewAccountTransferResultMessage(result, 0, transfer)
+	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+	// the client logs in again with the new token
+	wrapper.Close()
+}
+
+// --- Helper functions --- //
+
+func remoteHost(wrapper *core.ClientWrapper) string {
+	address := wrapper.Conn().RemoteAddr().String()
+
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+
+	return address
+}
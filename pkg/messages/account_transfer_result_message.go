@@ -0,0 +1,51 @@
+package messages
+
+import (
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+type AccountTransferResultMessage struct {
+	result     messaging.AccountResult
+	retryAfter int32
+	transfer   *messaging.AccountTransfer
+}
+
+// NewAccountTransferResultMessage answers an account transfer. transfer is nil unless it
+// succeeded, retryAfter is how many seconds the client has to wait after too many attempts.
+func NewAccountTransferResultMessage(result messaging.AccountResult, retryAfter int32, transfer *messaging.AccountTransfer) *AccountTransferResultMessage {
+	return &AccountTransferResultMessage{
+		result:     result,
+		retryAfter: retryAfter,
+		transfer:   transfer,
+	}
+}
+
+func (a *AccountTransferResultMessage) PacketId() uint16 {
+	return 20191
+}
+
+func (a *AccountTransferResultMessage) PacketVersion() uint16 {
+	return 1
+}
+
+func (a *AccountTransferResultMessage) Marshal() []byte {
+	stream := core.NewByteStreamWithCapacity(64)
+
+	stream.Write(int32(a.result))
+	stream.Write(a.retryAfter)
+
+	if a.transfer == nil {
+		stream.Write(0)
+		stream.Write(0)
+		stream.Write(core.EmptyString)
+
+		return stream.Buffer()
+	}
+
+	stream.Write(a.transfer.HighId)
+	stream.Write(a.transfer.LowId)
+	stream.Write(a.transfer.Token)
+
+	return stream.Buffer()
+}
@@ -29,7 +29,7 @@ func (a *AllianceChatMessage) Process(wrapper *core.ClientWrapper, dbm *database
 		return
 	}
 
-	if wrapper.Player.AllianceId == nil {
+	if wrapper.Player.AllianceId == nil || muted(wrapper.Player, a.content) {
 		return
 	}
 
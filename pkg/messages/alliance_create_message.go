@@ -7,6 +7,7 @@ import (
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/database"
 	"github.com/szcvak/sps/pkg/hub"
+	"github.com/szcvak/sps/pkg/messaging"
 )
 
 type AllianceCreateMessage struct {
@@ -36,7 +37,14 @@ func (a *AllianceCreateMessage) Process(wrapper *core.ClientWrapper, dbm *databa
 		return
 	}
 
-	err := dbm.CreateAlliance(context.Background(), a.name, a.description, a.badge.S, int32(a.allianceType), int32(a.requiredTrophies), wrapper.Player)
+	if err := messaging.ValidateAllianceBadge(a.badge); err != nil {
+		slog.Warn("refusing alliance creation", "playerId", wrapper.Player.DbId, "err", err)
+		return
+	}
+
+	err := messaging.PayForAlliance(wrapper.Player, messaging.NewDatabaseRewardStore(dbm), func() error {
+		return dbm.CreateAlliance(context.Background(), a.name, a.description, a.badge.S, int32(a.allianceType), int32(a.requiredTrophies), wrapper.Player)
+	})
 
 	if err != nil {
 		slog.Error("failed to create alliance!", "err", err)
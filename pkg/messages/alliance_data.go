@@ -64,20 +64,7 @@ func (a *AllianceDataMessage) Marshal() []byte {
 		stream.Write(member.Name)
 		stream.Write(core.VInt(member.Role))
 
-		level := -1
-
-		for i := 0; i < len(core.RequiredExp)-1; i++ {
-			if core.RequiredExp[i] <= 0 && 0 < core.RequiredExp[i+1] {
-				level = i + 1
-				break
-			}
-		}
-
-		if level == -1 {
-			level = len(core.RequiredExp)
-		}
-
-		stream.Write(core.VInt(level))
+		stream.Write(core.VInt(core.ExperienceLevel(member.Experience)))
 		stream.Write(core.VInt(member.Trophies))
 		stream.Write(core.DataRef{28, member.ProfileIcon})
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
 )
 
 type AllianceEditMessage struct {
@@ -37,6 +38,11 @@ func (a *AllianceEditMessage) Process(wrapper *core.ClientWrapper, dbm *database
 		return
 	}
 
+	if err := messaging.ValidateAllianceBadge(a.badge); err != nil {
+		slog.Warn("refusing alliance edit", "playerId", wrapper.Player.DbId, "err", err)
+		return
+	}
+
 	err := dbm.Exec(
 		"update alliances set description = $1, badge_id = $2, type = $3, required_trophies = $4 where id = $5",
 		a.description, a.badge.S, a.allianceType, a.requiredTrophies, *wrapper.Player.AllianceId,
@@ -4,8 +4,8 @@ import (
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
 	"log/slog"
-	"strconv"
 )
 
 type AskForBattleEndMessage struct {
@@ -93,22 +93,7 @@ func (a *AskForBattleEndMessage) Process(wrapper *core.ClientWrapper, dbm *datab
 	}
 
 	if brawlerData, ok := player.Brawlers[charId]; ok {
-		powerLevel := int32(0)
-
-		for cardStr, amt := range brawlerData.Cards {
-			id, err := strconv.Atoi(cardStr)
-
-			if err != nil {
-				slog.Error("failed to convert card str!", "card", cardStr, "playerId", player.DbId)
-				continue
-			}
-
-			if !csv.IsCardUnlocked(id) {
-				powerLevel += amt
-			}
-		}
-
-		a.data.Brawlers[playerIndex].PowerLevel = powerLevel
+		a.data.Brawlers[playerIndex].PowerLevel = brawlerData.PowerLevel
 	} else {
 		slog.Warn("player data for brawler not found", "playerId", player.DbId, "charId", charId)
 		a.data.Brawlers[playerIndex].PowerLevel = 1
@@ -120,14 +105,63 @@ func (a *AskForBattleEndMessage) Process(wrapper *core.ClientWrapper, dbm *datab
 	if a.data.BattleRank != 0 {
 		msg := NewBattleEndSdMessage(a.data, player, dbm)
 		wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+		sendRewards(wrapper, msg.LevelUp())
+
+		if a.data.IsRealGame {
+			finishTicketedEvent(wrapper, dbm, int32(a.data.Location), int32(a.data.BattleRank))
+
+			updateQuests(wrapper, dbm, messaging.QuestBattle{
+				GameMode:  gameModeForLocation(int32(a.data.Location)),
+				BrawlerId: charId,
+				Won:       a.data.BattleRank <= showdownWinRank,
+			})
+		}
 	} else {
 		// msg := NewBattleEndTrioMessage(a.data, player, dbm)
 		// wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+		//
+		// sendRewards(wrapper, msg.LevelUp())
 	}
 }
 
 // --- Helper functions --- //
 
+// showdownWinRank is the lowest showdown rank that counts as a win for quests.
+const showdownWinRank = 4
+
+func updateQuests(wrapper *core.ClientWrapper, dbm *database.Manager, battle messaging.QuestBattle) {
+	logic, err := messaging.UpdateQuests(wrapper.Player, messaging.NewDatabaseRewardStore(dbm), battle)
+
+	if err != nil {
+		slog.Error("failed to update quests!", "playerId", wrapper.Player.DbId, "err", err)
+	}
+
+	sendRewards(wrapper, logic)
+}
+
+func finishTicketedEvent(wrapper *core.ClientWrapper, dbm *database.Manager, location int32, rank int32) {
+	if _, err := messaging.FinishTicketedEvent(wrapper.Player, messaging.NewDatabaseRewardStore(dbm), location, rank); err != nil {
+		slog.Error("failed to finish ticketed event!", "playerId", wrapper.Player.DbId, "location", location, "err", err)
+	}
+}
+
+func gameModeForLocation(location int32) string {
+	gameMode, _ := csv.GetGamemodeForLocation(location)
+	return gameMode
+}
+
+// sendRewards shows the boxes granted for levels gained or quests completed in a battle.
+// Currencies are already part of the home data the client reloads.
+func sendRewards(wrapper *core.ClientWrapper, logic *messaging.DeliveryLogic) {
+	if logic == nil || len(logic.Rewards()) == 0 {
+		return
+	}
+
+	msg := messaging.NewAvailableServerCommandMessage(203, logic)
+	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+}
+
 func discardError[T any](value T, _ error) T {
 	return value
 }
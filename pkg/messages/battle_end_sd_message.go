@@ -5,6 +5,7 @@ import (
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
 	"log/slog"
 	"math"
 	"time"
@@ -14,6 +15,8 @@ type BattleEndSdMessage struct {
 	data   BattleEndData
 	player *core.Player
 	dbm    *database.Manager
+
+	levelUp *messaging.DeliveryLogic
 }
 
 func NewBattleEndSdMessage(data BattleEndData, player *core.Player, dbm *database.Manager) *BattleEndSdMessage {
@@ -32,6 +35,11 @@ func (b *BattleEndSdMessage) PacketVersion() uint16 {
 	return 1
 }
 
+// LevelUp returns the rewards of the levels gained in the battle, after Marshal.
+func (b *BattleEndSdMessage) LevelUp() *messaging.DeliveryLogic {
+	return b.levelUp
+}
+
 func (b *BattleEndSdMessage) Marshal() []byte {
 	stream := core.NewByteStreamWithCapacity(256)
 
@@ -81,17 +89,10 @@ func (b *BattleEndSdMessage) Marshal() []byte {
 			starPlayerExp = 10
 		}
 
-		doubledCoins = coins
-
-		if coins > player.CoinDoubler {
-			doubledCoins = player.CoinDoubler
-		}
-
-		now := time.Now().Unix()
+		boosts := messaging.ApplyCoinBoosts(player, messaging.NewDatabaseRewardStore(b.dbm), coins, time.Now())
 
-		if int64(player.CoinBooster)-now > 0 {
-			boostedCoins = coins
-		}
+		boostedCoins = boosts.Boosted
+		doubledCoins = boosts.Doubled
 	}
 
 	stream.Write(core.VInt(5)) // 5 = showdown
@@ -171,15 +172,17 @@ func (b *BattleEndSdMessage) Marshal() []byte {
 	stream.Write(core.VInt(player.Experience))
 
 	stream.Write(true)
-	core.EmbedMilestones(stream)
+	core.EmbedMilestones(stream, messaging.MilestoneClaims(player))
 
 	if data.IsRealGame {
 		player.Trophies += trophies
 		player.HighestTrophies = max(player.Trophies, player.HighestTrophies)
-		player.Experience += exp + starPlayerExp
+
+		b.levelUp = messaging.NewDeliveryLogicWithStore(player, messaging.NewDatabaseRewardStore(b.dbm))
+		b.levelUp.AddExperience(exp + starPlayerExp)
 
 		if walletCoin, ok := player.Wallet[config.CurrencyCoins]; ok {
-			walletCoin.Balance += int64(coins + boostedCoins + doubledCoins)
+			walletCoin.Balance = messaging.CapBalance(config.CurrencyCoins, walletCoin.Balance, int64(coins+boostedCoins+doubledCoins))
 		}
 
 		player.CoinsReward = coins + boostedCoins + doubledCoins
@@ -213,8 +216,8 @@ func (b *BattleEndSdMessage) Marshal() []byte {
 
 			logError(
 				b.dbm.Exec(
-					"update players set coin_doubler = $1, coins_reward = $2 where id = $3",
-					player.CoinDoubler, player.CoinsReward, player.DbId,
+					"update players set coins_reward = $1 where id = $2",
+					player.CoinsReward, player.DbId,
 				),
 			)
 
@@ -4,6 +4,7 @@ import (
 	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
 	"math"
 	"time"
 )
@@ -12,6 +13,8 @@ type BattleEndTrioMessage struct {
 	data   BattleEndData
 	player *core.Player
 	dbm    *database.Manager
+
+	levelUp *messaging.DeliveryLogic
 }
 
 func NewBattleEndTrioMessage(data BattleEndData, player *core.Player, dbm *database.Manager) *BattleEndTrioMessage {
@@ -30,6 +33,11 @@ func (b *BattleEndTrioMessage) PacketVersion() uint16 {
 	return 1
 }
 
+// LevelUp returns the rewards of the levels gained in the battle, after Marshal.
+func (b *BattleEndTrioMessage) LevelUp() *messaging.DeliveryLogic {
+	return b.levelUp
+}
+
 func (b *BattleEndTrioMessage) Marshal() []byte {
 	stream := core.NewByteStreamWithCapacity(32)
 
@@ -39,6 +47,7 @@ func (b *BattleEndTrioMessage) Marshal() []byte {
 		exp           int32
 		starPlayerExp int32
 		boostedCoins  int32
+		doubledCoins  int32
 	)
 
 	if !b.data.IsRealGame {
@@ -47,19 +56,18 @@ func (b *BattleEndTrioMessage) Marshal() []byte {
 		exp = 0
 		starPlayerExp = 0
 		boostedCoins = 0
+		doubledCoins = 0
 	} else {
 		trophies = getTrioBattleEndTrophies(int32(b.data.BattleRank), b.player.Brawlers[b.data.Brawlers[0].CharacterId.S].Trophies)
 		coins = getTrioBattleEndCoins(int32(b.data.BattleRank))
 		exp = getTrioBattleEndExp(int32(b.data.BattleRank))
 
 		starPlayerExp = 10
-		boostedCoins = 0
 
-		now := time.Now().Unix()
+		boosts := messaging.ApplyCoinBoosts(b.player, messaging.NewDatabaseRewardStore(b.dbm), coins, time.Now())
 
-		if int64(b.player.CoinBooster)-now > 0 {
-			boostedCoins = coins
-		}
+		boostedCoins = boosts.Boosted
+		doubledCoins = boosts.Doubled
 	}
 
 	stream.Write(core.VInt(1))
@@ -76,7 +84,7 @@ func (b *BattleEndTrioMessage) Marshal() []byte {
 	stream.Write(b.data.IsRealGame)
 	stream.Write(core.VInt(50))
 	stream.Write(core.VInt(boostedCoins))
-	stream.Write(core.VInt(0)) /* coins doubled */
+	stream.Write(core.VInt(doubledCoins))
 
 	stream.Write(b.data.PlayersAmount)
 
@@ -112,12 +120,14 @@ func (b *BattleEndTrioMessage) Marshal() []byte {
 
 	stream.Write(true)
 
-	core.EmbedMilestones(stream)
+	core.EmbedMilestones(stream, messaging.MilestoneClaims(b.player))
 
 	b.player.Trophies += trophies
-	b.player.Experience += exp
-	b.player.Wallet[config.CurrencyCoins].Balance += int64(coins + boostedCoins) /* coins doubled */
-	b.player.CoinsReward += coins + boostedCoins
+
+	b.levelUp = messaging.NewDeliveryLogicWithStore(b.player, messaging.NewDatabaseRewardStore(b.dbm))
+	b.levelUp.AddExperience(exp)
+	b.player.Wallet[config.CurrencyCoins].Balance = messaging.CapBalance(config.CurrencyCoins, b.player.Wallet[config.CurrencyCoins].Balance, int64(coins+boostedCoins+doubledCoins))
+	b.player.CoinsReward += coins + boostedCoins + doubledCoins
 	b.player.Brawlers[b.data.Brawlers[0].CharacterId.S].Trophies += trophies
 	b.player.Brawlers[b.data.Brawlers[0].CharacterId.S].HighestTrophies = max(b.player.Brawlers[b.data.Brawlers[0].CharacterId.S].Trophies, b.player.Brawlers[b.data.Brawlers[0].CharacterId.S].HighestTrophies)
 
@@ -36,7 +36,7 @@ func (c *ChangeAvatarNameMessage) Process(wrapper *core.ClientWrapper, dbm *data
 	err := dbm.Exec("update players set Name = $1 where id = $2", c.name, wrapper.Player.DbId)
 
 	if err != nil {
-		_, _ = fmt.Fprintf(os.Stderr, "failed to change player's Name: %w\n", err)
+		_, _ = fmt.Fprintf(os.Stderr, "failed to change player's Name: %v\n", err)
 		return
 	}
 
@@ -119,7 +119,7 @@ func (l *LeaderboardMessage) playerLeaderboard(stream *core.ByteStream) {
 			stream.Write("")
 		}
 
-		stream.Write(core.VInt(getPlayerLevel(entry.PlayerExperience)))
+		stream.Write(core.VInt(core.ExperienceLevel(entry.PlayerExperience)))
 		stream.Write(core.DataRef{28, entry.ProfileIcon})
 		stream.Write(false)
 	}
@@ -202,7 +202,7 @@ func (l *LeaderboardMessage) brawlerLeaderboard(stream *core.ByteStream) {
 			stream.Write("")
 		}
 
-		stream.Write(core.VInt(getPlayerLevel(entry.PlayerExperience)))
+		stream.Write(core.VInt(core.ExperienceLevel(entry.PlayerExperience)))
 		stream.Write(core.DataRef{28, entry.ProfileIcon})
 		stream.Write(false)
 	}
@@ -268,17 +268,3 @@ func (l *LeaderboardMessage) allianceLeaderboard(stream *core.ByteStream) {
 
 	stream.Write(l.wrapper.Player.Region)
 }
-
-func getPlayerLevel(experience int32) int32 {
-	level := int32(0)
-
-	for i, reqExp := range core.RequiredExp {
-		if experience >= int32(reqExp) {
-			level = int32(i + 1)
-		} else {
-			break
-		}
-	}
-
-	return level
-}
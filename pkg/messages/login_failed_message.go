@@ -9,6 +9,12 @@ type LoginFailedMessage struct {
 	loginMessage *LoginMessage
 	text         string
 	reason       messaging.LoginFailedReason
+
+	fingerprint string
+	contentUrl  string
+	updateUrl   string
+
+	maintenanceSeconds int32
 }
 
 func NewLoginFailedMessage(loginMessage *LoginMessage, text string, reason messaging.LoginFailedReason) *LoginFailedMessage {
@@ -19,6 +25,39 @@ func NewLoginFailedMessage(loginMessage *LoginMessage, text string, reason messa
 	}
 }
 
+// NewLoginFailedUpdateMessage tells a client its content is out of date. The client downloads
+// the files of fingerprint that differ from its own from contentUrl and logs in again.
+func NewLoginFailedUpdateMessage(loginMessage *LoginMessage, fingerprint string, contentUrl string) *LoginFailedMessage {
+	return &LoginFailedMessage{
+		loginMessage: loginMessage,
+		reason:       messaging.UpdateAvailable,
+		fingerprint:  fingerprint,
+		contentUrl:   contentUrl,
+	}
+}
+
+// NewLoginFailedVersionMessage tells a client older than the supported versions to update from
+// updateUrl.
+func NewLoginFailedVersionMessage(loginMessage *LoginMessage, updateUrl string) *LoginFailedMessage {
+	return &LoginFailedMessage{
+		loginMessage: loginMessage,
+		text:         "A new version is available, please update the game.",
+		reason:       messaging.UpdateAvailable,
+		updateUrl:    updateUrl,
+	}
+}
+
+// NewLoginFailedMaintenanceMessage tells a client the server is under maintenance, counting
+// down seconds until it is expected to end. loginMessage may be nil for players that are
+// disconnected after logging in.
+func NewLoginFailedMaintenanceMessage(loginMessage *LoginMessage, seconds int32) *LoginFailedMessage {
+	return &LoginFailedMessage{
+		loginMessage:       loginMessage,
+		reason:             messaging.MaintenanceBreak,
+		maintenanceSeconds: seconds,
+	}
+}
+
 func (l *LoginFailedMessage) PacketId() uint16 {
 	return 20103
 }
@@ -32,15 +71,35 @@ func (l *LoginFailedMessage) Marshal() []byte {
 
 	stream.Write(int32(l.reason))
 
-	stream.Write(l.loginMessage.FingerprintSha)
+	fingerprint := ""
+	contentUrl := "https://game-assets.brawlstarsgame.com"
+	updateUrl := "https://github.com/szcvak/sps"
+
+	if l.loginMessage != nil {
+		fingerprint = l.loginMessage.FingerprintSha
+	}
+
+	if l.fingerprint != "" {
+		fingerprint = l.fingerprint
+	}
+
+	if l.contentUrl != "" {
+		contentUrl = l.contentUrl
+	}
+
+	if l.updateUrl != "" {
+		updateUrl = l.updateUrl
+	}
+
+	stream.Write(fingerprint)
 
 	stream.Write("prod.sps.q4.lol:9339")
-	stream.Write("https://game-assets.brawlstarsgame.com")
-	stream.Write("https://github.com/szcvak/sps")
+	stream.Write(contentUrl)
+	stream.Write(updateUrl)
 
 	stream.Write(l.text)
 
-	stream.Write(0)
+	stream.Write(l.maintenanceSeconds)
 	stream.Write(false)
 
 	stream.Write(core.EmptyString)
@@ -4,20 +4,32 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
+	"sync"
 
+	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
 	"github.com/szcvak/sps/pkg/hub"
 	"github.com/szcvak/sps/pkg/messaging"
 )
 
+const internationalRegion = "_INT"
+
+// LoggedInUser is a player who is online, with the ids and token they logged in with. Player
+// stays set after the connection lets go of it, for the goroutines that look players up here.
+type LoggedInUser struct {
+	HighId  int32
+	LowId   int32
+	Token   string
+	Player  *core.Player
+	Wrapper *core.ClientWrapper
+}
+
 var (
-	LoggedInUsers = []struct {
-		HighId int32
-		LowId int32
-		Token string
-		Wrapper *core.ClientWrapper
-	}{}
+	loggedInUsers   = []LoggedInUser{}
+	loggedInUsersMu sync.RWMutex
 )
 
 type LoginMessage struct {
@@ -80,27 +92,48 @@ func (l *LoginMessage) Process(wrapper *core.ClientWrapper, dbm *database.Manage
 		return
 	}
 	
-	duplicate := false
-	
-	for _, x := range LoggedInUsers {
-		if l.Token == x.Token || (l.HighId == x.HighId && l.LowId == x.LowId) {
-			duplicate = true
-			break
+	if !allowVersion(l, wrapper) {
+		return
+	}
+
+	if server := config.Get().Server; server.CheckFingerprint && l.FingerprintSha != "" {
+		gameData := csv.Data()
+
+		if l.FingerprintSha != gameData.Fingerprint().Sha {
+			slog.Info("sending content update", "clientSha", l.FingerprintSha, "serverSha", gameData.Fingerprint().Sha)
+
+			failMsg := NewLoginFailedUpdateMessage(l, string(gameData.FingerprintJson()), server.PatchUrl)
+			wrapper.Send(failMsg.PacketId(), failMsg.PacketVersion(), failMsg.Marshal())
+
+			return
 		}
 	}
-	
-	if duplicate {
+
+	if l.loggedIn() {
 		failMsg := NewLoginFailedMessage(l, "You are already logged in somewhere else.", messaging.LoginFailed)
 		wrapper.Send(failMsg.PacketId(), failMsg.PacketVersion(), failMsg.Marshal())
 		return
 	}
 
-	player, err := dbm.LoadPlayerByToken(context.Background(), l.Token)
+	player, err := l.loadPlayer(dbm)
 	isNew := false
 
 	if err != nil {
+		if errors.Is(err, database.ErrPlayerNotFound) && l.claimsIds() {
+			slog.Warn("refusing login with unknown token", "highId", l.HighId, "lowId", l.LowId)
+
+			failMsg := NewLoginFailedMessage(l, "This account does not exist on this server. Clear the game data to start a new one.", messaging.LoginFailed)
+			wrapper.Send(failMsg.PacketId(), failMsg.PacketVersion(), failMsg.Marshal())
+
+			return
+		}
+
 		if errors.Is(err, database.ErrPlayerNotFound) {
-			temp, err := dbm.CreatePlayer(context.Background(), l.HighId, l.LowId, "Undefined", l.Token, l.Region)
+			if !allowDuringMaintenance(l, wrapper, nil) {
+				return
+			}
+
+			temp, err := l.createPlayer(dbm)
 
 			if err != nil {
 				slog.Error("failed to create player!", "err", err)
@@ -122,16 +155,32 @@ func (l *LoginMessage) Process(wrapper *core.ClientWrapper, dbm *database.Manage
 			return
 		}
 	}
+
+	if l.claimsIds() && (l.HighId != player.HighId || l.LowId != player.LowId) {
+		slog.Warn("refusing login with mismatched ids", "playerId", player.DbId, "claimedHighId", l.HighId, "claimedLowId", l.LowId)
+
+		failMsg := NewLoginFailedMessage(l, "Your account could not be verified. Clear the game data to start a new one.", messaging.LoginFailed)
+		wrapper.Send(failMsg.PacketId(), failMsg.PacketVersion(), failMsg.Marshal())
+
+		return
+	}
+
+	// the ids and token the server allocated are sent back with LoginOk for the client to keep
+	l.HighId, l.LowId, l.Token = player.HighId, player.LowId, player.Token
+
+	if !isNew && !allowDuringMaintenance(l, wrapper, player) {
+		return
+	}
+
+	if !allowSanctioned(l, wrapper, player) {
+		return
+	}
 	
-	LoggedInUsers = append(LoggedInUsers, struct {
-		HighId int32
-		LowId int32
-		Token string
-		Wrapper *core.ClientWrapper
-	}{
-		HighId: l.HighId,
-		LowId: l.LowId,
-		Token: l.Token,
+	addLoggedInUser(LoggedInUser{
+		HighId:  l.HighId,
+		LowId:   l.LowId,
+		Token:   l.Token,
+		Player:  player,
 		Wrapper: wrapper,
 	})
 
@@ -173,3 +222,78 @@ func (l *LoginMessage) Process(wrapper *core.ClientWrapper, dbm *database.Manage
 		wrapper.Send(msg5.PacketId(), msg5.PacketVersion(), msg5.Marshal())
 	}
 }
+
+// LoggedInUsers returns the players who are online right now.
+func LoggedInUsers() []LoggedInUser {
+	loggedInUsersMu.RLock()
+	defer loggedInUsersMu.RUnlock()
+
+	return slices.Clone(loggedInUsers)
+}
+
+// RemoveLoggedInUser takes the player of a connection that ended off the online list.
+func RemoveLoggedInUser(wrapper *core.ClientWrapper) {
+	loggedInUsersMu.Lock()
+	defer loggedInUsersMu.Unlock()
+
+	loggedInUsers = slices.DeleteFunc(loggedInUsers, func(user LoggedInUser) bool {
+		return user.Wrapper == wrapper
+	})
+}
+
+func addLoggedInUser(user LoggedInUser) {
+	loggedInUsersMu.Lock()
+	defer loggedInUsersMu.Unlock()
+
+	loggedInUsers = append(loggedInUsers, user)
+}
+
+// loggedIn tells whether the account of the login is already online.
+func (l *LoginMessage) loggedIn() bool {
+	loggedInUsersMu.RLock()
+	defer loggedInUsersMu.RUnlock()
+
+	return slices.ContainsFunc(loggedInUsers, func(user LoggedInUser) bool {
+		return (l.Token != "" && l.Token == user.Token) || (l.claimsIds() && l.HighId == user.HighId && l.LowId == user.LowId)
+	})
+}
+
+// claimsIds tells whether the client sent the ids of an account, which new installs do not.
+func (l *LoginMessage) claimsIds() bool {
+	return l.HighId != 0 || l.LowId != 0
+}
+
+// loadPlayer loads the account of the login token. Clients without a token have no account.
+func (l *LoginMessage) loadPlayer(dbm *database.Manager) (*core.Player, error) {
+	if l.Token == "" {
+		return nil, database.ErrPlayerNotFound
+	}
+
+	return dbm.LoadPlayerByToken(context.Background(), l.Token)
+}
+
+// createPlayer creates an account for a new install, with a token of its own when the client
+// did not bring one.
+func (l *LoginMessage) createPlayer(dbm *database.Manager) (*core.Player, error) {
+	token := l.Token
+
+	if token == "" {
+		var err error
+
+		if token, err = messaging.NewToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	return dbm.CreatePlayer(context.Background(), "Undefined", token, playerRegion(l.Region))
+}
+
+// playerRegion keeps the region the client reports when regions.csv knows it and falls back to
+// the international region otherwise, so leaderboards only ever group players by real regions.
+func playerRegion(region string) string {
+	if csv.IsRegion(region) {
+		return region
+	}
+
+	return internationalRegion
+}
@@ -0,0 +1,80 @@
+package messages
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+// DisconnectForMaintenance sends every online player that is not a tester the maintenance
+// message and closes their connection. The connection handler cleans up after them as for any
+// other disconnect.
+func DisconnectForMaintenance() {
+	access := config.Get().Access
+
+	if !access.Maintenance {
+		return
+	}
+
+	seconds := access.MaintenanceSecondsLeft(time.Now())
+	disconnected := 0
+
+	for _, user := range LoggedInUsers() {
+		if access.IsTester(user.HighId, user.LowId) {
+			continue
+		}
+
+		msg := NewLoginFailedMaintenanceMessage(nil, seconds)
+		user.Wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+		user.Wrapper.Close()
+
+		disconnected++
+	}
+
+	slog.Info("disconnected players for maintenance", "count", disconnected)
+}
+
+// --- Helper functions --- //
+
+// allowVersion tells whether the client version of a login is supported, sending the client
+// the reason when it is not.
+func allowVersion(l *LoginMessage, wrapper *core.ClientWrapper) bool {
+	access := config.Get().Access
+
+	switch access.SupportsVersion(l.Major, l.Minor, l.Build) {
+	case -1:
+		slog.Info("refusing outdated client", "version", []int32{l.Major, l.Minor, l.Build})
+
+		msg := NewLoginFailedVersionMessage(l, access.UpdateUrl)
+		wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+		return false
+	case 1:
+		slog.Info("refusing unsupported client", "version", []int32{l.Major, l.Minor, l.Build})
+
+		msg := NewLoginFailedMessage(l, "This version of the game is not supported yet.", messaging.LoginFailed)
+		wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+		return false
+	}
+
+	return true
+}
+
+// allowDuringMaintenance tells whether player may log in, which is always the case outside of
+// maintenance and only for testers during it. A nil player is an account yet to be created.
+func allowDuringMaintenance(l *LoginMessage, wrapper *core.ClientWrapper, player *core.Player) bool {
+	access := config.Get().Access
+
+	if !access.Maintenance || (player != nil && access.IsTester(player.HighId, player.LowId)) {
+		return true
+	}
+
+	msg := NewLoginFailedMaintenanceMessage(l, access.MaintenanceSecondsLeft(time.Now()))
+	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+	return false
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
 )
 
 type OwnHomeDataMessage struct {
@@ -42,12 +44,13 @@ func (o *OwnHomeDataMessage) Marshal() []byte {
 
 	stream := core.NewByteStreamWithCapacity(128)
 
+	gameplay := config.Get().Gameplay
 	brawlerTrophies := 0
 
-	if config.MaximumRank <= 34 {
-		brawlerTrophies = progressStart[config.MaximumRank-1]
+	if gameplay.MaximumRank <= 34 {
+		brawlerTrophies = progressStart[gameplay.MaximumRank-1]
 	} else {
-		brawlerTrophies = progressStart[33] + 50*(config.MaximumRank-1)
+		brawlerTrophies = progressStart[33] + 50*(gameplay.MaximumRank-1)
 	}
 
 	stream.Write(core.VInt(2025111)) // timestamp
@@ -111,26 +114,21 @@ func (o *OwnHomeDataMessage) Marshal() []byte {
 
 	stream.Write(core.VInt(player.ControlMode))
 	stream.Write(player.BattleHints)
-	stream.Write(core.VInt(player.CoinDoubler))
+	stream.Write(core.VInt(player.Boost(core.BoostCoinDoubler).Remaining))
 
 	// coin booster
 
-	now := time.Now().Unix()
+	stream.Write(core.VInt(int32(player.Boost(core.BoostCoinBooster).TimeLeft(time.Now()).Seconds())))
 
-	if int64(player.CoinBooster)-now > 0 {
-		stream.Write(core.VInt(int64(player.CoinBooster) - now))
-	} else {
-		stream.Write(core.VInt(0))
-		player.CoinBooster = int32(now)
+	// end
 
-		if err := o.dbm.Exec("update players set coin_booster = $1 where id = $2", player.CoinBooster, player.DbId); err != nil {
-			slog.Error("failed to update coin_booster!", "playerId", player.DbId, "err", err)
-		}
-	}
+	seasonEnd := int64(gameplay.SeasonEndTime)
 
-	// end
+	if end := o.dbm.SeasonEnd(); !end.IsZero() {
+		seasonEnd = max(int64(time.Until(end).Seconds()), 0)
+	}
 
-	stream.Write(core.VInt(config.SeasonEndTime))
+	stream.Write(core.VInt(seasonEnd)) // season end timer
 	stream.Write(false)
 
 	stream.Write(core.LogicLong{0, 1})
@@ -140,7 +138,11 @@ func (o *OwnHomeDataMessage) Marshal() []byte {
 
 	stream.Write(core.DataRef{0, 1})
 
-	stream.Write(core.VInt(0))
+	// shop
+
+	messaging.EmbedShop(stream, player)
+
+	// end
 
 	stream.Write(true)
 	stream.Write(true)
@@ -173,15 +175,15 @@ func (o *OwnHomeDataMessage) Marshal() []byte {
 
 	// end
 
-	stream.Write(core.VInt(config.MaximumUpgradeLevel))
+	stream.Write(core.VInt(gameplay.MaximumUpgradeLevel))
 
-	for i := 0; i < config.MaximumUpgradeLevel; i++ {
+	for i := 0; i < gameplay.MaximumUpgradeLevel; i++ {
 		stream.Write(core.VInt(i + 1))
 	}
 
 	// milestones
 
-	core.EmbedMilestones(stream)
+	core.EmbedMilestones(stream, messaging.MilestoneClaims(player))
 
 	// end
 
@@ -204,7 +206,9 @@ func (o *OwnHomeDataMessage) Marshal() []byte {
 	stream.Write(core.VInt(5))
 
 	cards := make(map[int32]int32)
+	gameData := csv.Data()
 
+	// only unlock cards are kept in Cards, upgrade cards follow the power level
 	for _, data := range player.Brawlers {
 		for card, amt := range data.Cards {
 			id, e := strconv.Atoi(card)
@@ -214,7 +218,13 @@ func (o *OwnHomeDataMessage) Marshal() []byte {
 				continue
 			}
 
-			cards[int32(id)] = amt
+			if gameData.IsCardUnlocked(id) {
+				cards[int32(id)] = amt
+			}
+		}
+
+		for card, level := range messaging.UpgradeCardLevels(gameData, data) {
+			cards[card] = level
 		}
 	}
 
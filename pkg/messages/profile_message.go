@@ -3,10 +3,8 @@ package messages
 import (
 	"context"
 	"log/slog"
-	"strconv"
 
 	"github.com/szcvak/sps/pkg/core"
-	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
 )
 
@@ -48,22 +46,7 @@ func (p *ProfileMessage) Marshal() []byte {
 		stream.Write(core.VInt(data.Trophies))
 		stream.Write(core.VInt(data.HighestTrophies))
 
-		var powerLevel int32 = 0
-
-		for card, amt := range data.Cards {
-			id, err := strconv.Atoi(card)
-
-			if err != nil {
-				slog.Error("failed to convert card to int!", "err", err)
-				continue
-			}
-
-			if !csv.IsCardUnlocked(id) {
-				powerLevel += int32(amt)
-			}
-		}
-
-		stream.Write(core.VInt(powerLevel))
+		stream.Write(core.VInt(max(data.PowerLevel-1, 0)))
 	}
 
 	// stats
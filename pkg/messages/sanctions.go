@@ -0,0 +1,86 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
+)
+
+// ApplySanctions reloads the sanctions of a player who is online. Bans and suspensions end their
+// session right away, mutes and leaderboard exclusions apply from their next message on.
+func ApplySanctions(dbm *database.Manager, playerId int64) {
+	for _, user := range LoggedInUsers() {
+		if user.Player.DbId != playerId {
+			continue
+		}
+
+		sanctions, err := dbm.ActiveSanctions(context.Background(), playerId)
+
+		if err != nil {
+			slog.Error("failed to reload sanctions!", "playerId", playerId, "err", err)
+			return
+		}
+
+		user.Player.SetSanctions(sanctions)
+
+		if !allowSanctioned(nil, user.Wrapper, user.Player) {
+			user.Wrapper.Close()
+			slog.Info("disconnected sanctioned player", "playerId", playerId)
+		}
+
+		return
+	}
+}
+
+// --- Helper functions --- //
+
+// allowSanctioned tells whether player may play, sending the client the ban or suspension that
+// keeps them from it.
+func allowSanctioned(l *LoginMessage, wrapper *core.ClientWrapper, player *core.Player) bool {
+	now := time.Now()
+
+	if ban := player.Sanction(core.SanctionBan, now); ban != nil {
+		msg := NewLoginFailedMessage(l, sanctionText("You have been banned", ban), messaging.Banned)
+		wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+		return false
+	}
+
+	if suspension := player.Sanction(core.SanctionSuspension, now); suspension != nil {
+		msg := NewLoginFailedMessage(l, sanctionText("Your account has been suspended", suspension), messaging.AccountLocked)
+		wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+		return false
+	}
+
+	return true
+}
+
+// muted tells whether player is muted, logging the message that was dropped because of it.
+func muted(player *core.Player, content string) bool {
+	if player.Sanction(core.SanctionMute, time.Now()) == nil {
+		return false
+	}
+
+	slog.Info("dropping chat message from muted player", "playerId", player.DbId, "content", content)
+
+	return true
+}
+
+func sanctionText(text string, sanction *core.PlayerSanction) string {
+	if sanction.ExpiresAt != nil {
+		text += fmt.Sprintf(" until %s", sanction.ExpiresAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+
+	if sanction.Reason != "" {
+		text += ". Reason: " + strings.TrimSuffix(sanction.Reason, ".")
+	}
+
+	return text + "."
+}
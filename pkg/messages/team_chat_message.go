@@ -21,7 +21,7 @@ func (t *TeamChatMessage) Unmarshal(data []byte) {
 }
 
 func (t *TeamChatMessage) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
-	if wrapper.Player.TeamId == nil {
+	if wrapper.Player.TeamId == nil || muted(wrapper.Player, t.message) {
 		return
 	}
 	
@@ -49,12 +49,13 @@ func (t *TeamMessage) Marshal() []byte {
 		return []byte{}
 	}
 
+	gameplay := config.Get().Gameplay
 	brawlerTrophies := 0
 
-	if config.MaximumRank <= 34 {
-		brawlerTrophies = progressStart[config.MaximumRank-1]
+	if gameplay.MaximumRank <= 34 {
+		brawlerTrophies = progressStart[gameplay.MaximumRank-1]
 	} else {
-		brawlerTrophies = progressStart[33] + 50*(config.MaximumRank-1)
+		brawlerTrophies = progressStart[33] + 50*(gameplay.MaximumRank-1)
 	}
 
 	em := core.GetEventManager()
@@ -87,7 +88,7 @@ func (t *TeamMessage) Marshal() []byte {
 		stream.Write(member.SelectedSkin)
 		stream.Write(core.VInt(brawlerTrophies))
 		stream.Write(core.VInt(brawlerTrophies))
-		stream.Write(core.VInt(config.MaximumRank))
+		stream.Write(core.VInt(gameplay.MaximumRank))
 		stream.Write(core.VInt(member.Status)) // 0=offline, 1=in battle, 2=other screen, 3=present, 4=in matchmake
 		stream.Write(core.VInt(0))
 		stream.Write(member.IsReady)
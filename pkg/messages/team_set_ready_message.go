@@ -3,6 +3,7 @@ package messages
 import (
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/database"
+	"github.com/szcvak/sps/pkg/messaging"
 	"log/slog"
 )
 
@@ -27,12 +28,29 @@ func (t *TeamSetReadyMessage) Process(wrapper *core.ClientWrapper, dbm *database
 		return
 	}
 	
+	tm := core.GetTeamManager()
+	team := tm.Teams[*wrapper.Player.TeamId]
+
+	if team == nil {
+		return
+	}
+
+	if t.ready {
+		if err := messaging.EnterTicketedEvent(wrapper.Player, messaging.NewDatabaseRewardStore(dbm), team.Event-1); err != nil {
+			slog.Error("failed to enter ticketed event!", "playerId", wrapper.Player.DbId, "event", team.Event, "err", err)
+
+			msg := NewTeamMessage(wrapper)
+			wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+
+			return
+		}
+	}
+
 	slog.Info("changed team ready status", "playerId", wrapper.Player.DbId, "isReady", t.ready)
 
-	tm := core.GetTeamManager()
 	tm.UpdateReady(wrapper.Player, t.ready)
 
-	for _, member := range tm.Teams[*wrapper.Player.TeamId].Members {
+	for _, member := range team.Members {
 		if member.Wrapper != nil {
 			msg := NewTeamMessage(member.Wrapper)
 			payload := msg.Marshal()
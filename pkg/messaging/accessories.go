@@ -0,0 +1,310 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync/atomic"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+// Star powers, gadgets and gears are only kept on the server. The client this server speaks
+// predates them and has no place for them in home data, profiles or team data, nor commands
+// to buy or select them, so they are neither sent nor reachable from it. The boxes shipped in
+// assets do not drop them for the same reason.
+
+var (
+	ErrAccessoryUnknown     = errors.New("accessory does not exist")
+	ErrAccessoryNotFitting  = errors.New("accessory does not fit the brawler")
+	ErrAccessoryLocked      = errors.New("brawler level is too low for the accessory")
+	ErrAccessoryOwned       = errors.New("accessory is already unlocked")
+	ErrAccessoryNotUnlocked = errors.New("accessory is not unlocked")
+	ErrInvalidGearSlot      = errors.New("invalid gear slot")
+)
+
+type AccessoryDefinition struct {
+	Id   int32  `json:"id"`
+	Name string `json:"name"`
+
+	// BrawlerId is the character the accessory is made for. Gears fit every brawler and leave
+	// it out.
+	BrawlerId *int32 `json:"brawler_id,omitempty"`
+
+	RequiredLevel int32 `json:"required_level"`
+	CurrencyId    int32 `json:"currency_id"`
+	Price         int32 `json:"price"`
+
+	Kind core.AccessoryKind `json:"-"`
+}
+
+// AccessoryTable holds every star power, gadget and gear. Ids are shared by all three kinds
+// and sent to the client as cards.
+type AccessoryTable struct {
+	StarPowers []AccessoryDefinition `json:"star_powers"`
+	Gadgets    []AccessoryDefinition `json:"gadgets"`
+	Gears      []AccessoryDefinition `json:"gears"`
+
+	accessories map[int32]*AccessoryDefinition
+}
+
+var accessoryTable atomic.Pointer[AccessoryTable]
+
+// LoadAccessories reads the accessory definitions from path and swaps them in. The previous
+// table is kept if the file cannot be read or is invalid.
+func LoadAccessories(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read accessory definitions: %w", err)
+	}
+
+	table := &AccessoryTable{}
+
+	if err = json.Unmarshal(data, table); err != nil {
+		return fmt.Errorf("failed to parse accessory definitions: %w", err)
+	}
+
+	if err = table.validate(); err != nil {
+		return fmt.Errorf("invalid accessory definitions: %w", err)
+	}
+
+	accessoryTable.Store(table)
+
+	slog.Info("loaded accessory definitions", "path", path, "starPowers", len(table.StarPowers), "gadgets", len(table.Gadgets), "gears", len(table.Gears))
+
+	return nil
+}
+
+// Accessories returns the live accessory definitions, or nil if LoadAccessories has not
+// succeeded yet.
+func Accessories() *AccessoryTable {
+	return accessoryTable.Load()
+}
+
+func (t *AccessoryTable) Accessory(id int32) (*AccessoryDefinition, bool) {
+	accessory, exists := t.accessories[id]
+	return accessory, exists
+}
+
+func (a *AccessoryDefinition) Fits(brawlerId int32) bool {
+	return a.BrawlerId == nil || *a.BrawlerId == brawlerId
+}
+
+// BuyAccessory unlocks an accessory for a brawler at its price.
+func (d *DeliveryLogic) BuyAccessory(brawlerId int32, id int32) error {
+	brawler, accessory, err := d.checkAccessoryUnlock(brawlerId, id)
+
+	if err != nil {
+		return err
+	}
+
+	if err = d.Spend(accessory.CurrencyId, int64(accessory.Price)); err != nil {
+		return err
+	}
+
+	if _, err = d.grantAccessory(brawler, accessory, 0); err != nil {
+		if refundErr := d.addCurrency(accessory.CurrencyId, accessory.Price); refundErr != nil {
+			slog.Error("failed to refund accessory!", "playerId", d.player.DbId, "accessory", id, "err", refundErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// SelectAccessory equips an unlocked accessory. slot picks the gear slot, 1 or 2, and is
+// ignored for star powers and gadgets. A gear moved from the other slot leaves it empty.
+func (d *DeliveryLogic) SelectAccessory(brawlerId int32, id int32, slot int32) error {
+	brawler, exists := d.player.Brawlers[brawlerId]
+
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrBrawlerNotUnlocked, brawlerId)
+	}
+
+	accessory, err := d.accessory(id)
+
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(*brawler.Unlocked(accessory.Kind), id) {
+		return fmt.Errorf("%w: %d", ErrAccessoryNotUnlocked, id)
+	}
+
+	previous := *brawler
+
+	switch accessory.Kind {
+	case core.AccessoryStarPower:
+		brawler.SelectedStarPower = &id
+	case core.AccessoryGadget:
+		brawler.SelectedGadget = &id
+	case core.AccessoryGear:
+		selected, other := &brawler.SelectedGear1, &brawler.SelectedGear2
+
+		if slot == 2 {
+			selected, other = other, selected
+		} else if slot != 1 {
+			return fmt.Errorf("%w: %d", ErrInvalidGearSlot, slot)
+		}
+
+		if *other != nil && **other == id {
+			*other = nil
+		}
+
+		*selected = &id
+	}
+
+	if err = d.store.UpdateBrawler(d.player, brawler); err != nil {
+		*brawler = previous
+		return fmt.Errorf("failed to update brawler: %w", err)
+	}
+
+	return nil
+}
+
+// --- Private methods --- //
+
+func (d *DeliveryLogic) accessory(id int32) (*AccessoryDefinition, error) {
+	table := Accessories()
+
+	if table == nil {
+		return nil, errors.New("accessory definitions are not loaded")
+	}
+
+	accessory, exists := table.Accessory(id)
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrAccessoryUnknown, id)
+	}
+
+	return accessory, nil
+}
+
+func (d *DeliveryLogic) checkAccessoryUnlock(brawlerId int32, id int32) (*core.PlayerBrawler, *AccessoryDefinition, error) {
+	brawler, exists := d.player.Brawlers[brawlerId]
+
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: %d", ErrBrawlerNotUnlocked, brawlerId)
+	}
+
+	accessory, err := d.accessory(id)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !accessory.Fits(brawlerId) {
+		return nil, nil, fmt.Errorf("%w: %d", ErrAccessoryNotFitting, id)
+	}
+
+	if brawler.PowerLevel < accessory.RequiredLevel {
+		return nil, nil, fmt.Errorf("%w: need %d, have %d", ErrAccessoryLocked, accessory.RequiredLevel, brawler.PowerLevel)
+	}
+
+	if slices.Contains(*brawler.Unlocked(accessory.Kind), id) {
+		return nil, nil, fmt.Errorf("%w: %d", ErrAccessoryOwned, id)
+	}
+
+	return brawler, accessory, nil
+}
+
+// droppableAccessories returns the star powers and gadgets the player could unlock right now.
+// Gears are only sold.
+func (d *DeliveryLogic) droppableAccessories() []*AccessoryDefinition {
+	table := Accessories()
+
+	if table == nil {
+		return nil
+	}
+
+	droppable := make([]*AccessoryDefinition, 0)
+
+	for _, list := range [][]AccessoryDefinition{table.StarPowers, table.Gadgets} {
+		for i := range list {
+			accessory := &list[i]
+
+			if _, _, err := d.checkAccessoryUnlock(*accessory.BrawlerId, accessory.Id); err == nil {
+				droppable = append(droppable, accessory)
+			}
+		}
+	}
+
+	return droppable
+}
+
+func (d *DeliveryLogic) grantAccessory(brawler *core.PlayerBrawler, accessory *AccessoryDefinition, rarity int32) (*RewardItem, error) {
+	if err := d.store.UnlockAccessory(d.player, brawler, accessory.Kind, accessory.Id); err != nil {
+		slog.Error("failed to unlock accessory!", "playerId", d.player.DbId, "brawler", brawler.BrawlerId, "accessory", accessory.Id, "err", err)
+		return nil, fmt.Errorf("error granting accessory: %w", err)
+	}
+
+	unlocked := brawler.Unlocked(accessory.Kind)
+	*unlocked = append(*unlocked, accessory.Id)
+
+	slog.Info("unlocked accessory", "playerId", d.player.DbId, "brawler", brawler.BrawlerId, "accessory", accessory.Id)
+
+	return &RewardItem{
+		Rarity:   rarity,
+		Amount:   1,
+		RewardId: RewardIdAccessory,
+		DataRef:  core.DataRef{DataRefClassCard, accessory.Id},
+	}, nil
+}
+
+func (t *AccessoryTable) validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	t.accessories = make(map[int32]*AccessoryDefinition)
+
+	lists := []struct {
+		name string
+		kind core.AccessoryKind
+		list []AccessoryDefinition
+	}{
+		{"star_powers", core.AccessoryStarPower, t.StarPowers},
+		{"gadgets", core.AccessoryGadget, t.Gadgets},
+		{"gears", core.AccessoryGear, t.Gears},
+	}
+
+	for _, l := range lists {
+		for i := range l.list {
+			accessory := &l.list[i]
+			accessory.Kind = l.kind
+
+			_, duplicate := t.accessories[accessory.Id]
+
+			check(accessory.Id > 0, "%s[%d].id must be positive", l.name, i)
+			check(!duplicate, "%s[%d].id %d is defined twice", l.name, i, accessory.Id)
+			check(accessory.Name != "", "%s[%d].name must not be empty", l.name, i)
+			check(accessory.RequiredLevel >= 1 && accessory.RequiredLevel <= 11, "%s[%d].required_level must be between 1 and 11", l.name, i)
+			check(accessory.CurrencyId == config.CurrencyCoins || accessory.CurrencyId == config.CurrencyGems, "%s[%d].currency_id must be coins or gems", l.name, i)
+			check(accessory.Price >= 0, "%s[%d].price must not be negative", l.name, i)
+
+			if l.kind == core.AccessoryGear {
+				check(accessory.BrawlerId == nil, "%s[%d].brawler_id must not be set for gears", l.name, i)
+			} else if accessory.BrawlerId == nil {
+				check(false, "%s[%d].brawler_id must be set", l.name, i)
+			} else {
+				_, found := csv.GetCardForCharacter(*accessory.BrawlerId)
+				check(found, "%s[%d].brawler_id %d is not a brawler", l.name, i, *accessory.BrawlerId)
+			}
+
+			t.accessories[accessory.Id] = accessory
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+const DataRefClassAllianceBadge int32 = 8
+
+var ErrInvalidBadge = errors.New("invalid alliance badge")
+
+// AllianceCreationCost returns the currency and the price of creating an alliance, as set by
+// ALLIANCE_CREATE_RESOURCE and ALLIANCE_CREATE_COST in globals.csv.
+func AllianceCreationCost() (int32, int64, error) {
+	cost, _ := csv.GetGlobalInt("ALLIANCE_CREATE_COST")
+
+	if cost <= 0 {
+		return 0, 0, nil
+	}
+
+	resource, _ := csv.GetGlobalText("ALLIANCE_CREATE_RESOURCE")
+	currencyId, exists := ResourceCurrency(resource)
+
+	if !exists {
+		return 0, 0, fmt.Errorf("alliance creation costs unknown resource %q", resource)
+	}
+
+	return currencyId, int64(cost), nil
+}
+
+// ValidateAllianceBadge checks that a badge picked by a client is one of alliance_badges.csv.
+func ValidateAllianceBadge(badge core.DataRef) error {
+	if badge.F != DataRefClassAllianceBadge || !csv.IsAllianceBadge(badge.S) {
+		return fmt.Errorf("%w: %d %d", ErrInvalidBadge, badge.F, badge.S)
+	}
+
+	return nil
+}
+
+// PayForAlliance charges the alliance creation cost, runs create and refunds the cost when
+// create fails.
+func PayForAlliance(player *core.Player, store RewardStore, create func() error) error {
+	currencyId, cost, err := AllianceCreationCost()
+
+	if err != nil {
+		return err
+	}
+
+	if cost == 0 {
+		return create()
+	}
+
+	d := NewDeliveryLogicWithStore(player, store)
+
+	if err = d.Spend(currencyId, cost); err != nil {
+		return err
+	}
+
+	if err = create(); err != nil {
+		if refundErr := d.addCurrency(currencyId, int32(cost)); refundErr != nil {
+			slog.Error("failed to refund alliance creation!", "playerId", player.DbId, "err", refundErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
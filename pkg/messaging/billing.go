@@ -0,0 +1,173 @@
+package messaging
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+const (
+	BillingVerifierNone = "none"
+	BillingVerifierFake = "fake"
+)
+
+var (
+	ErrBillingDisabled       = errors.New("purchases are disabled")
+	ErrBillingPackageUnknown = errors.New("billing package does not exist")
+	ErrReceiptInvalid        = errors.New("receipt is invalid")
+	ErrReceiptUsed           = errors.New("receipt has already been used")
+)
+
+// Receipt is what the client sends as proof of a store purchase.
+type Receipt struct {
+	ProductId string
+	Data      string
+}
+
+// VerifiedReceipt is a receipt the store confirmed. ReceiptId must be unique per purchase, it
+// is what keeps a receipt from being redeemed twice.
+type VerifiedReceipt struct {
+	ReceiptId string
+	ProductId string
+}
+
+// ReceiptVerifier checks receipts with a store. A verifier for a real store would call its
+// validation API, the fake one accepts everything.
+type ReceiptVerifier interface {
+	Verify(ctx context.Context, receipt Receipt) (*VerifiedReceipt, error)
+}
+
+// FakeReceiptVerifier accepts any non-empty receipt for the product it names. Receipts are
+// identified by their data, so sending the same one twice still only grants once. It must
+// never be used on a server with real players.
+type FakeReceiptVerifier struct{}
+
+var receiptVerifier atomic.Pointer[ReceiptVerifier]
+
+func NewFakeReceiptVerifier() *FakeReceiptVerifier {
+	return &FakeReceiptVerifier{}
+}
+
+func (v *FakeReceiptVerifier) Verify(_ context.Context, receipt Receipt) (*VerifiedReceipt, error) {
+	if receipt.Data == "" || receipt.ProductId == "" {
+		return nil, ErrReceiptInvalid
+	}
+
+	hash := sha256.Sum256([]byte(receipt.Data))
+
+	return &VerifiedReceipt{
+		ReceiptId: "fake-" + hex.EncodeToString(hash[:]),
+		ProductId: receipt.ProductId,
+	}, nil
+}
+
+// NewReceiptVerifier returns the verifier configured by name, nil for BillingVerifierNone.
+func NewReceiptVerifier(name string) (ReceiptVerifier, error) {
+	switch name {
+	case BillingVerifierNone:
+		return nil, nil
+	case BillingVerifierFake:
+		return NewFakeReceiptVerifier(), nil
+	default:
+		return nil, fmt.Errorf("unknown receipt verifier %q", name)
+	}
+}
+
+// SetReceiptVerifier swaps the verifier used by purchases, nil turns purchases off.
+func SetReceiptVerifier(verifier ReceiptVerifier) {
+	if verifier == nil {
+		receiptVerifier.Store(nil)
+		return
+	}
+
+	receiptVerifier.Store(&verifier)
+}
+
+// BillingPackages returns the gem packs on sale, in shop order. Disabled packages and starter
+// packs are not sold. The client lists the packs from its own copy of billing_packages.csv, so
+// they are not sent to it.
+func BillingPackages() []csv.BillingPackage {
+	packages := make([]csv.BillingPackage, 0)
+
+	for _, id := range csv.BillingPackageIds() {
+		pack, exists := csv.GetBillingPackage(id)
+
+		if !exists || pack.Disabled || pack.StarterPackNumber != 0 || pack.Diamonds <= 0 {
+			continue
+		}
+
+		packages = append(packages, pack)
+	}
+
+	slices.SortFunc(packages, func(a, b csv.BillingPackage) int {
+		return cmp.Or(cmp.Compare(a.Order, b.Order), cmp.Compare(a.Id, b.Id))
+	})
+
+	return packages
+}
+
+// PurchaseBillingPackage verifies receipt and grants the gems of the package with id. A
+// receipt that was already redeemed returns ErrReceiptUsed without granting anything.
+func PurchaseBillingPackage(player *core.Player, store RewardStore, id int32, receipt string) (*csv.BillingPackage, error) {
+	verifier := receiptVerifier.Load()
+
+	if verifier == nil {
+		return nil, ErrBillingDisabled
+	}
+
+	packages := BillingPackages()
+	index := slices.IndexFunc(packages, func(pack csv.BillingPackage) bool { return pack.Id == id })
+
+	if index == -1 {
+		return nil, fmt.Errorf("%w: %d", ErrBillingPackageUnknown, id)
+	}
+
+	pack := packages[index]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	verified, err := (*verifier).Verify(ctx, Receipt{ProductId: pack.ProductId, Data: receipt})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify receipt: %w", err)
+	}
+
+	if verified.ProductId != pack.ProductId {
+		return nil, fmt.Errorf("%w: bought %s, asked for %s", ErrReceiptInvalid, verified.ProductId, pack.ProductId)
+	}
+
+	granted, err := store.GrantPurchase(player, verified.ReceiptId, pack.ProductId, config.CurrencyGems, pack.Diamonds)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant purchase: %w", err)
+	}
+
+	if !granted {
+		return nil, ErrReceiptUsed
+	}
+
+	wallet, exists := player.Wallet[config.CurrencyGems]
+
+	if !exists {
+		wallet = &core.PlayerCurrency{CurrencyId: config.CurrencyGems}
+		player.Wallet[config.CurrencyGems] = wallet
+	}
+
+	wallet.Balance += int64(pack.Diamonds)
+
+	slog.Info("granted purchase", "playerId", player.DbId, "product", pack.ProductId, "receipt", verified.ReceiptId, "gems", pack.Diamonds)
+
+	return &pack, nil
+}
@@ -0,0 +1,45 @@
+package messaging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/szcvak/sps/pkg/core"
+)
+
+// CoinBoosts are the coins boosts add on top of what a battle paid.
+type CoinBoosts struct {
+	Boosted int32
+	Doubled int32
+}
+
+// ApplyCoinBoosts works out the boosted coins of a battle that paid coins, for every game mode.
+// An active coin booster adds the coins again. The coin doubler does the same for as many
+// coins as it has left, and what it doubled is taken from it in the store first, so the
+// doubler is never spent twice.
+func ApplyCoinBoosts(player *core.Player, store RewardStore, coins int32, now time.Time) CoinBoosts {
+	result := CoinBoosts{}
+
+	if coins <= 0 {
+		return result
+	}
+
+	if player.Boost(core.BoostCoinBooster).Active(now) {
+		result.Boosted = coins
+	}
+
+	used, remaining, err := store.ConsumeBoost(player, core.BoostCoinDoubler, coins)
+
+	if err != nil {
+		slog.Error("failed to consume coin doubler!", "playerId", player.DbId, "err", err)
+		return result
+	}
+
+	result.Doubled = used
+
+	if boost, exists := player.Boosts[core.BoostCoinDoubler]; exists {
+		boost.Remaining = remaining
+	}
+
+	return result
+}
@@ -0,0 +1,172 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+type RewardWeights struct {
+	Elixir      uint `json:"elixir"`
+	Brawler     uint `json:"brawler"`
+	CoinBooster uint `json:"coin_booster"`
+	CoinDoubler uint `json:"coin_doubler"`
+	PowerPoints uint `json:"power_points"`
+	Accessory   uint `json:"accessory"`
+}
+
+type BoxRarity struct {
+	Id           int32  `json:"id"`
+	Name         string `json:"name"`
+	ElixirAmount int32  `json:"elixir_amount"`
+	ChipAmount   int32  `json:"chip_amount"`
+
+	// PowerPointsAmount goes to one owned brawler below the maximum upgrade level.
+	PowerPointsAmount int32 `json:"power_points_amount"`
+
+	Weights RewardWeights `json:"weights"`
+}
+
+// PityRule raises the odds of a brawler of Rarity by Increment weight for every reward that
+// was not one. After Guarantee misses the next reward is forced to be one, 0 disables that.
+type PityRule struct {
+	Rarity    int32 `json:"rarity"`
+	Increment uint  `json:"increment"`
+	Guarantee int32 `json:"guarantee"`
+}
+
+type BoxDefinition struct {
+	Type         int32  `json:"type"`
+	Id           int32  `json:"id"`
+	Name         string `json:"name"`
+	Price        int32  `json:"price"`
+	CurrencyId   int32  `json:"currency_id"`
+	RewardsCount int    `json:"rewards_count"`
+
+	// RarityWeights is indexed by rarity id.
+	RarityWeights []uint     `json:"rarity_weights"`
+	Pity          []PityRule `json:"pity"`
+}
+
+type BoxTable struct {
+	CoinBoosterDuration int32 `json:"coin_booster_duration"`
+	CoinDoublerAmount   int32 `json:"coin_doubler_amount"`
+
+	// DuplicateProtection makes brawler rewards pick from the brawlers a player does not own
+	// until every brawler of the rarity is unlocked.
+	DuplicateProtection bool `json:"duplicate_protection"`
+
+	Rarities []BoxRarity     `json:"rarities"`
+	Boxes    []BoxDefinition `json:"boxes"`
+
+	boxes map[int32]*BoxDefinition
+}
+
+var boxTable atomic.Pointer[BoxTable]
+
+// LoadBoxes reads the box definitions from path and swaps them in. The previous table is kept
+// if the file cannot be read or is invalid.
+func LoadBoxes(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read box definitions: %w", err)
+	}
+
+	table := &BoxTable{}
+
+	if err = json.Unmarshal(data, table); err != nil {
+		return fmt.Errorf("failed to parse box definitions: %w", err)
+	}
+
+	if err = table.validate(); err != nil {
+		return fmt.Errorf("invalid box definitions: %w", err)
+	}
+
+	boxTable.Store(table)
+
+	slog.Info("loaded box definitions", "path", path, "boxes", len(table.Boxes))
+
+	return nil
+}
+
+// Boxes returns the live box definitions, or nil if LoadBoxes has not succeeded yet.
+func Boxes() *BoxTable {
+	return boxTable.Load()
+}
+
+func (t *BoxTable) Box(boxType int32) (*BoxDefinition, bool) {
+	box, exists := t.boxes[boxType]
+	return box, exists
+}
+
+func (t *BoxTable) Rarity(id int32) (*BoxRarity, bool) {
+	if id < 0 || int(id) >= len(t.Rarities) {
+		return nil, false
+	}
+
+	return &t.Rarities[id], true
+}
+
+// --- Private methods --- //
+
+func (t *BoxTable) validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(t.CoinBoosterDuration > 0, "coin_booster_duration must be positive")
+	check(t.CoinDoublerAmount > 0, "coin_doubler_amount must be positive")
+	check(len(t.Rarities) > 0, "rarities must not be empty")
+
+	for i, rarity := range t.Rarities {
+		check(rarity.Id == int32(i), "rarities[%d].id must be %d, got %d", i, i, rarity.Id)
+		check(rarity.Name != "", "rarities[%d].name must not be empty", i)
+		check(rarity.ElixirAmount > 0, "rarities[%d].elixir_amount must be positive", i)
+		check(rarity.ChipAmount > 0, "rarities[%d].chip_amount must be positive", i)
+
+		check(rarity.PowerPointsAmount >= 0, "rarities[%d].power_points_amount must not be negative", i)
+
+		w := rarity.Weights
+		check(w.Elixir+w.Brawler+w.CoinBooster+w.CoinDoubler+w.PowerPoints+w.Accessory > 0, "rarities[%d].weights must not all be zero", i)
+		check(w.PowerPoints == 0 || rarity.PowerPointsAmount > 0, "rarities[%d].power_points_amount must be positive when power points can drop", i)
+	}
+
+	t.boxes = make(map[int32]*BoxDefinition, len(t.Boxes))
+
+	for i := range t.Boxes {
+		box := &t.Boxes[i]
+
+		_, duplicate := t.boxes[box.Type]
+		check(!duplicate, "boxes[%d].type %d is defined twice", i, box.Type)
+
+		check(box.Price >= 0, "boxes[%d].price must not be negative", i)
+		check(box.RewardsCount > 0, "boxes[%d].rewards_count must be positive", i)
+		check(len(box.RarityWeights) == len(t.Rarities), "boxes[%d].rarity_weights must have %d entries, got %d", i, len(t.Rarities), len(box.RarityWeights))
+
+		var total uint
+
+		for _, weight := range box.RarityWeights {
+			total += weight
+		}
+
+		check(total > 0, "boxes[%d].rarity_weights must not all be zero", i)
+
+		for j, rule := range box.Pity {
+			_, exists := t.Rarity(rule.Rarity)
+			check(exists, "boxes[%d].pity[%d].rarity %d does not exist", i, j, rule.Rarity)
+			check(rule.Guarantee >= 0, "boxes[%d].pity[%d].guarantee must not be negative", i, j)
+		}
+
+		t.boxes[box.Type] = box
+	}
+
+	return errors.Join(errs...)
+}
@@ -6,11 +6,9 @@ import (
 	"github.com/szcvak/sps/pkg/core"
 	"github.com/szcvak/sps/pkg/csv"
 	"github.com/szcvak/sps/pkg/database"
-	"strconv"
 
 	"encoding/json"
 	"log/slog"
-	"time"
 )
 
 type ServerCommand interface {
@@ -59,6 +57,10 @@ func init() {
 	ClientCommands[511] = func() ClientCommand { return NewClientBuyCoinBooster() }
 	ClientCommands[513] = func() ClientCommand { return NewClientSelectBattleHintsCommand() }
 	ClientCommands[514] = func() ClientCommand { return NewClientBuyBrawlerCommand() }
+	ClientCommands[517] = func() ClientCommand { return NewClientClaimMilestoneCommand() }
+	ClientCommands[519] = func() ClientCommand { return NewClientBuyOfferCommand() }
+	ClientCommands[520] = func() ClientCommand { return NewClientUpgradeBrawlerCommand() }
+	ClientCommands[525] = func() ClientCommand { return NewClientPurchaseBillingPackageCommand() }
 }
 
 // --- Server commands --- //
@@ -126,6 +128,23 @@ type ClientBuyBrawlerCommand struct {
 	boxType core.VInt
 }
 
+type ClientClaimMilestoneCommand struct {
+	milestoneId core.VInt
+}
+
+type ClientBuyOfferCommand struct {
+	offerIndex core.VInt
+}
+
+type ClientUpgradeBrawlerCommand struct {
+	character core.DataRef
+}
+
+type ClientPurchaseBillingPackageCommand struct {
+	packageId core.VInt
+	receipt   string
+}
+
 func NewClientSelectControlModeCommand() *ClientSelectControlModeCommand {
 	return &ClientSelectControlModeCommand{}
 }
@@ -170,6 +189,22 @@ func NewClientBuyBrawlerCommand() *ClientBuyBrawlerCommand {
 	return &ClientBuyBrawlerCommand{}
 }
 
+func NewClientClaimMilestoneCommand() *ClientClaimMilestoneCommand {
+	return &ClientClaimMilestoneCommand{}
+}
+
+func NewClientBuyOfferCommand() *ClientBuyOfferCommand {
+	return &ClientBuyOfferCommand{}
+}
+
+func NewClientUpgradeBrawlerCommand() *ClientUpgradeBrawlerCommand {
+	return &ClientUpgradeBrawlerCommand{}
+}
+
+func NewClientPurchaseBillingPackageCommand() *ClientPurchaseBillingPackageCommand {
+	return &ClientPurchaseBillingPackageCommand{}
+}
+
 // --- Control mode --- //
 
 func (c *ClientSelectControlModeCommand) UnmarshalStream(stream *core.ByteStream) {
@@ -227,9 +262,10 @@ func (c *ClientProfileIconCommand) Process(wrapper *core.ClientWrapper, dbm *dat
 		return
 	}
 
+	gameData := csv.Data()
 	contains := false
 
-	for _, value := range csv.Thumbnails() {
+	for _, value := range gameData.Thumbnails() {
 		if value == c.profileIcon.S {
 			contains = true
 			break
@@ -240,17 +276,17 @@ func (c *ClientProfileIconCommand) Process(wrapper *core.ClientWrapper, dbm *dat
 		return
 	}
 
-	if csv.GetTrophiesForThumbnail(c.profileIcon.S) > wrapper.Player.Trophies {
+	if gameData.GetTrophiesForThumbnail(c.profileIcon.S) > wrapper.Player.Trophies {
 		return
 	}
 
-	_, exists := wrapper.Player.Brawlers[csv.GetCharacterIdByName(csv.GetBrawlerForThumbnail(c.profileIcon.S))]
+	_, exists := wrapper.Player.Brawlers[gameData.GetCharacterIdByName(gameData.GetBrawlerForThumbnail(c.profileIcon.S))]
 
 	if !exists {
 		return
 	}
 
-	requiredExperience := csv.GetExperienceLevelForThumbnail(c.profileIcon.S)
+	requiredExperience := gameData.GetExperienceLevelForThumbnail(c.profileIcon.S)
 	experience := core.RequiredExp[requiredExperience]
 
 	if experience > wrapper.Player.Experience {
@@ -304,12 +340,14 @@ func (c *ClientEventActionCommand) Process(wrapper *core.ClientWrapper, dbm *dat
 
 	slog.Info("giving event coins", "playerId", wrapper.Player.DbId, "amount", amount)
 
-	if err := dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3", wrapper.Player.Wallet[config.CurrencyCoins].Balance+amount, wrapper.Player.DbId, config.CurrencyCoins); err != nil {
+	balance := CapBalance(config.CurrencyCoins, wrapper.Player.Wallet[config.CurrencyCoins].Balance, amount)
+
+	if err := dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3", balance, wrapper.Player.DbId, config.CurrencyCoins); err != nil {
 		slog.Error("failed to update player wallet!", "err", err)
 		return
 	}
 
-	wrapper.Player.Wallet[config.CurrencyCoins].Balance += amount
+	wrapper.Player.Wallet[config.CurrencyCoins].Balance = balance
 	event.SeenBy = append(event.SeenBy, wrapper.Player.DbId)
 }
 
@@ -331,25 +369,17 @@ func (c *ClientSelectBattleHintsCommand) Process(wrapper *core.ClientWrapper, db
 func (c *ClientBuyCoinDoubler) UnmarshalStream(stream *core.ByteStream) {}
 
 func (c *ClientBuyCoinDoubler) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
-	newBalance := wrapper.Player.Wallet[config.CurrencyGems].Balance - config.CoinDoublerPrice
+	economy := config.Get().Economy
+	logic := NewDeliveryLogic(wrapper, dbm)
 
-	if newBalance < 0 {
+	if err := logic.Spend(config.CurrencyGems, economy.CoinDoublerPrice); err != nil {
+		slog.Warn("failed to buy coin doubler", "playerId", wrapper.Player.DbId, "err", err)
 		return
 	}
 
-	newCoinDoubler := wrapper.Player.CoinDoubler + config.CoinDoublerReward
-
-	if err := dbm.Exec("update players set coin_doubler = $1 where id = $2", newCoinDoubler, wrapper.Player.DbId); err != nil {
+	if _, err := logic.grantCoinDoubler(economy.CoinDoublerReward, 0); err != nil {
 		slog.Error("failed to update player's coin doubler!", "err", err)
-		return
 	}
-
-	if err := dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3", newBalance, wrapper.Player.DbId, config.CurrencyGems); err != nil {
-		slog.Error("failed to update player's balance!", "err", err)
-		return
-	}
-
-	wrapper.Player.CoinDoubler = newCoinDoubler
 }
 
 // --- Buy coin booster --- //
@@ -357,30 +387,17 @@ func (c *ClientBuyCoinDoubler) Process(wrapper *core.ClientWrapper, dbm *databas
 func (c *ClientBuyCoinBooster) UnmarshalStream(stream *core.ByteStream) {}
 
 func (c *ClientBuyCoinBooster) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
-	newBalance := wrapper.Player.Wallet[config.CurrencyGems].Balance - config.CoinBoosterPrice
+	economy := config.Get().Economy
+	logic := NewDeliveryLogic(wrapper, dbm)
 
-	if newBalance < 0 {
+	if err := logic.Spend(config.CurrencyGems, economy.CoinBoosterPrice); err != nil {
+		slog.Warn("failed to buy coin booster", "playerId", wrapper.Player.DbId, "err", err)
 		return
 	}
 
-	now := time.Now().Unix()
-	newCoinBooster := wrapper.Player.CoinBooster + config.CoinBoosterReward
-
-	if wrapper.Player.CoinBooster < int32(now) {
-		newCoinBooster = int32(now) + config.CoinBoosterReward
-	}
-
-	if err := dbm.Exec("update players set coin_booster = $1 where id = $2", newCoinBooster, wrapper.Player.DbId); err != nil {
+	if _, err := logic.grantCoinBooster(economy.CoinBoosterReward, 0); err != nil {
 		slog.Error("failed to update player's coin booster!", "err", err)
-		return
-	}
-
-	if err := dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3", newBalance, wrapper.Player.DbId, config.CurrencyGems); err != nil {
-		slog.Error("failed to update player's balance!", "err", err)
-		return
 	}
-
-	wrapper.Player.CoinBooster = newCoinBooster
 }
 
 // --- Unlock skin --- //
@@ -398,9 +415,10 @@ func (c *ClientUnlockSkinCommand) Process(wrapper *core.ClientWrapper, dbm *data
 		return
 	}
 
+	gameData := csv.Data()
 	contains := false
 
-	for _, value := range csv.Skins() {
+	for _, value := range gameData.Skins() {
 		if value == c.skin.S {
 			contains = true
 			break
@@ -411,18 +429,18 @@ func (c *ClientUnlockSkinCommand) Process(wrapper *core.ClientWrapper, dbm *data
 		return
 	}
 
-	if csv.IsSkinDefault(c.skin.S) {
+	if gameData.IsSkinDefault(c.skin.S) {
 		return
 	}
 
-	brawler := csv.GetBrawlerForSkin(c.skin.S)
+	brawler := gameData.GetBrawlerForSkin(c.skin.S)
 	_, exists := wrapper.Player.Brawlers[brawler]
 
 	if !exists {
 		return
 	}
 
-	price := csv.GetSkinPrice(c.skin.S)
+	price := gameData.GetSkinPrice(c.skin.S)
 	newBalance := wrapper.Player.Wallet[config.CurrencyGems].Balance - int64(price)
 
 	if newBalance < 0 {
@@ -462,7 +480,9 @@ func (c *ClientSelectSkinCommand) UnmarshalStream(stream *core.ByteStream) {
 }
 
 func (c *ClientSelectSkinCommand) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
-	brawler := csv.GetBrawlerForSkin(c.skin.S)
+	gameData := csv.Data()
+
+	brawler := gameData.GetBrawlerForSkin(c.skin.S)
 	data, exists := wrapper.Player.Brawlers[brawler]
 
 	if !exists {
@@ -501,71 +521,23 @@ func (c *ClientBuyCardCommand) UnmarshalStream(stream *core.ByteStream) {
 }
 
 func (c *ClientBuyCardCommand) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
-	unlockCard := csv.GetCardUnlock(c.card.S)
-	brawlerId := csv.GetBrawlerId(unlockCard)
-
-	if unlockCard != c.card.S {
-		index := strconv.Itoa(int(c.card.S))
-		data, exists := wrapper.Player.Brawlers[brawlerId].Cards[index]
-
-		if exists {
-			if wrapper.Player.Wallet[config.CurrencyElixir].Balance < int64(data) {
-				return
-			}
-
-			if data+1 > config.MaximumUpgradeLevel {
-				return
-			}
-
-			wrapper.Player.Brawlers[brawlerId].Cards[index] += 1
-			wrapper.Player.Wallet[config.CurrencyElixir].Balance -= int64(wrapper.Player.Brawlers[brawlerId].Cards[index])
-
-			newCards, err := json.Marshal(wrapper.Player.Brawlers[brawlerId].Cards)
-
-			if err != nil {
-				slog.Error("failed to marshal data!", "err", err)
-				return
-			}
-
-			if err = dbm.Exec("update player_brawlers set cards = $1 where player_id = $2 and brawler_id = $3", newCards, wrapper.Player.DbId, brawlerId); err != nil {
-				slog.Error("failed to update player's brawlers!", "err", err)
-				return
-			}
-
-			if err = dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3", wrapper.Player.Wallet[config.CurrencyElixir].Balance, wrapper.Player.DbId, config.CurrencyElixir); err != nil {
-				slog.Error("failed to update player's wallet!", "err", err)
-				return
-			}
-
-			return
-		}
-
-		if wrapper.Player.Wallet[config.CurrencyElixir].Balance < 1 {
-			return
-		}
+	gameData := csv.Data()
 
-		wrapper.Player.Brawlers[brawlerId].Cards[index] = 1
-		wrapper.Player.Wallet[config.CurrencyElixir].Balance -= 1
+	unlockCard := gameData.GetCardUnlock(c.card.S)
+	brawlerId := gameData.GetBrawlerId(unlockCard)
 
-		newCards, err := json.Marshal(wrapper.Player.Brawlers[brawlerId].Cards)
-
-		if err != nil {
-			slog.Error("failed to marshal data!", "err", err)
-			return
-		}
+	// hp and skill cards used to be levelled one by one, they now upgrade the brawler
+	if unlockCard != c.card.S {
+		logic := NewDeliveryLogic(wrapper, dbm)
 
-		if err = dbm.Exec("update player_brawlers set cards = $1 where player_id = $2 and brawler_id = $3", newCards, wrapper.Player.DbId, brawlerId); err != nil {
-			slog.Error("failed to update player's brawlers!", "err", err)
-			return
+		if err := logic.UpgradeBrawler(brawlerId); err != nil {
+			slog.Warn("failed to upgrade brawler", "playerId", wrapper.Player.DbId, "brawler", brawlerId, "card", c.card.S, "err", err)
 		}
 
-		if err = dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3", wrapper.Player.Wallet[config.CurrencyElixir].Balance, wrapper.Player.DbId, config.CurrencyElixir); err != nil {
-			slog.Error("failed to update player's wallet!", "err", err)
-			return
-		}
+		return
 	}
 
-	brawlerRarity := csv.GetBrawlerRarity(unlockCard)
+	brawlerRarity := gameData.GetBrawlerRarity(unlockCard)
 	var price int64 = 500
 
 	switch brawlerRarity {
@@ -585,12 +557,14 @@ func (c *ClientBuyCardCommand) Process(wrapper *core.ClientWrapper, dbm *databas
 		return
 	}
 
+	gameplay := config.Get().Gameplay
+
 	brawler := &core.PlayerBrawler{
 		BrawlerId:         brawlerId,
-		Trophies:          config.NewBrawlerTrophies,
-		HighestTrophies:   config.NewBrawlerTrophies,
-		PowerLevel:        config.NewBrawlerPowerLevel,
-		PowerPoints:       config.NewBrawlerPowerPoints,
+		Trophies:          gameplay.NewBrawlerTrophies,
+		HighestTrophies:   gameplay.NewBrawlerTrophies,
+		PowerLevel:        gameplay.NewBrawlerPowerLevel,
+		PowerPoints:       gameplay.NewBrawlerPowerPoints,
 		SelectedGadget:    nil,
 		SelectedStarPower: nil,
 		SelectedGear1:     nil,
@@ -668,3 +642,108 @@ func (c *ClientBuyBrawlerCommand) Process(wrapper *core.ClientWrapper, dbm *data
 
 	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
 }
+
+// --- Claim milestone --- //
+
+func (c *ClientClaimMilestoneCommand) UnmarshalStream(stream *core.ByteStream) {
+	for i := 0; i < 4; i++ {
+		_, _ = stream.ReadVInt()
+	}
+
+	c.milestoneId, _ = stream.ReadVInt()
+}
+
+func (c *ClientClaimMilestoneCommand) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
+	if wrapper.Player.State() != core.StateLoggedIn {
+		return
+	}
+
+	logic, err := ClaimMilestone(wrapper.Player, &databaseRewardStore{dbm: dbm}, int32(c.milestoneId))
+
+	if err != nil {
+		slog.Warn("failed to claim milestone", "playerId", wrapper.Player.DbId, "milestone", c.milestoneId, "err", err)
+		return
+	}
+
+	if len(logic.Rewards()) == 0 {
+		return
+	}
+
+	msg := NewAvailableServerCommandMessage(203, logic)
+
+	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+}
+
+// --- Buy offer --- //
+
+func (c *ClientBuyOfferCommand) UnmarshalStream(stream *core.ByteStream) {
+	for i := 0; i < 4; i++ {
+		_, _ = stream.ReadVInt()
+	}
+
+	c.offerIndex, _ = stream.ReadVInt()
+}
+
+func (c *ClientBuyOfferCommand) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
+	if wrapper.Player.State() != core.StateLoggedIn {
+		return
+	}
+
+	logic, err := PurchaseOffer(wrapper.Player, &databaseRewardStore{dbm: dbm}, int(c.offerIndex))
+
+	if err != nil {
+		slog.Warn("failed to buy offer", "playerId", wrapper.Player.DbId, "offer", c.offerIndex, "err", err)
+		return
+	}
+
+	if len(logic.Rewards()) == 0 {
+		return
+	}
+
+	msg := NewAvailableServerCommandMessage(203, logic)
+
+	wrapper.Send(msg.PacketId(), msg.PacketVersion(), msg.Marshal())
+}
+
+// --- Upgrade brawler --- //
+
+func (c *ClientUpgradeBrawlerCommand) UnmarshalStream(stream *core.ByteStream) {
+	for i := 0; i < 4; i++ {
+		_, _ = stream.ReadVInt()
+	}
+
+	c.character, _ = stream.ReadDataRef()
+}
+
+func (c *ClientUpgradeBrawlerCommand) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
+	if wrapper.Player.State() != core.StateLoggedIn {
+		return
+	}
+
+	logic := NewDeliveryLogic(wrapper, dbm)
+
+	if err := logic.UpgradeBrawler(c.character.S); err != nil {
+		slog.Warn("failed to upgrade brawler", "playerId", wrapper.Player.DbId, "brawler", c.character.S, "err", err)
+	}
+}
+
+// --- Purchase billing package --- //
+
+func (c *ClientPurchaseBillingPackageCommand) UnmarshalStream(stream *core.ByteStream) {
+	for i := 0; i < 4; i++ {
+		_, _ = stream.ReadVInt()
+	}
+
+	c.packageId, _ = stream.ReadVInt()
+	c.receipt, _ = stream.ReadString()
+}
+
+func (c *ClientPurchaseBillingPackageCommand) Process(wrapper *core.ClientWrapper, dbm *database.Manager) {
+	if wrapper.Player.State() != core.StateLoggedIn {
+		return
+	}
+
+	if _, err := PurchaseBillingPackage(wrapper.Player, &databaseRewardStore{dbm: dbm}, int32(c.packageId), c.receipt); err != nil {
+		slog.Warn("failed to purchase billing package", "playerId", wrapper.Player.DbId, "package", c.packageId, "err", err)
+	}
+}
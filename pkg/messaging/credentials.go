@@ -0,0 +1,297 @@
+package messaging
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/crypt"
+	"github.com/szcvak/sps/pkg/database"
+)
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 128
+
+	// a recovery code is a selector that finds the account followed by a verifier that proves
+	// it, 40 and 80 bits of an alphabet without look-alike characters
+	recoveryAlphabet       = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	recoverySelectorLength = 8
+	recoveryVerifierLength = 16
+
+	tokenBytes = 20
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,20}$`)
+
+	credentialAttempts = core.NewAttemptLimiter()
+)
+
+// BindPassword lets a player log in on another device with username and password.
+func BindPassword(ctx context.Context, dbm *database.Manager, player *core.Player, username string, password string) AccountResult {
+	username = strings.ToLower(strings.TrimSpace(username))
+
+	if !usernamePattern.MatchString(username) {
+		return AccountInvalidUsername
+	}
+
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return AccountWeakPassword
+	}
+
+	if !allowBind(player) {
+		return AccountTooManyAttempts
+	}
+
+	hash, err := crypt.HashSecret(password)
+
+	if err != nil {
+		slog.Error("failed to hash password!", "playerId", player.DbId, "err", err)
+		return AccountServerError
+	}
+
+	if err = dbm.SetPassword(ctx, player.DbId, username, hash); err != nil {
+		if errors.Is(err, database.ErrUsernameTaken) {
+			return AccountUsernameTaken
+		}
+
+		slog.Error("failed to set password!", "playerId", player.DbId, "err", err)
+
+		return AccountServerError
+	}
+
+	slog.Info("bound password", "playerId", player.DbId, "username", username)
+
+	return AccountOk
+}
+
+// BindRecoveryCode gives a player a new recovery code, replacing the old one. The code is only
+// ever shown this once.
+func BindRecoveryCode(ctx context.Context, dbm *database.Manager, player *core.Player) (string, AccountResult) {
+	if !allowBind(player) {
+		return "", AccountTooManyAttempts
+	}
+
+	selector, err := randomRecoveryString(recoverySelectorLength)
+
+	if err != nil {
+		slog.Error("failed to generate recovery code!", "playerId", player.DbId, "err", err)
+		return "", AccountServerError
+	}
+
+	verifier, err := randomRecoveryString(recoveryVerifierLength)
+
+	if err != nil {
+		slog.Error("failed to generate recovery code!", "playerId", player.DbId, "err", err)
+		return "", AccountServerError
+	}
+
+	hash, err := crypt.HashSecret(verifier)
+
+	if err != nil {
+		slog.Error("failed to hash recovery code!", "playerId", player.DbId, "err", err)
+		return "", AccountServerError
+	}
+
+	if err = dbm.SetRecoveryCode(ctx, player.DbId, selector, hash); err != nil {
+		slog.Error("failed to set recovery code!", "playerId", player.DbId, "err", err)
+		return "", AccountServerError
+	}
+
+	slog.Info("bound recovery code", "playerId", player.DbId)
+
+	return formatRecoveryCode(selector + verifier), AccountOk
+}
+
+// AccountTransfer is an account a device proved it owns, with the fresh token it logs in with
+// from now on.
+type AccountTransfer struct {
+	PlayerId int64
+	HighId   int32
+	LowId    int32
+	Token    string
+}
+
+// TransferAccount checks a username and password, or a recovery code when username is empty,
+// and rotates the token of the account they belong to. Wrong guesses are limited per address
+// and per account, the returned duration is how long address has to wait when it is over.
+func TransferAccount(ctx context.Context, dbm *database.Manager, address string, username string, secret string) (*AccountTransfer, time.Duration, AccountResult) {
+	access := config.Get().Access
+	window := time.Duration(access.CredentialAttemptWindow) * time.Second
+	now := time.Now()
+
+	byRecoveryCode := username == ""
+	username = strings.ToLower(strings.TrimSpace(username))
+	accountKey := "user:" + username
+	selector := ""
+	verifier := secret
+
+	if byRecoveryCode {
+		// a malformed code finds no account and only counts against the address
+		selector, verifier, _ = splitRecoveryCode(secret)
+		accountKey = "recovery:" + selector
+	}
+
+	addressKey := "address:" + address
+
+	for _, key := range []string{addressKey, accountKey} {
+		if wait := credentialAttempts.RetryAfter(key, access.CredentialAttempts, window, now); wait > 0 {
+			slog.Warn("refusing account transfer, too many attempts", "key", key, "retryAfter", wait)
+			return nil, wait, AccountTooManyAttempts
+		}
+	}
+
+	var credentials *database.Credentials
+	var err error
+
+	if byRecoveryCode && selector != "" {
+		credentials, err = dbm.CredentialsByRecoverySelector(ctx, selector)
+	} else if !byRecoveryCode {
+		credentials, err = dbm.CredentialsByUsername(ctx, username)
+	}
+
+	if err != nil && !errors.Is(err, database.ErrCredentialsNotFound) {
+		slog.Error("failed to load credentials!", "err", err)
+		return nil, 0, AccountServerError
+	}
+
+	if !verifyCredentials(credentials, byRecoveryCode, verifier) {
+		credentialAttempts.Fail(addressKey, window, now)
+
+		if credentials != nil {
+			credentialAttempts.Fail(accountKey, window, now)
+		}
+
+		slog.Info("refusing account transfer, wrong credentials", "address", address)
+
+		return nil, 0, AccountWrongCredentials
+	}
+
+	credentialAttempts.Reset(accountKey)
+
+	token, err := NewToken()
+
+	if err != nil {
+		slog.Error("failed to generate token!", "err", err)
+		return nil, 0, AccountServerError
+	}
+
+	if err = dbm.RotateToken(ctx, credentials.PlayerId, token, byRecoveryCode); err != nil {
+		slog.Error("failed to transfer account!", "playerId", credentials.PlayerId, "err", err)
+		return nil, 0, AccountServerError
+	}
+
+	slog.Info("transferred account", "playerId", credentials.PlayerId, "address", address)
+
+	return &AccountTransfer{
+		PlayerId: credentials.PlayerId,
+		HighId:   credentials.HighId,
+		LowId:    credentials.LowId,
+		Token:    token,
+	}, 0, AccountOk
+}
+
+// NewToken returns a fresh random account token.
+func NewToken() (string, error) {
+	return crypt.RandomToken(tokenBytes)
+}
+
+// --- Helper functions --- //
+
+// allowBind counts a bind against the player and tells whether they are still within the
+// credential attempt limit. Every bind counts, as each one costs a hash.
+func allowBind(player *core.Player) bool {
+	access := config.Get().Access
+	window := time.Duration(access.CredentialAttemptWindow) * time.Second
+	now := time.Now()
+	key := fmt.Sprintf("bind:%d", player.DbId)
+
+	if wait := credentialAttempts.RetryAfter(key, access.CredentialAttempts, window, now); wait > 0 {
+		slog.Warn("refusing bind, too many attempts", "playerId", player.DbId, "retryAfter", wait)
+		return false
+	}
+
+	credentialAttempts.Fail(key, window, now)
+
+	return true
+}
+
+func verifyCredentials(credentials *database.Credentials, byRecoveryCode bool, secret string) bool {
+	if credentials == nil {
+		return false
+	}
+
+	hash := credentials.PasswordHash
+
+	if byRecoveryCode {
+		hash = credentials.RecoveryHash
+	}
+
+	if hash == nil {
+		return false
+	}
+
+	ok, err := crypt.VerifySecret(*hash, secret)
+
+	if err != nil {
+		slog.Error("failed to verify credentials!", "playerId", credentials.PlayerId, "err", err)
+		return false
+	}
+
+	return ok
+}
+
+func randomRecoveryString(length int) (string, error) {
+	data := make([]byte, length)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	for i, b := range data {
+		data[i] = recoveryAlphabet[int(b)%len(recoveryAlphabet)]
+	}
+
+	return string(data), nil
+}
+
+// formatRecoveryCode splits a code in groups of four, e.g. ABCD-EFGH-....
+func formatRecoveryCode(code string) string {
+	groups := make([]string, 0, len(code)/4)
+
+	for i := 0; i < len(code); i += 4 {
+		groups = append(groups, code[i:min(i+4, len(code))])
+	}
+
+	return strings.Join(groups, "-")
+}
+
+// splitRecoveryCode returns the selector and verifier of a code as the player typed it, ok is
+// false when it does not have the length of a recovery code.
+func splitRecoveryCode(code string) (selector string, verifier string, ok bool) {
+	code = normalizeRecoveryCode(code)
+
+	if len(code) != recoverySelectorLength+recoveryVerifierLength {
+		return "", code, false
+	}
+
+	return code[:recoverySelectorLength], code[recoverySelectorLength:], true
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(code))
+}
@@ -0,0 +1,56 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCodeRoundTrip(t *testing.T) {
+	selector, err := randomRecoveryString(recoverySelectorLength)
+
+	if err != nil {
+		t.Fatalf("failed to generate selector: %v", err)
+	}
+
+	verifier, err := randomRecoveryString(recoveryVerifierLength)
+
+	if err != nil {
+		t.Fatalf("failed to generate verifier: %v", err)
+	}
+
+	code := formatRecoveryCode(selector + verifier)
+
+	if len(code) != 29 || strings.Count(code, "-") != 5 {
+		t.Fatalf("unexpected code format %s", code)
+	}
+
+	gotSelector, gotVerifier, ok := splitRecoveryCode(strings.ToLower(strings.ReplaceAll(code, "-", " ")))
+
+	if !ok || gotSelector != selector || gotVerifier != verifier {
+		t.Fatalf("expected %s and %s, got %s and %s (%v)", selector, verifier, gotSelector, gotVerifier, ok)
+	}
+}
+
+func TestSplitRecoveryCodeLength(t *testing.T) {
+	codes := []string{"", "ABCD-EFGH", "ABCD-EFGH-JKLM-NPQR-STUV-WXYZ-2"}
+
+	for _, code := range codes {
+		if selector, _, ok := splitRecoveryCode(code); ok || selector != "" {
+			t.Fatalf("expected %q to be refused, got selector %q", code, selector)
+		}
+	}
+}
+
+func TestRandomRecoveryStringAlphabet(t *testing.T) {
+	value, err := randomRecoveryString(256)
+
+	if err != nil {
+		t.Fatalf("failed to generate string: %v", err)
+	}
+
+	for _, r := range value {
+		if !strings.ContainsRune(recoveryAlphabet, r) {
+			t.Fatalf("unexpected character %q in %s", r, value)
+		}
+	}
+}
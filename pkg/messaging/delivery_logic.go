@@ -1,10 +1,13 @@
 package messaging
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"slices"
 	"strconv"
 	"time"
 
@@ -21,6 +24,8 @@ const (
 	RewardIdElixir      int32 = 3
 	RewardIdCoinDoubler int32 = 4
 	RewardIdCoinBooster int32 = 5
+	RewardIdPowerPoints int32 = 6
+	RewardIdAccessory   int32 = 7
 
 	DataRefClassCard int32 = 23
 
@@ -34,94 +39,107 @@ type RewardItem struct {
 	DataRef  core.DataRef
 }
 
-type BoxConfig struct {
-	Id           int32
-	Price        int32
-	CurrencyId   int32
-	RewardsCount int
+// RewardStore persists what DeliveryLogic grants, the database on the server and nothing in
+// simulations.
+type RewardStore interface {
+	UpdateBalance(player *core.Player, currencyId int32, balance int64) error
+	ExtendBoost(player *core.Player, kind core.BoostKind, duration time.Duration) (*core.PlayerBoost, error)
+	AddBoost(player *core.Player, kind core.BoostKind, amount int32) (*core.PlayerBoost, error)
+	ConsumeBoost(player *core.Player, kind core.BoostKind, amount int32) (int32, int32, error)
+	InsertBrawler(player *core.Player, brawler *core.PlayerBrawler) error
+	UpdateBrawler(player *core.Player, brawler *core.PlayerBrawler) error
+	UpdatePity(player *core.Player) error
+	RecordOfferPurchase(player *core.Player, key string, limit int32) (bool, error)
+	RevertOfferPurchase(player *core.Player, key string) error
+	UnlockAccessory(player *core.Player, brawler *core.PlayerBrawler, kind core.AccessoryKind, id int32) error
+	ClaimMilestone(player *core.Player, id int32) (bool, error)
+	ReplaceQuests(player *core.Player, period core.QuestPeriod, quests []*core.PlayerQuest) error
+	UpdateQuest(player *core.Player, quest *core.PlayerQuest) error
+	ClaimQuest(player *core.Player, quest *core.PlayerQuest) (bool, error)
+	UnclaimQuest(player *core.Player, quest *core.PlayerQuest) error
+	GrantPurchase(player *core.Player, receiptId string, productId string, currencyId int32, amount int32) (bool, error)
 }
 
-var boxConfigs = map[int32]BoxConfig{
-	1: {Id: 10, Price: 100, CurrencyId: config.CurrencyCoins, RewardsCount: 1},
-	2: {Id: 10, Price: 10, CurrencyId: config.CurrencyGems, RewardsCount: 1},
-	3: {Id: 11, Price: 80, CurrencyId: config.CurrencyGems, RewardsCount: 10},
-}
-
-type RarityConfig struct {
-	Name         string
-	ElixirAmount int32
-	ChipAmount   int32
-
-	WeightElixir  uint
-	WeightBrawler uint
-	WeightBooster uint
-	WeightDoubler uint
-}
-
-var rarityConfigs = map[int32]RarityConfig{
-	0: {Name: "common", ElixirAmount: 2, ChipAmount: 1, WeightElixir: 80, WeightBrawler: 20, WeightBooster: 0, WeightDoubler: 0},
-	1: {Name: "rare", ElixirAmount: 2, ChipAmount: 2, WeightElixir: 80, WeightBrawler: 20, WeightBooster: 0, WeightDoubler: 0},
-	2: {Name: "super_rare", ElixirAmount: 3, ChipAmount: 4, WeightElixir: 30, WeightBrawler: 10, WeightBooster: 30, WeightDoubler: 30},
-	3: {Name: "epic", ElixirAmount: 5, ChipAmount: 10, WeightElixir: 80, WeightBrawler: 20, WeightBooster: 0, WeightDoubler: 0},
-	4: {Name: "mega_epic", ElixirAmount: 7, ChipAmount: 25, WeightElixir: 80, WeightBrawler: 20, WeightBooster: 0, WeightDoubler: 0},
-	5: {Name: "legendary", ElixirAmount: 10, ChipAmount: 60, WeightElixir: 80, WeightBrawler: 20, WeightBooster: 0, WeightDoubler: 0},
-}
+var ErrInsufficientFunds = errors.New("insufficient funds")
 
 type DeliveryLogic struct {
-	wrapper *core.ClientWrapper
-	dbm     *database.Manager
+	player  *core.Player
+	store   RewardStore
+	boxes   *BoxTable
+	data    *csv.GameData
 	boxId   int32
 	rewards []RewardItem
 }
 
 func NewDeliveryLogic(wrapper *core.ClientWrapper, dbm *database.Manager) *DeliveryLogic {
+	return NewDeliveryLogicWithStore(wrapper.Player, &databaseRewardStore{dbm: dbm})
+}
+
+// NewDatabaseRewardStore returns the RewardStore backed by dbm, for code that has a player but
+// not its connection.
+func NewDatabaseRewardStore(dbm *database.Manager) RewardStore {
+	return &databaseRewardStore{dbm: dbm}
+}
+
+func NewDeliveryLogicWithStore(player *core.Player, store RewardStore) *DeliveryLogic {
 	return &DeliveryLogic{
-		wrapper: wrapper,
-		dbm:     dbm,
+		player:  player,
+		store:   store,
+		boxes:   Boxes(),
+		data:    csv.Data(),
 		boxId:   -1,
 		rewards: make([]RewardItem, 0),
 	}
 }
 
-func (d *DeliveryLogic) GenerateRewards(boxTypeIdentifier int32) error {
-	boxConf, ok := boxConfigs[boxTypeIdentifier]
+func (d *DeliveryLogic) Rewards() []RewardItem {
+	return d.rewards
+}
 
-	if !ok {
-		err := fmt.Errorf("unknown box type identifier: %d", boxTypeIdentifier)
+func (d *DeliveryLogic) GenerateRewards(boxTypeIdentifier int32) error {
+	if d.boxes == nil {
+		err := errors.New("box definitions have not been loaded")
 		slog.Error("failed to generate rewards!", "err", err)
 
 		return err
 	}
 
-	player := d.wrapper.Player
-	wallet, exists := player.Wallet[boxConf.CurrencyId]
+	boxConf, ok := d.boxes.Box(boxTypeIdentifier)
 
-	if !exists || wallet.Balance < int64(boxConf.Price) {
-		err := fmt.Errorf("insufficient funds for box %d (Type %d): need %d of currency %d, have %d",
-			boxConf.Id, boxTypeIdentifier, boxConf.Price, boxConf.CurrencyId, wallet.Balance)
+	if !ok {
+		err := fmt.Errorf("unknown box type identifier: %d", boxTypeIdentifier)
+		slog.Error("failed to generate rewards!", "err", err)
 
-		slog.Warn("will not giwe out rewards", "playerId", player.DbId, "err", err)
+		return err
+	}
 
+	if err := d.Spend(boxConf.CurrencyId, int64(boxConf.Price)); err != nil {
+		slog.Warn("will not giwe out rewards", "playerId", d.player.DbId, "boxId", boxConf.Id, "boxType", boxTypeIdentifier, "err", err)
 		return err
 	}
 
-	newBalance := wallet.Balance - int64(boxConf.Price)
+	return d.OpenBox(boxTypeIdentifier)
+}
+
+// OpenBox generates the rewards of a box without charging for it, for boxes that were paid
+// for elsewhere.
+func (d *DeliveryLogic) OpenBox(boxTypeIdentifier int32) error {
+	if d.boxes == nil {
+		return errors.New("box definitions have not been loaded")
+	}
 
-	err := d.dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3",
-		newBalance, player.DbId, boxConf.CurrencyId)
+	boxConf, ok := d.boxes.Box(boxTypeIdentifier)
 
-	if err != nil {
-		slog.Error("failed to update player!", "playerId", player.DbId, "currency", boxConf.CurrencyId, "err", err)
-		return fmt.Errorf("failed to deduct box cost: %w", err)
+	if !ok {
+		return fmt.Errorf("unknown box type identifier: %d", boxTypeIdentifier)
 	}
 
-	wallet.Balance = newBalance
+	player := d.player
 
 	d.boxId = boxConf.Id
-	d.rewards = make([]RewardItem, 0, boxConf.RewardsCount)
 
 	for i := 0; i < boxConf.RewardsCount; i++ {
-		reward, err := d.generateSingleReward()
+		reward, err := d.generateSingleReward(boxConf)
 
 		if err != nil {
 			slog.Error("failed to generate reward!", "boxId", d.boxId, "itemIndex", i, "err", err)
@@ -131,6 +149,16 @@ func (d *DeliveryLogic) GenerateRewards(boxTypeIdentifier int32) error {
 		if reward != nil {
 			d.rewards = append(d.rewards, *reward)
 		}
+
+		d.updatePity(boxConf, reward)
+	}
+
+	if len(boxConf.Pity) > 0 {
+		if err := d.store.UpdatePity(player); err != nil {
+			slog.Error("failed to update box pity!", "playerId", player.DbId, "err", err)
+		}
+
+		slog.Info("box pity", "playerId", player.DbId, "boxId", d.boxId, "counters", player.PityCounters)
 	}
 
 	slog.Info("generated rewards", "playerId", player.DbId, "boxId", d.boxId, "count", len(d.rewards))
@@ -138,45 +166,99 @@ func (d *DeliveryLogic) GenerateRewards(boxTypeIdentifier int32) error {
 	return nil
 }
 
-func (d *DeliveryLogic) generateSingleReward() (*RewardItem, error) {
-	rarityId := int32(config.BoxRewardRarityChooser.Pick())
-	rarityConf, ok := rarityConfigs[rarityId]
-
-	if !ok {
-		return nil, fmt.Errorf("invalid rarity picked: %d", rarityId)
+// Spend takes price of currencyId from the player's wallet. Every purchase goes through here.
+func (d *DeliveryLogic) Spend(currencyId int32, price int64) error {
+	if price < 0 {
+		return fmt.Errorf("invalid price: %d", price)
 	}
 
-	choices := []weightedrand.Choice[int, uint]{}
+	wallet, exists := d.player.Wallet[currencyId]
 
-	if rarityConf.WeightElixir > 0 {
-		choices = append(choices, weightedrand.NewChoice(0, rarityConf.WeightElixir))
-	}
+	if !exists || wallet.Balance < price {
+		var balance int64
 
-	if rarityConf.WeightBrawler > 0 {
-		choices = append(choices, weightedrand.NewChoice(1, rarityConf.WeightBrawler))
-	}
+		if exists {
+			balance = wallet.Balance
+		}
 
-	if rarityConf.WeightBooster > 0 {
-		choices = append(choices, weightedrand.NewChoice(2, rarityConf.WeightBooster))
+		return fmt.Errorf("%w: need %d of currency %d, have %d", ErrInsufficientFunds, price, currencyId, balance)
 	}
 
-	if rarityConf.WeightDoubler > 0 {
-		choices = append(choices, weightedrand.NewChoice(3, rarityConf.WeightDoubler))
+	newBalance := wallet.Balance - price
+
+	if err := d.store.UpdateBalance(d.player, currencyId, newBalance); err != nil {
+		return fmt.Errorf("failed to update balance: %w", err)
 	}
 
-	chooser, err := weightedrand.NewChooser(choices...)
+	wallet.Balance = newBalance
+
+	return nil
+}
+
+func (d *DeliveryLogic) generateSingleReward(box *BoxDefinition) (*RewardItem, error) {
+	rarityId, forced, err := d.pickRarity(box)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create reward type chooser for rarity %d: %w", rarityId, err)
+		return nil, err
 	}
 
-	rewardType := chooser.Pick()
+	rarityConf, ok := d.boxes.Rarity(rarityId)
+
+	if !ok {
+		return nil, fmt.Errorf("invalid rarity picked: %d", rarityId)
+	}
+
+	rewardType := 1
+
+	if !forced {
+		brawlerWeight := rarityConf.Weights.Brawler + d.pityBonus(box, rarityId)
+
+		choices := []weightedrand.Choice[int, uint]{}
+
+		if rarityConf.Weights.Elixir > 0 {
+			choices = append(choices, weightedrand.NewChoice(0, rarityConf.Weights.Elixir))
+		}
+
+		if brawlerWeight > 0 {
+			choices = append(choices, weightedrand.NewChoice(1, brawlerWeight))
+		}
+
+		if rarityConf.Weights.CoinBooster > 0 {
+			choices = append(choices, weightedrand.NewChoice(2, rarityConf.Weights.CoinBooster))
+		}
+
+		if rarityConf.Weights.CoinDoubler > 0 {
+			choices = append(choices, weightedrand.NewChoice(3, rarityConf.Weights.CoinDoubler))
+		}
+
+		if rarityConf.Weights.PowerPoints > 0 {
+			choices = append(choices, weightedrand.NewChoice(4, rarityConf.Weights.PowerPoints))
+		}
+
+		if rarityConf.Weights.Accessory > 0 {
+			choices = append(choices, weightedrand.NewChoice(5, rarityConf.Weights.Accessory))
+		}
+
+		chooser, err := weightedrand.NewChooser(choices...)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create reward type chooser for rarity %d: %w", rarityId, err)
+		}
+
+		rewardType = chooser.Pick()
+	}
 
 	switch rewardType {
 	case 0: // elixir
 		return d.grantElixir(rarityConf.ElixirAmount, rarityId)
 	case 1: // Brawler
-		characters := csv.GetBrawlersWithRarity(rarityConf.Name)
+		characters := d.data.GetBrawlersWithRarity(rarityConf.Name)
+
+		if d.boxes.DuplicateProtection {
+			if missing := d.unownedCards(characters); len(missing) > 0 {
+				characters = missing
+			}
+		}
 
 		if len(characters) == 0 {
 			slog.Warn("no brawlers found for rarity, will give elixir", "rarity", rarityConf.Name)
@@ -186,35 +268,141 @@ func (d *DeliveryLogic) generateSingleReward() (*RewardItem, error) {
 		selected := characters[rand.Intn(len(characters))]
 		return d.grantBrawler(selected, rarityConf.ChipAmount, rarityId)
 	case 2: // booster
-		boosterDuration := int32(259200) // 3 days
-		return d.grantCoinBooster(boosterDuration, rarityId)
+		return d.grantCoinBooster(d.boxes.CoinBoosterDuration, rarityId)
 	case 3: // doubler
-		amount := int32(200)
-		return d.grantCoinDoubler(amount, rarityId)
+		return d.grantCoinDoubler(d.boxes.CoinDoublerAmount, rarityId)
+	case 4: // power points
+		upgradable := d.upgradableBrawlers()
+
+		if len(upgradable) == 0 {
+			return d.grantElixir(rarityConf.ElixirAmount, rarityId)
+		}
+
+		selected := upgradable[rand.Intn(len(upgradable))]
+		return d.grantPowerPoints(selected, rarityConf.PowerPointsAmount, rarityId)
+	case 5: // star power or gadget
+		droppable := d.droppableAccessories()
+
+		if len(droppable) == 0 {
+			return d.grantElixir(rarityConf.ElixirAmount, rarityId)
+		}
+
+		selected := droppable[rand.Intn(len(droppable))]
+		return d.grantAccessory(d.player.Brawlers[*selected.BrawlerId], selected, rarityId)
 	default:
 		return nil, fmt.Errorf("unknown reward type picked: %d", rewardType)
 	}
 }
 
-func (d *DeliveryLogic) grantElixir(amount int32, rarity int32) (*RewardItem, error) {
-	if amount <= 0 {
-		return nil, fmt.Errorf("invalid elixir amount: %d", amount)
+// pickRarity rolls a rarity using the box weights raised by pity. forced is set when a pity
+// guarantee was reached, in which case the reward must be a brawler of that rarity.
+func (d *DeliveryLogic) pickRarity(box *BoxDefinition) (int32, bool, error) {
+	var guaranteed *PityRule
+
+	for i := range box.Pity {
+		rule := &box.Pity[i]
+
+		if rule.Guarantee > 0 && d.player.PityCounters[rule.Rarity] >= rule.Guarantee {
+			if guaranteed == nil || rule.Rarity > guaranteed.Rarity {
+				guaranteed = rule
+			}
+		}
+	}
+
+	if guaranteed != nil {
+		slog.Info("box pity guarantee reached", "playerId", d.player.DbId, "rarity", guaranteed.Rarity, "misses", d.player.PityCounters[guaranteed.Rarity])
+		return guaranteed.Rarity, true, nil
 	}
 
-	player := d.wrapper.Player
-	currencyId := config.CurrencyElixir
+	choices := make([]weightedrand.Choice[int32, uint], 0, len(box.RarityWeights))
 
-	newBalance := player.Wallet[int32(currencyId)].Balance + int64(amount)
+	for rarity, weight := range box.RarityWeights {
+		choices = append(choices, weightedrand.NewChoice(int32(rarity), weight+d.pityBonus(box, int32(rarity))))
+	}
 
-	err := d.dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3",
-		newBalance, player.DbId, currencyId)
+	chooser, err := weightedrand.NewChooser(choices...)
 
 	if err != nil {
-		slog.Error("failed to update elixir", "playerId", player.DbId, "err", err)
-		return nil, fmt.Errorf("error granting elixir: %w", err)
+		return 0, false, fmt.Errorf("failed to create rarity chooser for box %d: %w", box.Type, err)
+	}
+
+	return chooser.Pick(), false, nil
+}
+
+func (d *DeliveryLogic) pityBonus(box *BoxDefinition, rarity int32) uint {
+	var bonus uint
+
+	for _, rule := range box.Pity {
+		if rule.Rarity == rarity {
+			bonus += rule.Increment * uint(d.player.PityCounters[rarity])
+		}
+	}
+
+	return bonus
+}
+
+// updatePity resets the counter of every pity rarity that dropped a brawler or has nothing left
+// to unlock, and counts a miss for the others.
+func (d *DeliveryLogic) updatePity(box *BoxDefinition, reward *RewardItem) {
+	for _, rule := range box.Pity {
+		hit := reward != nil && reward.RewardId == RewardIdBrawler && reward.Rarity == rule.Rarity
+
+		if hit || d.rarityComplete(rule.Rarity) {
+			d.player.PityCounters[rule.Rarity] = 0
+		} else {
+			d.player.PityCounters[rule.Rarity]++
+		}
+	}
+}
+
+func (d *DeliveryLogic) rarityComplete(rarity int32) bool {
+	rarityConf, ok := d.boxes.Rarity(rarity)
+
+	if !ok {
+		return false
+	}
+
+	return len(d.unownedCards(d.data.GetBrawlersWithRarity(rarityConf.Name))) == 0
+}
+
+func (d *DeliveryLogic) unownedCards(cards []int32) []int32 {
+	unowned := make([]int32, 0, len(cards))
+
+	for _, card := range cards {
+		if _, owned := d.player.Brawlers[d.data.GetBrawlerId(card)]; !owned {
+			unowned = append(unowned, card)
+		}
+	}
+
+	return unowned
+}
+
+// upgradableBrawlers returns the owned brawlers below the maximum upgrade level, sorted so
+// the pick only depends on the random source.
+func (d *DeliveryLogic) upgradableBrawlers() []int32 {
+	maximum := int32(config.Get().Gameplay.MaximumUpgradeLevel)
+	brawlers := make([]int32, 0, len(d.player.Brawlers))
+
+	for id, brawler := range d.player.Brawlers {
+		if brawler.PowerLevel < maximum {
+			brawlers = append(brawlers, id)
+		}
+	}
+
+	slices.Sort(brawlers)
+
+	return brawlers
+}
+
+func (d *DeliveryLogic) grantElixir(amount int32, rarity int32) (*RewardItem, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid elixir amount: %d", amount)
 	}
 
-	player.Wallet[int32(currencyId)].Balance = newBalance
+	if err := d.addCurrency(config.CurrencyElixir, amount); err != nil {
+		slog.Error("failed to update elixir", "playerId", d.player.DbId, "err", err)
+		return nil, fmt.Errorf("error granting elixir: %w", err)
+	}
 
 	return &RewardItem{
 		Rarity:   rarity,
@@ -229,21 +417,11 @@ func (d *DeliveryLogic) grantChips(amount int32, id int32, rarity int32) (*Rewar
 		return nil, fmt.Errorf("invalid chip amount: %d", amount)
 	}
 
-	player := d.wrapper.Player
-	currencyId := config.CurrencyChips
-
-	newBalance := player.Wallet[int32(currencyId)].Balance + int64(amount)
-
-	err := d.dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3",
-		newBalance, player.DbId, currencyId)
-
-	if err != nil {
-		slog.Error("failed to update chips", "playerId", player.DbId, "err", err)
+	if err := d.addCurrency(config.CurrencyChips, amount); err != nil {
+		slog.Error("failed to update chips", "playerId", d.player.DbId, "err", err)
 		return nil, fmt.Errorf("error granting chips: %w", err)
 	}
 
-	player.Wallet[int32(currencyId)].Balance = newBalance
-
 	return &RewardItem{
 		Rarity:   rarity,
 		Amount:   amount,
@@ -257,25 +435,15 @@ func (d *DeliveryLogic) grantCoinBooster(duration int32, rarity int32) (*RewardI
 		return nil, fmt.Errorf("invalid booster duration: %d", duration)
 	}
 
-	player := d.wrapper.Player
-
-	now := time.Now().Unix()
-	newBoosterEndTime := player.CoinBooster
-
-	if now >= int64(newBoosterEndTime) {
-		newBoosterEndTime = int32(now) + duration
-	} else {
-		newBoosterEndTime += duration
-	}
-
-	err := d.dbm.Exec("update players set coin_booster = $1 where id = $2", newBoosterEndTime, player.DbId)
+	player := d.player
+	boost, err := d.store.ExtendBoost(player, core.BoostCoinBooster, time.Duration(duration)*time.Second)
 
 	if err != nil {
-		slog.Error("failed to update coin_booster!", "playerId", player.DbId, "err", err)
+		slog.Error("failed to update coin booster!", "playerId", player.DbId, "err", err)
 		return nil, fmt.Errorf("error granting booster: %w", err)
 	}
 
-	player.CoinBooster = newBoosterEndTime
+	player.Boosts[core.BoostCoinBooster] = boost
 
 	return &RewardItem{
 		Rarity:   rarity,
@@ -290,17 +458,15 @@ func (d *DeliveryLogic) grantCoinDoubler(amount int32, rarity int32) (*RewardIte
 		return nil, fmt.Errorf("invalid doubler amount: %d", amount)
 	}
 
-	player := d.wrapper.Player
-	newAmount := amount + player.CoinDoubler
-
-	err := d.dbm.Exec("update players set coin_doubler = $1 where id = $2", newAmount, player.DbId)
+	player := d.player
+	boost, err := d.store.AddBoost(player, core.BoostCoinDoubler, amount)
 
 	if err != nil {
-		slog.Error("failed to update coin_doubler!", "playerId", player.DbId, "err", err)
+		slog.Error("failed to update coin doubler!", "playerId", player.DbId, "err", err)
 		return nil, fmt.Errorf("error granting doubler: %w", err)
 	}
 
-	player.CoinDoubler = newAmount
+	player.Boosts[core.BoostCoinDoubler] = boost
 
 	return &RewardItem{
 		Rarity:   rarity,
@@ -311,78 +477,117 @@ func (d *DeliveryLogic) grantCoinDoubler(amount int32, rarity int32) (*RewardIte
 }
 
 func (d *DeliveryLogic) grantBrawler(cardId int32, chipAmount int32, rarity int32) (*RewardItem, error) {
-	player := d.wrapper.Player
+	player := d.player
 
-	brawlerId := csv.GetBrawlerId(cardId)
+	brawlerId := d.data.GetBrawlerId(cardId)
 
 	_, exists := player.Brawlers[brawlerId]
 
 	if exists {
 		return d.grantChips(chipAmount, cardId, rarity)
-	} else {
-		cardsKey := strconv.Itoa(int(cardId))
-		newBrawlerCards := map[string]int32{cardsKey: 1}
+	}
 
-		cardsJson, err := json.Marshal(newBrawlerCards)
+	gameplay := config.Get().Gameplay
+
+	brawler := &core.PlayerBrawler{
+		BrawlerId:         brawlerId,
+		Trophies:          gameplay.NewBrawlerTrophies,
+		HighestTrophies:   gameplay.NewBrawlerTrophies,
+		PowerLevel:        gameplay.NewBrawlerPowerLevel,
+		PowerPoints:       gameplay.NewBrawlerPowerPoints,
+		SelectedGadget:    nil,
+		SelectedStarPower: nil,
+		SelectedGear1:     nil,
+		SelectedGear2:     nil,
+		UnlockedSkinIds:   []int32{0},
+		Cards:             map[string]int32{strconv.Itoa(int(cardId)): 1},
+		SelectedSkinId:    0,
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal new brawler cards to json: %w", err)
-		}
+	if err := d.store.InsertBrawler(player, brawler); err != nil {
+		slog.Error("failed to insert new brawler!", "playerId", player.DbId, "brawler", brawlerId, "err", err)
 
-		skinsJson, err := json.Marshal([]int32{0})
+		return nil, fmt.Errorf("error granting new brawler: %w", err)
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal new brawler skins to json: %w", err)
-		}
+	player.Brawlers[brawlerId] = brawler
 
-		brawler := &core.PlayerBrawler{
-			BrawlerId:         brawlerId,
-			Trophies:          config.NewBrawlerTrophies,
-			HighestTrophies:   config.NewBrawlerTrophies,
-			PowerLevel:        config.NewBrawlerPowerLevel,
-			PowerPoints:       config.NewBrawlerPowerPoints,
-			SelectedGadget:    nil,
-			SelectedStarPower: nil,
-			SelectedGear1:     nil,
-			SelectedGear2:     nil,
-			UnlockedSkinIds:   []int32{0},
-			Cards:             newBrawlerCards,
-			SelectedSkinId:    0,
-		}
+	return &RewardItem{
+		Rarity:   rarity,
+		Amount:   1,
+		RewardId: RewardIdBrawler,
+		DataRef:  core.DataRef{DataRefClassCard, cardId},
+	}, nil
+}
 
-		stmt := `
-			INSERT INTO player_brawlers (
-				player_id, brawler_id, trophies, highest_trophies,
-				power_level, power_points,
-				unlocked_skins, selected_skin, cards
-			)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-
-		err = d.dbm.Exec(stmt,
-			player.DbId,
-			brawler.BrawlerId,
-			brawler.Trophies, brawler.HighestTrophies,
-			brawler.PowerLevel, brawler.PowerPoints,
-			skinsJson,
-			brawler.SelectedSkinId,
-			cardsJson,
-		)
+func (d *DeliveryLogic) grantSkin(skinId int32) error {
+	brawler, exists := d.player.Brawlers[d.data.GetBrawlerForSkin(skinId)]
 
-		if err != nil {
-			slog.Error("failed to insert new brawler!", "playerId", player.DbId, "brawler", brawlerId, "err", err)
+	if !exists {
+		return fmt.Errorf("brawler for skin %d is not unlocked", skinId)
+	}
 
-			return nil, fmt.Errorf("error granting new brawler: %w", err)
-		}
+	if slices.Contains(brawler.UnlockedSkinIds, skinId) {
+		return fmt.Errorf("skin %d is already unlocked", skinId)
+	}
 
-		player.Brawlers[brawlerId] = brawler
+	brawler.UnlockedSkinIds = append(brawler.UnlockedSkinIds, skinId)
 
-		return &RewardItem{
-			Rarity:   rarity,
-			Amount:   1,
-			RewardId: RewardIdBrawler,
-			DataRef:  core.DataRef{DataRefClassCard, cardId},
-		}, nil
+	if err := d.store.UpdateBrawler(d.player, brawler); err != nil {
+		brawler.UnlockedSkinIds = brawler.UnlockedSkinIds[:len(brawler.UnlockedSkinIds)-1]
+		return fmt.Errorf("error granting skin: %w", err)
 	}
+
+	return nil
+}
+
+func (d *DeliveryLogic) grantPowerPoints(brawlerId int32, amount int32, rarity int32) (*RewardItem, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("invalid power point amount: %d", amount)
+	}
+
+	brawler, exists := d.player.Brawlers[brawlerId]
+
+	if !exists {
+		return nil, fmt.Errorf("brawler %d is not unlocked", brawlerId)
+	}
+
+	brawler.PowerPoints += amount
+
+	if err := d.store.UpdateBrawler(d.player, brawler); err != nil {
+		brawler.PowerPoints -= amount
+
+		slog.Error("failed to update power points!", "playerId", d.player.DbId, "brawler", brawlerId, "err", err)
+		return nil, fmt.Errorf("error granting power points: %w", err)
+	}
+
+	cardId, _ := d.data.GetCardForCharacter(brawlerId)
+
+	return &RewardItem{
+		Rarity:   rarity,
+		Amount:   amount,
+		RewardId: RewardIdPowerPoints,
+		DataRef:  core.DataRef{DataRefClassCard, cardId},
+	}, nil
+}
+
+func (d *DeliveryLogic) addCurrency(currencyId int32, amount int32) error {
+	wallet, exists := d.player.Wallet[currencyId]
+
+	if !exists {
+		wallet = &core.PlayerCurrency{CurrencyId: currencyId}
+		d.player.Wallet[currencyId] = wallet
+	}
+
+	newBalance := CapBalance(currencyId, wallet.Balance, int64(amount))
+
+	if err := d.store.UpdateBalance(d.player, currencyId, newBalance); err != nil {
+		return err
+	}
+
+	wallet.Balance = newBalance
+
+	return nil
 }
 
 func (d *DeliveryLogic) Marshal(stream *core.ByteStream) {
@@ -400,3 +605,164 @@ func (d *DeliveryLogic) Marshal(stream *core.ByteStream) {
 		stream.Write(core.VInt(item.RewardId))
 	}
 }
+
+// --- Database store --- //
+
+type databaseRewardStore struct {
+	dbm *database.Manager
+}
+
+func (s *databaseRewardStore) UpdateBalance(player *core.Player, currencyId int32, balance int64) error {
+	return s.dbm.Exec("update player_wallet set balance = $1 where player_id = $2 and currency_id = $3",
+		balance, player.DbId, currencyId)
+}
+
+func (s *databaseRewardStore) ExtendBoost(player *core.Player, kind core.BoostKind, duration time.Duration) (*core.PlayerBoost, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.ExtendBoost(ctx, player.DbId, kind, duration)
+}
+
+func (s *databaseRewardStore) AddBoost(player *core.Player, kind core.BoostKind, amount int32) (*core.PlayerBoost, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.AddBoost(ctx, player.DbId, kind, amount)
+}
+
+func (s *databaseRewardStore) ConsumeBoost(player *core.Player, kind core.BoostKind, amount int32) (int32, int32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.ConsumeBoost(ctx, player.DbId, kind, amount)
+}
+
+func (s *databaseRewardStore) UpdatePity(player *core.Player) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.UpdatePlayerPity(ctx, player)
+}
+
+func (s *databaseRewardStore) UpdateBrawler(player *core.Player, brawler *core.PlayerBrawler) error {
+	cardsJson, err := json.Marshal(brawler.Cards)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal brawler cards to json: %w", err)
+	}
+
+	skinsJson, err := json.Marshal(brawler.UnlockedSkinIds)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal brawler skins to json: %w", err)
+	}
+
+	stmt := `
+		update player_brawlers
+		set power_level = $1, power_points = $2, unlocked_skins = $3, selected_skin = $4, cards = $5,
+			selected_gadget = $6, selected_star_power = $7, selected_gear1 = $8, selected_gear2 = $9
+		where player_id = $10 and brawler_id = $11`
+
+	return s.dbm.Exec(stmt,
+		brawler.PowerLevel, brawler.PowerPoints,
+		skinsJson, brawler.SelectedSkinId, cardsJson,
+		brawler.SelectedGadget, brawler.SelectedStarPower, brawler.SelectedGear1, brawler.SelectedGear2,
+		player.DbId, brawler.BrawlerId,
+	)
+}
+
+func (s *databaseRewardStore) UnlockAccessory(player *core.Player, brawler *core.PlayerBrawler, kind core.AccessoryKind, id int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.UnlockAccessory(ctx, player.DbId, brawler.BrawlerId, kind, id)
+}
+
+func (s *databaseRewardStore) RecordOfferPurchase(player *core.Player, key string, limit int32) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.RecordOfferPurchase(ctx, player.DbId, key, limit)
+}
+
+func (s *databaseRewardStore) RevertOfferPurchase(player *core.Player, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.RevertOfferPurchase(ctx, player.DbId, key)
+}
+
+func (s *databaseRewardStore) InsertBrawler(player *core.Player, brawler *core.PlayerBrawler) error {
+	cardsJson, err := json.Marshal(brawler.Cards)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal new brawler cards to json: %w", err)
+	}
+
+	skinsJson, err := json.Marshal(brawler.UnlockedSkinIds)
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal new brawler skins to json: %w", err)
+	}
+
+	stmt := `
+		INSERT INTO player_brawlers (
+			player_id, brawler_id, trophies, highest_trophies,
+			power_level, power_points,
+			unlocked_skins, selected_skin, cards
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	return s.dbm.Exec(stmt,
+		player.DbId,
+		brawler.BrawlerId,
+		brawler.Trophies, brawler.HighestTrophies,
+		brawler.PowerLevel, brawler.PowerPoints,
+		skinsJson,
+		brawler.SelectedSkinId,
+		cardsJson,
+	)
+}
+
+func (s *databaseRewardStore) ClaimMilestone(player *core.Player, id int32) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.ClaimMilestone(ctx, player.DbId, id)
+}
+
+func (s *databaseRewardStore) ReplaceQuests(player *core.Player, period core.QuestPeriod, quests []*core.PlayerQuest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.ReplaceQuests(ctx, player.DbId, period, quests)
+}
+
+func (s *databaseRewardStore) UpdateQuest(player *core.Player, quest *core.PlayerQuest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.UpdateQuest(ctx, player.DbId, quest)
+}
+
+func (s *databaseRewardStore) ClaimQuest(player *core.Player, quest *core.PlayerQuest) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.ClaimQuest(ctx, player.DbId, quest)
+}
+
+func (s *databaseRewardStore) UnclaimQuest(player *core.Player, quest *core.PlayerQuest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.UnclaimQuest(ctx, player.DbId, quest)
+}
+
+func (s *databaseRewardStore) GrantPurchase(player *core.Player, receiptId string, productId string, currencyId int32, amount int32) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.GrantPurchase(ctx, player.DbId, receiptId, productId, currencyId, amount)
+}
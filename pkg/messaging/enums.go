@@ -10,3 +10,16 @@ const (
 	Banned                             = 11
 	AccountLocked                      = 13
 )
+
+// AccountResult answers an account bind or transfer request.
+type AccountResult int32
+
+const (
+	AccountOk               AccountResult = 0
+	AccountInvalidUsername                = 1
+	AccountUsernameTaken                  = 2
+	AccountWeakPassword                   = 3
+	AccountWrongCredentials               = 4
+	AccountTooManyAttempts                = 5
+	AccountServerError                    = 6
+)
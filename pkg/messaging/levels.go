@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/szcvak/sps/pkg/core"
+)
+
+type LevelReward struct {
+	Level   int32      `json:"level"`
+	Rewards []ShopItem `json:"rewards"`
+}
+
+// LevelRewardTable holds what a player gets for reaching an experience level. EveryLevel is
+// granted on every level-up, on top of the rewards listed for that level.
+type LevelRewardTable struct {
+	EveryLevel []ShopItem    `json:"every_level"`
+	Levels     []LevelReward `json:"levels"`
+
+	levels map[int32]*LevelReward
+}
+
+var levelRewardTable atomic.Pointer[LevelRewardTable]
+
+// LoadLevelRewards reads the level-up rewards from path and swaps them in. The previous table
+// is kept if the file cannot be read or is invalid.
+func LoadLevelRewards(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read level rewards: %w", err)
+	}
+
+	table := &LevelRewardTable{}
+
+	if err = json.Unmarshal(data, table); err != nil {
+		return fmt.Errorf("failed to parse level rewards: %w", err)
+	}
+
+	if err = table.validate(); err != nil {
+		return fmt.Errorf("invalid level rewards: %w", err)
+	}
+
+	levelRewardTable.Store(table)
+
+	slog.Info("loaded level rewards", "path", path, "levels", len(table.Levels))
+
+	return nil
+}
+
+// LevelRewards returns the live level-up rewards, or nil if LoadLevelRewards has not
+// succeeded yet.
+func LevelRewards() *LevelRewardTable {
+	return levelRewardTable.Load()
+}
+
+// Rewards returns everything granted for reaching level.
+func (t *LevelRewardTable) Rewards(level int32) []ShopItem {
+	rewards := make([]ShopItem, 0, len(t.EveryLevel))
+	rewards = append(rewards, t.EveryLevel...)
+
+	if reward, exists := t.levels[level]; exists {
+		rewards = append(rewards, reward.Rewards...)
+	}
+
+	return rewards
+}
+
+// AddExperience is the only way experience should be given to a player. It grants the rewards
+// of every level crossed and returns the levels gained. Storing the experience itself is left
+// to the caller, which usually saves it together with the rest of the battle results.
+func (d *DeliveryLogic) AddExperience(amount int32) int32 {
+	if amount <= 0 {
+		return 0
+	}
+
+	previous := d.player.Level()
+	d.player.Experience += amount
+	current := d.player.Level()
+
+	table := LevelRewards()
+
+	for level := previous + 1; level <= current; level++ {
+		slog.Info("player leveled up", "playerId", d.player.DbId, "level", level)
+
+		if table == nil {
+			continue
+		}
+
+		for _, item := range table.Rewards(level) {
+			if err := d.grantShopItem(item); err != nil {
+				slog.Error("failed to grant level reward!", "playerId", d.player.DbId, "level", level, "item", item.Type, "err", err)
+			}
+		}
+	}
+
+	return current - previous
+}
+
+// --- Private methods --- //
+
+func (t *LevelRewardTable) validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	for i, item := range t.EveryLevel {
+		item.validate(check, fmt.Sprintf("every_level[%d]", i), false)
+	}
+
+	t.levels = make(map[int32]*LevelReward, len(t.Levels))
+
+	for i := range t.Levels {
+		reward := &t.Levels[i]
+		name := fmt.Sprintf("levels[%d]", i)
+
+		_, duplicate := t.levels[reward.Level]
+
+		check(reward.Level >= 2 && int(reward.Level) <= len(core.RequiredExp), "%s.level must be between 2 and %d", name, len(core.RequiredExp))
+		check(!duplicate, "%s.level %d is defined twice", name, reward.Level)
+		check(len(reward.Rewards) > 0, "%s.rewards must not be empty", name)
+
+		for j, item := range reward.Rewards {
+			item.validate(check, fmt.Sprintf("%s.rewards[%d]", name, j), false)
+		}
+
+		t.levels[reward.Level] = reward
+	}
+
+	return errors.Join(errs...)
+}
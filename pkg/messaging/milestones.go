@@ -0,0 +1,203 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+
+	"github.com/szcvak/sps/pkg/core"
+)
+
+const (
+	MilestoneTrophies   = "trophies"
+	MilestoneExperience = "experience"
+)
+
+var (
+	ErrMilestoneUnknown    = errors.New("milestone does not exist")
+	ErrMilestoneNotReached = errors.New("milestone has not been reached")
+	ErrMilestoneClaimed    = errors.New("milestone has already been claimed")
+)
+
+// Milestone is a Trophy Road or experience reward. Threshold is compared against the
+// player's highest trophies or experience, depending on Type.
+type Milestone struct {
+	Id        int32      `json:"id"`
+	Type      string     `json:"type"`
+	Threshold int32      `json:"threshold"`
+	Rewards   []ShopItem `json:"rewards"`
+}
+
+type MilestoneTable struct {
+	Milestones []Milestone `json:"milestones"`
+
+	milestones  map[int32]*Milestone
+	byThreshold map[milestoneKey]*Milestone
+}
+
+type milestoneKey struct {
+	kind      string
+	threshold int32
+}
+
+var milestoneTable atomic.Pointer[MilestoneTable]
+
+// LoadMilestones reads the milestone definitions from path and swaps them in. The previous
+// table is kept if the file cannot be read or is invalid.
+func LoadMilestones(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read milestone definitions: %w", err)
+	}
+
+	table := &MilestoneTable{}
+
+	if err = json.Unmarshal(data, table); err != nil {
+		return fmt.Errorf("failed to parse milestone definitions: %w", err)
+	}
+
+	if err = table.validate(); err != nil {
+		return fmt.Errorf("invalid milestone definitions: %w", err)
+	}
+
+	milestoneTable.Store(table)
+
+	slog.Info("loaded milestone definitions", "path", path, "milestones", len(table.Milestones))
+
+	return nil
+}
+
+// Milestones returns the live milestone definitions, or nil if LoadMilestones has not
+// succeeded yet.
+func Milestones() *MilestoneTable {
+	return milestoneTable.Load()
+}
+
+func (t *MilestoneTable) Milestone(id int32) (*Milestone, bool) {
+	milestone, exists := t.milestones[id]
+	return milestone, exists
+}
+
+func (m *Milestone) Reached(player *core.Player) bool {
+	if m.Type == MilestoneExperience {
+		return player.Experience >= m.Threshold
+	}
+
+	return player.HighestTrophies >= m.Threshold
+}
+
+// ClaimMilestone grants the rewards of a reached milestone once. The claim is stored before
+// anything is granted, so a failed grant is logged rather than claimable again.
+func ClaimMilestone(player *core.Player, store RewardStore, id int32) (*DeliveryLogic, error) {
+	table := Milestones()
+
+	if table == nil {
+		return nil, errors.New("milestone definitions have not been loaded")
+	}
+
+	milestone, exists := table.Milestone(id)
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %d", ErrMilestoneUnknown, id)
+	}
+
+	if player.ClaimedMilestones[id] {
+		return nil, fmt.Errorf("%w: %d", ErrMilestoneClaimed, id)
+	}
+
+	if !milestone.Reached(player) {
+		return nil, fmt.Errorf("%w: %d", ErrMilestoneNotReached, id)
+	}
+
+	claimed, err := store.ClaimMilestone(player, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	player.ClaimedMilestones[id] = true
+
+	if !claimed {
+		return nil, fmt.Errorf("%w: %d", ErrMilestoneClaimed, id)
+	}
+
+	d := NewDeliveryLogicWithStore(player, store)
+
+	for _, item := range milestone.Rewards {
+		if err = d.grantShopItem(item); err != nil {
+			slog.Error("failed to grant milestone reward!", "playerId", player.DbId, "milestone", id, "item", item.Type, "err", err)
+		}
+	}
+
+	slog.Info("claimed milestone", "playerId", player.DbId, "milestone", id, "type", milestone.Type)
+
+	return d, nil
+}
+
+// MilestoneClaims reports the milestones the player has claimed to core.EmbedMilestones. Steps
+// of the road without a reward defined here are never claimed.
+func MilestoneClaims(player *core.Player) core.MilestoneClaimed {
+	table := Milestones()
+
+	return func(trophyRoad bool, threshold int) bool {
+		if table == nil {
+			return false
+		}
+
+		kind := MilestoneExperience
+
+		if trophyRoad {
+			kind = MilestoneTrophies
+		}
+
+		milestone, exists := table.byThreshold[milestoneKey{kind, int32(threshold)}]
+
+		return exists && player.ClaimedMilestones[milestone.Id]
+	}
+}
+
+// --- Private methods --- //
+
+func (t *MilestoneTable) validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	t.milestones = make(map[int32]*Milestone, len(t.Milestones))
+	t.byThreshold = make(map[milestoneKey]*Milestone, len(t.Milestones))
+
+	for i := range t.Milestones {
+		milestone := &t.Milestones[i]
+		name := fmt.Sprintf("milestones[%d]", i)
+
+		_, duplicate := t.milestones[milestone.Id]
+
+		check(milestone.Id > 0, "%s.id must be positive", name)
+		check(!duplicate, "%s.id %d is defined twice", name, milestone.Id)
+		check(milestone.Type == MilestoneTrophies || milestone.Type == MilestoneExperience, "%s.type must be %s or %s", name, MilestoneTrophies, MilestoneExperience)
+		check(milestone.Threshold >= 0, "%s.threshold must not be negative", name)
+		check(len(milestone.Rewards) > 0, "%s.rewards must not be empty", name)
+
+		for j, item := range milestone.Rewards {
+			item.validate(check, fmt.Sprintf("%s.rewards[%d]", name, j), false)
+		}
+
+		key := milestoneKey{milestone.Type, milestone.Threshold}
+		_, shared := t.byThreshold[key]
+
+		check(!shared, "%s.threshold %d is shared with another %s milestone", name, milestone.Threshold, milestone.Type)
+
+		t.milestones[milestone.Id] = milestone
+		t.byThreshold[key] = milestone
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,519 @@
+package messaging
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+const (
+	QuestPlay = "play"
+	QuestWin  = "win"
+
+	QuestPeriodDaily  = "daily"
+	QuestPeriodWeekly = "weekly"
+)
+
+var (
+	ErrQuestUnknown     = errors.New("quest does not exist")
+	ErrQuestClaimed     = errors.New("quest has already been claimed")
+	ErrQuestRerolled    = errors.New("quest has already been rerolled")
+	ErrNoQuestAvailable = errors.New("no other quest is available")
+)
+
+// QuestTemplate is a quest players can be given. A battle counts towards it if it was played
+// in GameMode with BrawlerId, when they are set, and was won for win quests.
+type QuestTemplate struct {
+	Id     string `json:"id"`
+	Period string `json:"period"`
+	Type   string `json:"type"`
+
+	GameMode  string `json:"game_mode,omitempty"`
+	BrawlerId *int32 `json:"brawler_id,omitempty"`
+
+	Goal    int32      `json:"goal"`
+	Rewards []ShopItem `json:"rewards"`
+
+	period core.QuestPeriod
+}
+
+type QuestTable struct {
+	DailyCount  int `json:"daily_count"`
+	WeeklyCount int `json:"weekly_count"`
+
+	// RerollPrice is paid to swap a quest for another one, once per quest and period.
+	RerollCurrencyId int32 `json:"reroll_currency_id"`
+	RerollPrice      int32 `json:"reroll_price"`
+
+	Quests []QuestTemplate `json:"quests"`
+
+	templates map[string]*QuestTemplate
+}
+
+// QuestBattle is a finished battle as far as quests are concerned.
+type QuestBattle struct {
+	GameMode  string
+	BrawlerId int32
+	Won       bool
+}
+
+var questTable atomic.Pointer[QuestTable]
+
+// LoadQuests reads the quest templates from path and swaps them in. The previous table is
+// kept if the file cannot be read or is invalid.
+func LoadQuests(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read quest definitions: %w", err)
+	}
+
+	table := &QuestTable{}
+
+	if err = json.Unmarshal(data, table); err != nil {
+		return fmt.Errorf("failed to parse quest definitions: %w", err)
+	}
+
+	if err = table.validate(); err != nil {
+		return fmt.Errorf("invalid quest definitions: %w", err)
+	}
+
+	questTable.Store(table)
+
+	slog.Info("loaded quest definitions", "path", path, "quests", len(table.Quests))
+
+	return nil
+}
+
+// Quests returns the live quest templates, or nil if LoadQuests has not succeeded yet.
+func Quests() *QuestTable {
+	return questTable.Load()
+}
+
+func (t *QuestTable) Template(id string) (*QuestTemplate, bool) {
+	template, exists := t.templates[id]
+	return template, exists
+}
+
+func (q *QuestTemplate) Matches(battle QuestBattle) bool {
+	if q.Type == QuestWin && !battle.Won {
+		return false
+	}
+
+	if q.GameMode != "" && q.GameMode != battle.GameMode {
+		return false
+	}
+
+	return q.BrawlerId == nil || *q.BrawlerId == battle.BrawlerId
+}
+
+// QuestPeriodStart returns the start of the day or week containing now. Both begin at
+// midnight UTC, weeks on Monday.
+func QuestPeriodStart(period core.QuestPeriod, now time.Time) time.Time {
+	day := now.UTC().Truncate(24 * time.Hour)
+
+	if period == core.QuestWeekly {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+
+	return day
+}
+
+// RefreshQuests gives the player new quests for every period that has started since the last
+// ones were assigned, and replaces quests whose template no longer exists.
+func RefreshQuests(player *core.Player, store RewardStore, now time.Time) error {
+	table := Quests()
+
+	if table == nil {
+		return errors.New("quest definitions have not been loaded")
+	}
+
+	for _, period := range []core.QuestPeriod{core.QuestDaily, core.QuestWeekly} {
+		start := QuestPeriodStart(period, now)
+		current := playerQuests(player, period)
+
+		stale := len(current) != table.count(period) || slices.ContainsFunc(current, func(quest *core.PlayerQuest) bool {
+			return !quest.AssignedAt.Equal(start)
+		})
+
+		if !stale {
+			if err := table.replaceRemoved(player, store, current); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		quests := table.assign(player, period, start)
+
+		if err := store.ReplaceQuests(player, period, quests); err != nil {
+			return fmt.Errorf("failed to replace quests: %w", err)
+		}
+
+		player.Quests = slices.DeleteFunc(player.Quests, func(quest *core.PlayerQuest) bool {
+			return quest.Period == period
+		})
+
+		player.Quests = append(player.Quests, quests...)
+
+		slices.SortFunc(player.Quests, func(a, b *core.PlayerQuest) int {
+			return cmp.Or(cmp.Compare(a.Period, b.Period), cmp.Compare(a.Slot, b.Slot))
+		})
+
+		slog.Info("assigned quests", "playerId", player.DbId, "period", period, "quests", len(quests))
+	}
+
+	return nil
+}
+
+// UpdateQuests counts a battle towards every matching quest of the player and claims the
+// ones it completes. The client has no quest screen to claim them from, so the rewards are
+// returned to be shown like other battle rewards.
+func UpdateQuests(player *core.Player, store RewardStore, battle QuestBattle) (*DeliveryLogic, error) {
+	if err := RefreshQuests(player, store, time.Now()); err != nil {
+		return nil, err
+	}
+
+	table := Quests()
+	d := NewDeliveryLogicWithStore(player, store)
+
+	for _, quest := range player.Quests {
+		template, exists := table.Template(quest.TemplateId)
+
+		if !exists || quest.Claimed {
+			continue
+		}
+
+		if quest.Progress < template.Goal && template.Matches(battle) {
+			quest.Progress++
+
+			if err := store.UpdateQuest(player, quest); err != nil {
+				quest.Progress--
+				return d, fmt.Errorf("failed to update quest: %w", err)
+			}
+
+			if quest.Progress == template.Goal {
+				slog.Info("completed quest", "playerId", player.DbId, "quest", quest.TemplateId)
+			}
+		}
+
+		// Quests completed earlier are claimed here too, in case their grant failed back then.
+		if quest.Progress < template.Goal {
+			continue
+		}
+
+		if err := d.claimQuest(quest, template); err != nil {
+			slog.Error("failed to claim quest!", "playerId", player.DbId, "quest", quest.TemplateId, "err", err)
+		}
+	}
+
+	return d, nil
+}
+
+// RerollQuest swaps the quest at index for another one of the same period, resetting its
+// progress. The client this server speaks has no quest screen to ask for it from, so it is only
+// kept for one that does.
+func RerollQuest(player *core.Player, store RewardStore, index int) error {
+	quest, _, err := questAt(player, store, index)
+
+	if err != nil {
+		return err
+	}
+
+	if quest.Claimed {
+		return fmt.Errorf("%w: %s", ErrQuestClaimed, quest.TemplateId)
+	}
+
+	if quest.Rerolled {
+		return fmt.Errorf("%w: %s", ErrQuestRerolled, quest.TemplateId)
+	}
+
+	table := Quests()
+	template := table.pick(player, quest.Period, assignedTemplates(playerQuests(player, quest.Period)))
+
+	if template == nil {
+		return ErrNoQuestAvailable
+	}
+
+	d := NewDeliveryLogicWithStore(player, store)
+
+	if err = d.Spend(table.RerollCurrencyId, int64(table.RerollPrice)); err != nil {
+		return err
+	}
+
+	previous := *quest
+
+	quest.TemplateId = template.Id
+	quest.Progress = 0
+	quest.Rerolled = true
+
+	if err = store.UpdateQuest(player, quest); err != nil {
+		*quest = previous
+
+		if refundErr := d.addCurrency(table.RerollCurrencyId, table.RerollPrice); refundErr != nil {
+			slog.Error("failed to refund quest reroll!", "playerId", player.DbId, "quest", previous.TemplateId, "err", refundErr)
+		}
+
+		return fmt.Errorf("failed to update quest: %w", err)
+	}
+
+	slog.Info("rerolled quest", "playerId", player.DbId, "from", previous.TemplateId, "to", quest.TemplateId)
+
+	return nil
+}
+
+// --- Private methods --- //
+
+func (t *QuestTable) count(period core.QuestPeriod) int {
+	if period == core.QuestWeekly {
+		return t.WeeklyCount
+	}
+
+	return t.DailyCount
+}
+
+// pick returns a random template of period the player can progress, leaving out the ones in
+// exclude, or nil if there is none.
+func (t *QuestTable) pick(player *core.Player, period core.QuestPeriod, exclude map[string]bool) *QuestTemplate {
+	candidates := make([]*QuestTemplate, 0)
+
+	for i := range t.Quests {
+		template := &t.Quests[i]
+
+		if template.period != period || exclude[template.Id] {
+			continue
+		}
+
+		if template.BrawlerId != nil {
+			if _, owned := player.Brawlers[*template.BrawlerId]; !owned {
+				continue
+			}
+		}
+
+		candidates = append(candidates, template)
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	return candidates[rand.IntN(len(candidates))]
+}
+
+func (t *QuestTable) assign(player *core.Player, period core.QuestPeriod, start time.Time) []*core.PlayerQuest {
+	quests := make([]*core.PlayerQuest, 0, t.count(period))
+	exclude := make(map[string]bool)
+
+	for slot := 0; slot < t.count(period); slot++ {
+		template := t.pick(player, period, exclude)
+
+		if template == nil {
+			break
+		}
+
+		exclude[template.Id] = true
+
+		quests = append(quests, &core.PlayerQuest{
+			Period:     period,
+			Slot:       int32(slot),
+			TemplateId: template.Id,
+			AssignedAt: start,
+		})
+	}
+
+	return quests
+}
+
+func (t *QuestTable) replaceRemoved(player *core.Player, store RewardStore, quests []*core.PlayerQuest) error {
+	for _, quest := range quests {
+		if _, exists := t.templates[quest.TemplateId]; exists || quest.Claimed {
+			continue
+		}
+
+		template := t.pick(player, quest.Period, assignedTemplates(quests))
+
+		if template == nil {
+			continue
+		}
+
+		slog.Warn("replacing quest with a removed template", "playerId", player.DbId, "quest", quest.TemplateId, "replacement", template.Id)
+
+		quest.TemplateId = template.Id
+		quest.Progress = 0
+
+		if err := store.UpdateQuest(player, quest); err != nil {
+			return fmt.Errorf("failed to update quest: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *QuestTable) validate() error {
+	errs := make([]error, 0)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(t.DailyCount >= 0, "daily_count must not be negative")
+	check(t.WeeklyCount >= 0, "weekly_count must not be negative")
+	check(t.RerollCurrencyId == config.CurrencyCoins || t.RerollCurrencyId == config.CurrencyGems, "reroll_currency_id must be coins or gems")
+	check(t.RerollPrice >= 0, "reroll_price must not be negative")
+
+	t.templates = make(map[string]*QuestTemplate, len(t.Quests))
+
+	// quests without a brawler, which every player can be given
+	open := make(map[core.QuestPeriod]int)
+
+	for i := range t.Quests {
+		template := &t.Quests[i]
+		name := fmt.Sprintf("quests[%d]", i)
+
+		_, duplicate := t.templates[template.Id]
+
+		check(template.Id != "", "%s.id must not be empty", name)
+		check(!duplicate, "%s.id %q is defined twice", name, template.Id)
+		check(template.Type == QuestPlay || template.Type == QuestWin, "%s.type must be %s or %s", name, QuestPlay, QuestWin)
+		check(template.Goal > 0, "%s.goal must be positive", name)
+		check(len(template.Rewards) > 0, "%s.rewards must not be empty", name)
+
+		switch template.Period {
+		case QuestPeriodDaily:
+			template.period = core.QuestDaily
+		case QuestPeriodWeekly:
+			template.period = core.QuestWeekly
+		default:
+			check(false, "%s.period must be %s or %s", name, QuestPeriodDaily, QuestPeriodWeekly)
+		}
+
+		if template.GameMode != "" {
+			check(len(csv.GetLocationsByGamemode(template.GameMode)) > 0, "%s.game_mode %q has no locations", name, template.GameMode)
+		}
+
+		if template.BrawlerId != nil {
+			_, found := csv.GetCardForCharacter(*template.BrawlerId)
+			check(found, "%s.brawler_id %d is not a brawler", name, *template.BrawlerId)
+		} else {
+			open[template.period]++
+		}
+
+		for j, item := range template.Rewards {
+			item.validate(check, fmt.Sprintf("%s.rewards[%d]", name, j), false)
+		}
+
+		t.templates[template.Id] = template
+	}
+
+	check(open[core.QuestDaily] >= t.DailyCount, "need at least %d daily quests without a brawler_id", t.DailyCount)
+	check(open[core.QuestWeekly] >= t.WeeklyCount, "need at least %d weekly quests without a brawler_id", t.WeeklyCount)
+
+	return errors.Join(errs...)
+}
+
+// --- Helper functions --- //
+
+// claimQuest grants the rewards of a completed quest. The claim is stored first so that two
+// sessions cannot both grant them, and is taken back along with the currencies granted so far
+// if a reward cannot be granted, leaving the quest to be claimed again.
+func (d *DeliveryLogic) claimQuest(quest *core.PlayerQuest, template *QuestTemplate) error {
+	if err := checkOfferItems(d.data, d.player, template.Rewards); err != nil {
+		return err
+	}
+
+	claimed, err := d.store.ClaimQuest(d.player, quest)
+
+	if err != nil {
+		return err
+	}
+
+	quest.Claimed = true
+
+	if !claimed {
+		return fmt.Errorf("%w: %s", ErrQuestClaimed, quest.TemplateId)
+	}
+
+	for i, item := range template.Rewards {
+		if err = d.grantShopItem(item); err != nil {
+			d.revertQuestClaim(quest, template.Rewards[:i])
+			return fmt.Errorf("error granting quest reward: %w", err)
+		}
+	}
+
+	slog.Info("claimed quest", "playerId", d.player.DbId, "quest", quest.TemplateId)
+
+	return nil
+}
+
+func (d *DeliveryLogic) revertQuestClaim(quest *core.PlayerQuest, granted []ShopItem) {
+	for _, item := range granted {
+		if item.Type != ShopItemCurrency {
+			continue
+		}
+
+		if err := d.addCurrency(item.CurrencyId, -item.Amount); err != nil {
+			slog.Error("failed to take back quest reward!", "playerId", d.player.DbId, "quest", quest.TemplateId, "err", err)
+		}
+	}
+
+	if err := d.store.UnclaimQuest(d.player, quest); err != nil {
+		slog.Error("failed to unclaim quest!", "playerId", d.player.DbId, "quest", quest.TemplateId, "err", err)
+		return
+	}
+
+	quest.Claimed = false
+}
+
+func questAt(player *core.Player, store RewardStore, index int) (*core.PlayerQuest, *QuestTemplate, error) {
+	if err := RefreshQuests(player, store, time.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	if index < 0 || index >= len(player.Quests) {
+		return nil, nil, fmt.Errorf("%w: index %d", ErrQuestUnknown, index)
+	}
+
+	quest := player.Quests[index]
+	template, exists := Quests().Template(quest.TemplateId)
+
+	if !exists {
+		return nil, nil, fmt.Errorf("%w: %s", ErrQuestUnknown, quest.TemplateId)
+	}
+
+	return quest, template, nil
+}
+
+func playerQuests(player *core.Player, period core.QuestPeriod) []*core.PlayerQuest {
+	quests := make([]*core.PlayerQuest, 0)
+
+	for _, quest := range player.Quests {
+		if quest.Period == period {
+			quests = append(quests, quest)
+		}
+	}
+
+	return quests
+}
+
+func assignedTemplates(quests []*core.PlayerQuest) map[string]bool {
+	assigned := make(map[string]bool, len(quests))
+
+	for _, quest := range quests {
+		assigned[quest.TemplateId] = true
+	}
+
+	return assigned
+}
@@ -0,0 +1,50 @@
+package messaging
+
+import (
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+// currencyResources names the resources.csv row of each currency. Currencies the file does not
+// know, like bling, are never capped.
+var currencyResources = map[int32]string{
+	config.CurrencyCoins:  "Gold",
+	config.CurrencyGems:   "Diamonds",
+	config.CurrencyChips:  "Dust",
+	config.CurrencyElixir: "Upgradium",
+}
+
+// CurrencyCap returns the most of currencyId a player may hold, zero when it is uncapped.
+func CurrencyCap(currencyId int32) int64 {
+	name, exists := currencyResources[currencyId]
+
+	if !exists {
+		return 0
+	}
+
+	return int64(csv.GetResourceCap(name))
+}
+
+// CapBalance returns balance after adding amount, held at the currency's cap. A balance that is
+// already above the cap is never lowered.
+func CapBalance(currencyId int32, balance int64, amount int64) int64 {
+	newBalance := balance + amount
+	limit := CurrencyCap(currencyId)
+
+	if limit > 0 && newBalance > limit {
+		return max(limit, balance)
+	}
+
+	return newBalance
+}
+
+// ResourceCurrency returns the currency behind a resources.csv row.
+func ResourceCurrency(name string) (int32, bool) {
+	for currencyId, resource := range currencyResources {
+		if resource == name {
+			return currencyId, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,543 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+const (
+	ShopItemCurrency    = "currency"
+	ShopItemBox         = "box"
+	ShopItemSkin        = "skin"
+	ShopItemBrawler     = "brawler"
+	ShopItemPowerPoints = "power_points"
+	ShopItemCoinDoubler = "coin_doubler"
+	ShopItemCoinBooster = "coin_booster"
+)
+
+// client item types shown in the shop
+const (
+	shopClientCoins       = 1
+	shopClientBrawler     = 3
+	shopClientSkin        = 4
+	shopClientBrawlBox    = 6
+	shopClientPowerPoints = 8
+	shopClientCoinDoubler = 9
+	shopClientMegaBox     = 10
+	shopClientBigBox      = 14
+	shopClientGems        = 16
+)
+
+var (
+	ErrOfferUnavailable   = errors.New("offer is not available")
+	ErrOfferLimitReached  = errors.New("offer purchase limit reached")
+	ErrOfferNotApplicable = errors.New("offer cannot be applied to player")
+)
+
+type ShopItem struct {
+	Type       string `json:"type"`
+	CurrencyId int32  `json:"currency_id,omitempty"`
+	BoxType    int32  `json:"box_type,omitempty"`
+	SkinId     int32  `json:"skin_id,omitempty"`
+
+	// CardId is the unlock card of the brawler. Power point deals without one are given to a
+	// brawler the player owns, picked with the daily rotation.
+	CardId int32 `json:"card_id,omitempty"`
+
+	Amount int32 `json:"amount"`
+}
+
+type ShopOffer struct {
+	Id    string     `json:"id"`
+	Title string     `json:"title"`
+	Items []ShopItem `json:"items"`
+
+	CurrencyId    int32 `json:"currency_id"`
+	Price         int32 `json:"price"`
+	OriginalPrice int32 `json:"original_price,omitempty"`
+
+	// Limit is the number of purchases per player, per day for daily deals. 0 is unlimited.
+	Limit int32 `json:"limit"`
+
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+
+	Featured bool `json:"featured"`
+}
+
+type DailyDeals struct {
+	Count int         `json:"count"`
+	Pool  []ShopOffer `json:"pool"`
+}
+
+type ShopCatalog struct {
+	DailyDeals DailyDeals  `json:"daily_deals"`
+	Offers     []ShopOffer `json:"offers"`
+}
+
+// PlayerOffer is an offer as shown to one player, with its items resolved for them.
+type PlayerOffer struct {
+	Offer     *ShopOffer
+	Key       string
+	Items     []ShopItem
+	Daily     bool
+	EndTime   time.Time
+	Purchased int32
+}
+
+var shopCatalog atomic.Pointer[ShopCatalog]
+
+// LoadShop reads the shop catalog from path and swaps it in. The previous catalog is kept if
+// the file cannot be read or is invalid.
+func LoadShop(path string) error {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to read shop catalog: %w", err)
+	}
+
+	catalog := &ShopCatalog{}
+
+	if err = json.Unmarshal(data, catalog); err != nil {
+		return fmt.Errorf("failed to parse shop catalog: %w", err)
+	}
+
+	if err = catalog.validate(); err != nil {
+		return fmt.Errorf("invalid shop catalog: %w", err)
+	}
+
+	shopCatalog.Store(catalog)
+
+	slog.Info("loaded shop catalog", "path", path, "offers", len(catalog.Offers), "dailyDeals", len(catalog.DailyDeals.Pool))
+
+	return nil
+}
+
+// Shop returns the live shop catalog, or nil if LoadShop has not succeeded yet.
+func Shop() *ShopCatalog {
+	return shopCatalog.Load()
+}
+
+// OffersFor lists the offers the player can see at now: featured and limited-time offers in
+// catalog order, followed by the player's daily deals. Daily deals are resolved against data.
+func (c *ShopCatalog) OffersFor(data *csv.GameData, player *core.Player, now time.Time) []PlayerOffer {
+	offers := make([]PlayerOffer, 0, len(c.Offers)+c.DailyDeals.Count)
+
+	for i := range c.Offers {
+		offer := &c.Offers[i]
+
+		if offer.Start != nil && now.Before(*offer.Start) {
+			continue
+		}
+
+		if offer.End != nil && !now.Before(*offer.End) {
+			continue
+		}
+
+		var end time.Time
+
+		if offer.End != nil {
+			end = *offer.End
+		}
+
+		offers = append(offers, PlayerOffer{
+			Offer:     offer,
+			Key:       offer.Id,
+			Items:     offer.Items,
+			EndTime:   end,
+			Purchased: player.OfferPurchases[offer.Id],
+		})
+	}
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	rng := rand.New(rand.NewPCG(uint64(player.DbId), uint64(day.Unix())))
+
+	pool := make([]int, len(c.DailyDeals.Pool))
+
+	for i := range pool {
+		pool[i] = i
+	}
+
+	rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+
+	owned := make([]int32, 0, len(player.Brawlers))
+
+	for id := range player.Brawlers {
+		owned = append(owned, id)
+	}
+
+	slices.Sort(owned)
+
+	deals := 0
+
+	for _, index := range pool {
+		if deals == c.DailyDeals.Count {
+			break
+		}
+
+		offer := &c.DailyDeals.Pool[index]
+		items, ok := resolveDailyItems(data, offer.Items, owned, rng)
+
+		if !ok {
+			continue
+		}
+
+		key := fmt.Sprintf("%s@%s", offer.Id, day.Format("2006-01-02"))
+
+		offers = append(offers, PlayerOffer{
+			Offer:     offer,
+			Key:       key,
+			Items:     items,
+			Daily:     true,
+			EndTime:   day.Add(24 * time.Hour),
+			Purchased: player.OfferPurchases[key],
+		})
+
+		deals++
+	}
+
+	return offers
+}
+
+// PurchaseOffer buys the offer at index in the shop the player was last shown. An offer that
+// has ended since then is refused rather than replaced by whatever took its place. The
+// purchase is recorded before anything is spent, which keeps limited offers from being bought
+// twice. The returned DeliveryLogic holds the rewards of any box in the offer, or none.
+func PurchaseOffer(player *core.Player, store RewardStore, index int) (*DeliveryLogic, error) {
+	catalog := Shop()
+
+	if catalog == nil {
+		return nil, errors.New("shop catalog has not been loaded")
+	}
+
+	d := NewDeliveryLogicWithStore(player, store)
+
+	now := time.Now()
+	shownAt := player.ShopShownAt
+
+	if shownAt.IsZero() {
+		shownAt = now
+	}
+
+	offers := catalog.OffersFor(d.data, player, shownAt)
+
+	if index < 0 || index >= len(offers) {
+		return nil, fmt.Errorf("%w: index %d of %d", ErrOfferUnavailable, index, len(offers))
+	}
+
+	offer := offers[index]
+
+	if !offer.EndTime.IsZero() && !now.Before(offer.EndTime) {
+		return nil, fmt.Errorf("%w: %s ended at %s", ErrOfferUnavailable, offer.Key, offer.EndTime)
+	}
+
+	if offer.Offer.Limit > 0 && offer.Purchased >= offer.Offer.Limit {
+		return nil, fmt.Errorf("%w: %s", ErrOfferLimitReached, offer.Key)
+	}
+
+	if err := checkOfferItems(d.data, player, offer.Items); err != nil {
+		return nil, err
+	}
+
+	recorded, err := store.RecordOfferPurchase(player, offer.Key, offer.Offer.Limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to record offer purchase: %w", err)
+	}
+
+	if !recorded {
+		return nil, fmt.Errorf("%w: %s", ErrOfferLimitReached, offer.Key)
+	}
+
+	if err = d.Spend(offer.Offer.CurrencyId, int64(offer.Offer.Price)); err != nil {
+		d.revertOfferPurchase(offer.Key)
+		return nil, err
+	}
+
+	player.OfferPurchases[offer.Key]++
+
+	for _, item := range offer.Items {
+		if err = d.grantShopItem(item); err != nil {
+			slog.Error("failed to grant offer item!", "playerId", player.DbId, "offer", offer.Key, "item", item.Type, "err", err)
+
+			if refundErr := d.addCurrency(offer.Offer.CurrencyId, offer.Offer.Price); refundErr != nil {
+				slog.Error("failed to refund offer!", "playerId", player.DbId, "offer", offer.Key, "err", refundErr)
+			}
+
+			player.OfferPurchases[offer.Key]--
+			d.revertOfferPurchase(offer.Key)
+
+			return nil, fmt.Errorf("error granting offer item: %w", err)
+		}
+	}
+
+	slog.Info("purchased offer", "playerId", player.DbId, "offer", offer.Key, "currency", offer.Offer.CurrencyId, "price", offer.Offer.Price)
+
+	return d, nil
+}
+
+// EmbedShop writes the player's offers for OwnHomeDataMessage.
+func EmbedShop(stream *core.ByteStream, player *core.Player) {
+	catalog := Shop()
+
+	if catalog == nil {
+		stream.Write(core.VInt(0))
+		return
+	}
+
+	now := time.Now()
+	data := csv.Data()
+	offers := catalog.OffersFor(data, player, now)
+
+	player.ShopShownAt = now
+
+	stream.Write(core.VInt(len(offers)))
+
+	for _, offer := range offers {
+		stream.Write(core.VInt(len(offer.Items)))
+
+		for _, item := range offer.Items {
+			clientType, ref := item.clientData(data)
+
+			stream.Write(core.VInt(clientType))
+			stream.Write(core.VInt(item.Amount))
+			stream.Write(ref)
+			stream.Write(core.VInt(item.SkinId))
+		}
+
+		currency := 0
+
+		if offer.Offer.CurrencyId == config.CurrencyCoins {
+			currency = 1
+		}
+
+		timeLeft := int32(0)
+
+		if !offer.EndTime.IsZero() {
+			timeLeft = int32(offer.EndTime.Sub(now).Seconds())
+		}
+
+		display := 0
+
+		if offer.Daily {
+			display = 1
+		}
+
+		stream.Write(core.VInt(currency))
+		stream.Write(core.VInt(offer.Offer.Price))
+		stream.Write(core.VInt(timeLeft))
+
+		stream.Write(core.VInt(1))
+		stream.Write(core.VInt(100))
+
+		stream.Write(offer.Offer.Limit > 0 && offer.Purchased >= offer.Offer.Limit)
+		stream.Write(false)
+
+		stream.Write(core.VInt(display))
+		stream.Write(offer.Offer.Featured)
+
+		stream.Write(core.VInt(offer.Offer.OriginalPrice))
+		stream.Write(offer.Offer.Title)
+		stream.Write(false)
+	}
+}
+
+// --- Private methods --- //
+
+// revertOfferPurchase takes back a recorded purchase that was not paid for or delivered.
+func (d *DeliveryLogic) revertOfferPurchase(key string) {
+	if err := d.store.RevertOfferPurchase(d.player, key); err != nil {
+		slog.Error("failed to revert offer purchase!", "playerId", d.player.DbId, "offer", key, "err", err)
+	}
+}
+
+func (d *DeliveryLogic) grantShopItem(item ShopItem) error {
+	var err error
+
+	switch item.Type {
+	case ShopItemCurrency:
+		err = d.addCurrency(item.CurrencyId, item.Amount)
+	case ShopItemBox:
+		for i := int32(0); i < item.Amount && err == nil; i++ {
+			err = d.OpenBox(item.BoxType)
+		}
+	case ShopItemSkin:
+		err = d.grantSkin(item.SkinId)
+	case ShopItemBrawler:
+		_, err = d.grantBrawler(item.CardId, 0, 0)
+	case ShopItemPowerPoints:
+		_, err = d.grantPowerPoints(d.data.GetBrawlerId(item.CardId), item.Amount, 0)
+	case ShopItemCoinDoubler:
+		_, err = d.grantCoinDoubler(item.Amount, 0)
+	case ShopItemCoinBooster:
+		_, err = d.grantCoinBooster(item.Amount, 0)
+	default:
+		err = fmt.Errorf("unknown shop item type: %s", item.Type)
+	}
+
+	return err
+}
+
+func (item ShopItem) clientData(data *csv.GameData) (int32, core.ScId) {
+	switch item.Type {
+	case ShopItemCurrency:
+		if item.CurrencyId == config.CurrencyGems {
+			return shopClientGems, core.ScId{0, 0}
+		}
+
+		return shopClientCoins, core.ScId{0, 0}
+	case ShopItemBox:
+		if boxes := Boxes(); boxes != nil {
+			if box, ok := boxes.Box(item.BoxType); ok && box.Id == 11 {
+				return shopClientMegaBox, core.ScId{0, 0}
+			} else if ok && box.Id == 12 {
+				return shopClientBigBox, core.ScId{0, 0}
+			}
+		}
+
+		return shopClientBrawlBox, core.ScId{0, 0}
+	case ShopItemSkin:
+		return shopClientSkin, core.ScId{16, data.GetBrawlerForSkin(item.SkinId)}
+	case ShopItemBrawler:
+		return shopClientBrawler, core.ScId{16, data.GetBrawlerId(item.CardId)}
+	case ShopItemPowerPoints:
+		return shopClientPowerPoints, core.ScId{16, data.GetBrawlerId(item.CardId)}
+	default:
+		return shopClientCoinDoubler, core.ScId{0, 0}
+	}
+}
+
+func (c *ShopCatalog) validate() error {
+	errs := make([]error, 0)
+	ids := make(map[string]bool)
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	validateOffer := func(name string, offer *ShopOffer, daily bool) {
+		check(offer.Id != "", "%s.id must not be empty", name)
+		check(!ids[offer.Id], "%s.id %q is defined twice", name, offer.Id)
+		check(offer.CurrencyId == config.CurrencyCoins || offer.CurrencyId == config.CurrencyGems, "%s.currency_id must be coins or gems", name)
+		check(offer.Price >= 0, "%s.price must not be negative", name)
+		check(offer.Limit >= 0, "%s.limit must not be negative", name)
+		check(len(offer.Items) > 0, "%s.items must not be empty", name)
+		check(offer.Start == nil || offer.End == nil || offer.Start.Before(*offer.End), "%s.start must be before end", name)
+		check(!daily || (offer.Start == nil && offer.End == nil), "%s cannot have start or end times", name)
+
+		ids[offer.Id] = true
+
+		for j, item := range offer.Items {
+			item.validate(check, fmt.Sprintf("%s.items[%d]", name, j), daily)
+		}
+	}
+
+	for i := range c.Offers {
+		validateOffer(fmt.Sprintf("offers[%d]", i), &c.Offers[i], false)
+	}
+
+	check(c.DailyDeals.Count >= 0, "daily_deals.count must not be negative")
+
+	for i := range c.DailyDeals.Pool {
+		validateOffer(fmt.Sprintf("daily_deals.pool[%d]", i), &c.DailyDeals.Pool[i], true)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate reports item errors through check. Power points may leave out the brawler when
+// resolved per player.
+func (item ShopItem) validate(check func(ok bool, format string, args ...any), name string, resolved bool) {
+	switch item.Type {
+	case ShopItemCurrency:
+		check(item.CurrencyId == config.CurrencyCoins || item.CurrencyId == config.CurrencyGems, "%s.currency_id must be coins or gems", name)
+		check(item.Amount > 0, "%s.amount must be positive", name)
+	case ShopItemBox:
+		check(item.BoxType != 0, "%s.box_type must be set", name)
+		check(item.Amount > 0, "%s.amount must be positive", name)
+	case ShopItemSkin:
+		check(item.SkinId > 0, "%s.skin_id must be set", name)
+	case ShopItemBrawler:
+		check(item.CardId > 0, "%s.card_id must be set", name)
+	case ShopItemPowerPoints:
+		check(item.Amount > 0, "%s.amount must be positive", name)
+		check(item.CardId > 0 || resolved, "%s.card_id must be set outside daily deals", name)
+	case ShopItemCoinDoubler, ShopItemCoinBooster:
+		check(item.Amount > 0, "%s.amount must be positive", name)
+	default:
+		check(false, "%s.type %q is unknown", name, item.Type)
+	}
+}
+
+// --- Helper functions --- //
+
+// resolveDailyItems fills in the brawler of power point deals. Deals that need a brawler are
+// skipped for players without one.
+func resolveDailyItems(data *csv.GameData, items []ShopItem, owned []int32, rng *rand.Rand) ([]ShopItem, bool) {
+	resolved := make([]ShopItem, len(items))
+	copy(resolved, items)
+
+	for i := range resolved {
+		if resolved[i].Type != ShopItemPowerPoints || resolved[i].CardId != 0 {
+			continue
+		}
+
+		if len(owned) == 0 {
+			return nil, false
+		}
+
+		card, ok := data.GetCardForCharacter(owned[rng.IntN(len(owned))])
+
+		if !ok {
+			return nil, false
+		}
+
+		resolved[i].CardId = card
+	}
+
+	return resolved, true
+}
+
+// checkOfferItems refuses offers that would grant something the player cannot receive, before
+// anything is spent.
+func checkOfferItems(data *csv.GameData, player *core.Player, items []ShopItem) error {
+	for _, item := range items {
+		switch item.Type {
+		case ShopItemBrawler:
+			if _, owned := player.Brawlers[data.GetBrawlerId(item.CardId)]; owned {
+				return fmt.Errorf("%w: brawler of card %d is already unlocked", ErrOfferNotApplicable, item.CardId)
+			}
+		case ShopItemSkin:
+			brawler, owned := player.Brawlers[data.GetBrawlerForSkin(item.SkinId)]
+
+			if !owned || slices.Contains(brawler.UnlockedSkinIds, item.SkinId) {
+				return fmt.Errorf("%w: skin %d", ErrOfferNotApplicable, item.SkinId)
+			}
+		case ShopItemPowerPoints:
+			if _, owned := player.Brawlers[data.GetBrawlerId(item.CardId)]; !owned {
+				return fmt.Errorf("%w: brawler of card %d is not unlocked", ErrOfferNotApplicable, item.CardId)
+			}
+		case ShopItemBox:
+			if boxes := Boxes(); boxes == nil {
+				return fmt.Errorf("%w: box definitions have not been loaded", ErrOfferNotApplicable)
+			} else if _, ok := boxes.Box(item.BoxType); !ok {
+				return fmt.Errorf("%w: unknown box type %d", ErrOfferNotApplicable, item.BoxType)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/database"
+)
+
+type databaseTicketStore struct {
+	dbm *database.Manager
+}
+
+// NewDatabaseTicketStore keeps the entries of ticketed events in dbm.
+func NewDatabaseTicketStore(dbm *database.Manager) core.TicketStore {
+	return &databaseTicketStore{dbm: dbm}
+}
+
+// EnterTicketedEvent charges the entry fee when the player readies up for the event of slot.
+// Free events and entries that were already paid for pass without a charge. The attempt is
+// given back if the fee cannot be paid.
+func EnterTicketedEvent(player *core.Player, store RewardStore, slot int32) error {
+	em := core.GetEventManager()
+	ticketed, due, err := em.EnterTicketed(slot, player.DbId)
+
+	if err != nil {
+		return err
+	}
+
+	if !due {
+		return nil
+	}
+
+	if err = NewDeliveryLogicWithStore(player, store).Spend(ticketed.CurrencyId, int64(ticketed.EntryCost)); err != nil {
+		em.CancelTicketed(slot, player.DbId)
+		return err
+	}
+
+	slog.Info("entered ticketed event", "playerId", player.DbId, "slot", slot, "currencyId", ticketed.CurrencyId, "cost", ticketed.EntryCost)
+
+	return nil
+}
+
+// FinishTicketedEvent pays out the reward tier reached with rank when the battle at location
+// was a paid entry of a ticketed event. It returns the granted tier, or nil if there is none.
+func FinishTicketedEvent(player *core.Player, store RewardStore, location int32, rank int32) (*config.TicketedReward, error) {
+	ticketed, paid := core.GetEventManager().FinishTicketed(location, player.DbId)
+
+	if ticketed == nil {
+		return nil, nil
+	}
+
+	if !paid {
+		slog.Warn("ticketed battle ended without an entry", "playerId", player.DbId, "location", location)
+		return nil, nil
+	}
+
+	reward := ticketedReward(ticketed, rank)
+
+	if reward == nil {
+		return nil, nil
+	}
+
+	if err := NewDeliveryLogicWithStore(player, store).addCurrency(reward.CurrencyId, reward.Amount); err != nil {
+		return nil, fmt.Errorf("failed to grant ticketed reward: %w", err)
+	}
+
+	slog.Info("granted ticketed reward", "playerId", player.DbId, "rank", rank, "currencyId", reward.CurrencyId, "amount", reward.Amount)
+
+	return reward, nil
+}
+
+func (s *databaseTicketStore) LoadTicketEntries(slot int, startTime time.Time) (map[int64]*core.TicketEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.LoadTicketEntries(ctx, slot, startTime)
+}
+
+func (s *databaseTicketStore) SaveTicketEntry(slot int, startTime time.Time, playerId int64, entry core.TicketEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return s.dbm.SaveTicketEntry(ctx, slot, startTime, playerId, entry)
+}
+
+// --- Helper functions --- //
+
+func ticketedReward(ticketed *config.TicketedEvent, rank int32) *config.TicketedReward {
+	if rank < 1 {
+		return nil
+	}
+
+	for i := range ticketed.Rewards {
+		if rank <= ticketed.Rewards[i].MaxRank {
+			return &ticketed.Rewards[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/szcvak/sps/pkg/config"
+	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+var (
+	ErrBrawlerNotUnlocked      = errors.New("brawler is not unlocked")
+	ErrMaximumUpgradeLevel     = errors.New("brawler is at the maximum upgrade level")
+	ErrInsufficientPowerPoints = errors.New("insufficient power points")
+)
+
+// UpgradeCost returns the price of taking a brawler from level to level+1.
+func UpgradeCost(level int32) (config.UpgradeCost, error) {
+	cfg := config.Get()
+
+	if level < 1 || level >= int32(cfg.Gameplay.MaximumUpgradeLevel) {
+		return config.UpgradeCost{}, fmt.Errorf("%w: level %d", ErrMaximumUpgradeLevel, level)
+	}
+
+	return cfg.Economy.UpgradeCosts[level-1], nil
+}
+
+// UpgradeCardLevels returns the levels of the hp and skill cards the client shows for a
+// brawler. The client counts a brawler's level as one more than its upgrade cards, so the
+// levels above 1 are split between them, the first cards taking any remainder.
+func UpgradeCardLevels(data *csv.GameData, brawler *core.PlayerBrawler) map[int32]int32 {
+	cards := data.GetUpgradeCardsForCharacter(brawler.BrawlerId)
+	levels := make(map[int32]int32, len(cards))
+
+	if len(cards) == 0 || brawler.PowerLevel <= 1 {
+		return levels
+	}
+
+	upgrades := brawler.PowerLevel - 1
+	count := int32(len(cards))
+
+	for i, card := range cards {
+		level := upgrades / count
+
+		if int32(i) < upgrades%count {
+			level++
+		}
+
+		if level > 0 {
+			levels[card] = level
+		}
+	}
+
+	return levels
+}
+
+// UpgradeBrawler raises the power level of a brawler by one, paying the power points of the
+// brawler and the coins from the upgrade table.
+func (d *DeliveryLogic) UpgradeBrawler(brawlerId int32) error {
+	brawler, exists := d.player.Brawlers[brawlerId]
+
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrBrawlerNotUnlocked, brawlerId)
+	}
+
+	cost, err := UpgradeCost(brawler.PowerLevel)
+
+	if err != nil {
+		return err
+	}
+
+	if brawler.PowerPoints < cost.PowerPoints {
+		return fmt.Errorf("%w: need %d, have %d", ErrInsufficientPowerPoints, cost.PowerPoints, brawler.PowerPoints)
+	}
+
+	if err = d.Spend(config.CurrencyCoins, cost.Coins); err != nil {
+		return err
+	}
+
+	brawler.PowerLevel++
+	brawler.PowerPoints -= cost.PowerPoints
+
+	if err = d.store.UpdateBrawler(d.player, brawler); err != nil {
+		brawler.PowerLevel--
+		brawler.PowerPoints += cost.PowerPoints
+
+		if refundErr := d.addCurrency(config.CurrencyCoins, int32(cost.Coins)); refundErr != nil {
+			slog.Error("failed to refund upgrade!", "playerId", d.player.DbId, "coins", cost.Coins, "err", refundErr)
+		}
+
+		return fmt.Errorf("failed to update brawler: %w", err)
+	}
+
+	slog.Info("upgraded brawler", "playerId", d.player.DbId, "brawler", brawlerId, "level", brawler.PowerLevel)
+
+	return nil
+}
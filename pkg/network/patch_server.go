@@ -0,0 +1,123 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/szcvak/sps/pkg/csv"
+)
+
+// PatchServer hosts the content files of the loaded game data for clients to update from, at
+// /<sha>/fingerprint.json and /<sha>/<file>. Only the current sha is served, so a client that
+// started downloading before a reload fails and is sent the new fingerprint on its next login.
+type PatchServer struct {
+	address string
+	server  *http.Server
+}
+
+func NewPatchServer(address string) *PatchServer {
+	p := &PatchServer{address: address}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{sha}/{file...}", p.serveFile)
+
+	p.server = &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return p
+}
+
+func (p *PatchServer) Serve() error {
+	ln, err := net.Listen("tcp", p.address)
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("serving assets", "address", p.address)
+
+	err = p.server.Serve(ln)
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (p *PatchServer) Close() {
+	if err := p.server.Close(); err != nil {
+		slog.Error("failed to close patch server!", "err", err)
+	}
+}
+
+// --- Private methods --- //
+
+func (p *PatchServer) serveFile(w http.ResponseWriter, r *http.Request) {
+	gameData := csv.Data()
+
+	if r.PathValue("sha") != gameData.Fingerprint().Sha {
+		http.NotFound(w, r)
+		return
+	}
+
+	file := r.PathValue("file")
+
+	if file == "fingerprint.json" {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(gameData.FingerprintJson())
+
+		return
+	}
+
+	data, exists := gameData.CompressedFile(file)
+
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(data)
+
+		return
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+
+	if err != nil {
+		slog.Error("failed to decompress asset!", "file", file, "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	_, _ = io.Copy(w, reader)
+}
+
+// --- Helper functions --- //
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(encoding), ";")
+
+		if name == "gzip" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -41,4 +41,6 @@ func init() {
 	registerClientMessage(14360, func() messaging.ClientMessage { return messages.NewTeamPostAdMessage() })
 	registerClientMessage(14306, func() messaging.ClientMessage { return messages.NewAlliancePromoteMessage() })
 	registerClientMessage(14307, func() messaging.ClientMessage { return messages.NewAllianceKickMessage() })
+	registerClientMessage(10190, func() messaging.ClientMessage { return messages.NewAccountBindMessage() })
+	registerClientMessage(10191, func() messaging.ClientMessage { return messages.NewAccountTransferMessage() })
 }
@@ -2,7 +2,10 @@ package network
 
 import (
 	"encoding/binary"
+	"errors"
+	"github.com/szcvak/sps/pkg/config"
 	"github.com/szcvak/sps/pkg/core"
+	"github.com/szcvak/sps/pkg/crypt"
 	"github.com/szcvak/sps/pkg/database"
 	"github.com/szcvak/sps/pkg/hub"
 	"github.com/szcvak/sps/pkg/messages"
@@ -12,6 +15,11 @@ import (
 	"sync/atomic"
 )
 
+const (
+	loginMessageId uint16 = 10101
+	maxTokenLength int32  = 256
+)
+
 type Server struct {
 	address string
 	ln      net.Listener
@@ -96,12 +104,7 @@ func (s *Server) handleClient(wrapper *core.ClientWrapper) {
 			}
 		}
 		
-		for i, d := range messages.LoggedInUsers {
-			if d.Wrapper == wrapper {
-				messages.LoggedInUsers = remove(messages.LoggedInUsers, i)
-				break
-			}
-		}
+		messages.RemoveLoggedInUser(wrapper)
 		
 		wrapper.Player = nil
 		wrapper.Close()
@@ -111,12 +114,13 @@ func (s *Server) handleClient(wrapper *core.ClientWrapper) {
 	
 	conn := wrapper.Conn()
 	header := make([]byte, 7)
+	hasCodec := false
 
 	for {
-		_, err := conn.Read(header)
+		_, err := io.ReadFull(conn, header)
 
 		if err != nil {
-			if err != io.EOF {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
 				slog.Error("failed to read packet header!", "err", err)
 			}
 
@@ -131,14 +135,45 @@ func (s *Server) handleClient(wrapper *core.ClientWrapper) {
 
 		payload := make([]byte, payloadSize)
 
-		_, err = conn.Read(payload)
+		_, err = io.ReadFull(conn, payload)
 
 		if err != nil {
 			slog.Error("failed to read payload!", "err", err)
 			break
 		}
 
-		wrapper.Decrypt(payload)
+		if !hasCodec {
+			hasCodec = true
+
+			if packetId == crypt.ClientHelloId {
+				if err = handshake(wrapper, payload); err != nil {
+					slog.Error("failed to complete handshake!", "err", err)
+					break
+				}
+
+				continue
+			}
+
+			if config.Get().Crypto.RequireHandshake {
+				slog.Warn("refusing client without handshake", "id", packetId)
+				break
+			}
+
+			var valid func([]byte) bool
+
+			if packetId == loginMessageId {
+				valid = looksLikeLogin
+			}
+
+			wrapper.SetCodec(core.NewRc4Codec(payload, valid))
+		}
+
+		payload, err = wrapper.Decrypt(payload)
+
+		if err != nil {
+			slog.Error("failed to decrypt payload!", "id", packetId, "err", err)
+			break
+		}
 
 		factory, exists := ClientRegistry[packetId]
 
@@ -147,20 +182,44 @@ func (s *Server) handleClient(wrapper *core.ClientWrapper) {
 			continue
 		}
 
+		wrapper.Player.ApplyUpdates()
+
 		msg := factory()
 		msg.Unmarshal(payload)
 		msg.Process(wrapper, s.dbm)
 	}
 }
 
-func (s *Server) Close() {
-	close(s.quitch)
-	s.closed = true
+// handshake answers the ClientHello that opened the connection and switches it to the
+// session codec.
+func handshake(wrapper *core.ClientWrapper, hello []byte) error {
+	reply, codec, err := crypt.AcceptHandshake(hello)
+
+	if err != nil {
+		return err
+	}
+
+	wrapper.SendRaw(crypt.ServerHelloId, 0, reply)
+	wrapper.SetCodec(codec)
+
+	slog.Info("completed session handshake")
+
+	return nil
 }
 
-// --- Helper functions --- //
+// looksLikeLogin tells whether a decrypted LoginMessage payload starts like one, two ids and
+// a token of sensible length, which a payload decrypted with the wrong key almost never does.
+func looksLikeLogin(payload []byte) bool {
+	if len(payload) < 12 {
+		return false
+	}
+
+	tokenLength := int32(binary.BigEndian.Uint32(payload[8:12]))
 
-func remove[T any](s []T, i int) []T {
-	s[i] = s[len(s)-1]
-	return s[:len(s)-1]
+	return tokenLength >= -1 && tokenLength <= maxTokenLength && int(tokenLength) <= len(payload)-12
+}
+
+func (s *Server) Close() {
+	close(s.quitch)
+	s.closed = true
 }